@@ -1,7 +1,147 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/matching"
+	httpapi "hostaggr/internal/http"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/providers"
+	"hostaggr/internal/search"
+)
 
 func main() {
-	fmt.Println("Hello, World!")
+	if err := run(); err != nil {
+		slog.Error("server exited with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	metrics := obs.NewMetrics()
+
+	provs := []providers.Provider{
+		providers.NewMock1(),
+		providers.NewMock2(),
+		providers.NewMock3(),
+	}
+
+	cache, err := newResultCache(cfg, metrics)
+	if err != nil {
+		return err
+	}
+	defer cache.Stop()
+
+	rateLimiter, err := newRateLimiter(cfg, metrics)
+	if err != nil {
+		return err
+	}
+	defer rateLimiter.Stop()
+
+	fx, err := currency.NewConverter(cfg.Currency, metrics)
+	if err != nil {
+		return err
+	}
+	defer fx.Stop()
+
+	matcher, err := matching.New(cfg.Aggregator.Matching, metrics)
+	if err != nil {
+		return err
+	}
+
+	aggregator := search.NewAggregator(provs, cache, fx, matcher, cfg.Aggregator, metrics)
+
+	if cfg.Warmer.Enabled {
+		warmer, err := search.NewWarmer(aggregator, cfg.Warmer, metrics)
+		if err != nil {
+			return err
+		}
+		defer warmer.Stop()
+	}
+
+	handler := httpapi.NewHandler(aggregator, rateLimiter, cfg.Server, metrics)
+
+	var admin *httpapi.AdminHandler
+	if cfg.Admin.Enabled {
+		admin = httpapi.NewAdminHandler(cache, aggregator, cfg.Admin.Token)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           httpapi.NewRouter(handler, admin),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err, ok := <-errCh:
+		if ok {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, draining in-flight requests")
+
+	// Shutdown stops accepting connections and waits for in-flight searches to finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	// Let late provider answers from partial searches finish filling the cache
+	if err := aggregator.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	slog.Info("server stopped")
+	return nil
+}
+
+// newResultCache builds the configured cache backend
+func newResultCache(cfg config.Config, metrics *obs.Metrics) (search.ResultCache, error) {
+	if cfg.Cache.Backend == "redis" {
+		return search.NewRedisCache(cfg.Cache, cfg.Redis, metrics)
+	}
+	return search.NewCache(cfg.Cache, metrics)
+}
+
+// newRateLimiter builds the configured rate limit backend
+func newRateLimiter(cfg config.Config, metrics *obs.Metrics) (search.Limiter, error) {
+	if cfg.RateLimit.Backend == "redis" {
+		return search.NewRedisLimiter(cfg.RateLimit, cfg.Redis, metrics)
+	}
+	return search.NewRateLimiter(cfg.RateLimit, metrics)
 }
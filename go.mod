@@ -2,6 +2,8 @@ module hostaggr
 
 go 1.25.0
 
-require golang.org/x/sync v0.18.0
-
-require github.com/go-chi/chi/v5 v5.2.3 // indirect
+require (
+	github.com/go-chi/chi/v5 v5.2.3
+	golang.org/x/text v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
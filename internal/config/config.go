@@ -0,0 +1,574 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Config holds every tunable of the service
+type Config struct {
+	Server     ServerConfig     `yaml:"server"`
+	Aggregator AggregatorConfig `yaml:"aggregator"`
+	Cache      CacheConfig      `yaml:"cache"`
+	RateLimit  RateLimitConfig  `yaml:"rate_limit"`
+	Redis      RedisConfig      `yaml:"redis"`
+	Admin      AdminConfig      `yaml:"admin"`
+	Warmer     WarmerConfig     `yaml:"warmer"`
+	Currency   CurrencyConfig   `yaml:"currency"`
+}
+
+// ServerConfig configures the HTTP server and handlers
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	RequestTimeout    time.Duration `yaml:"request_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+
+	// TrustedProxies lists the CIDRs or addresses of the proxies whose X-Forwarded-For,
+	// X-Real-IP and Forwarded headers are believed. With none, the peer address is the client
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// AggregatorConfig configures the provider fan-out
+type AggregatorConfig struct {
+	ProviderTimeout time.Duration `yaml:"provider_timeout"`
+	Breaker         BreakerConfig `yaml:"breaker"`
+	Retry           RetryConfig   `yaml:"retry"`
+	Hedge           HedgeConfig   `yaml:"hedge"`
+
+	// CityAliases maps alternative city spellings to the canonical one, e.g.
+	// marrakesh -> marrakech. Both sides are normalized before use
+	CityAliases map[string]string `yaml:"city_aliases"`
+
+	Matching MatchingConfig `yaml:"matching"`
+}
+
+// MatchingConfig configures how offers from different providers are recognised as
+// the same property. Overrides win over the mapping file, which wins over fuzzy
+// matching on name, city and location
+type MatchingConfig struct {
+	// MappingFile is a YAML or JSON file of canonical properties and the ID each
+	// provider knows them by. Empty relies on fuzzy matching alone
+	MappingFile string `yaml:"mapping_file"`
+
+	// Overrides maps provider:hotel_id to a property ID, or to "-" to keep the
+	// offer apart from every other
+	Overrides map[string]string `yaml:"overrides"`
+
+	// Similarity of names is "jaro_winkler" or "trigram". Offers whose confidence
+	// reaches Threshold are matched
+	Similarity string  `yaml:"similarity"`
+	Threshold  float64 `yaml:"threshold"`
+
+	// MaxDistance in meters between two located offers of the same property. Zero
+	// ignores locations
+	MaxDistance float64 `yaml:"max_distance"`
+}
+
+// BreakerConfig configures the per-provider circuit breaker
+type BreakerConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	ConsecutiveFailures int           `yaml:"consecutive_failures"`
+	FailureRatio        float64       `yaml:"failure_ratio"`
+	MinRequests         int           `yaml:"min_requests"`
+	WindowSize          int           `yaml:"window_size"`
+	CoolDown            time.Duration `yaml:"cool_down"`
+	HalfOpenProbes      int           `yaml:"half_open_probes"`
+}
+
+// RetryConfig configures retries of transient provider errors
+type RetryConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	MaxAttempts int           `yaml:"max_attempts"` // including the first call
+	BaseDelay   time.Duration `yaml:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"`
+
+	// The retry budget earns BudgetRatio retries per first attempt plus
+	// BudgetMinPerSecond retries per second, so retries cannot amplify load
+	BudgetRatio        float64 `yaml:"budget_ratio"`
+	BudgetMinPerSecond float64 `yaml:"budget_min_per_second"`
+}
+
+// HedgeConfig configures hedged provider requests. When a provider has not answered
+// by its observed Quantile latency, a second identical call is fired
+type HedgeConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	Quantile     float64       `yaml:"quantile"`
+	MinDelay     time.Duration `yaml:"min_delay"`
+	MinSamples   int           `yaml:"min_samples"`
+	WindowSize   int           `yaml:"window_size"`
+	MaxPerSecond float64       `yaml:"max_per_second"`
+}
+
+// CacheConfig configures the search result cache
+type CacheConfig struct {
+	TTL             time.Duration `yaml:"ttl"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+
+	// ProviderTTL is how long one provider's raw results are reused, unless the
+	// provider declares its own freshness
+	ProviderTTL time.Duration `yaml:"provider_ttl"`
+
+	// StaleWhileRevalidate is how long past the TTL an entry is still served
+	// while a background refresh runs
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate"`
+	// StaleIfError is how long past the TTL an entry may be served when every
+	// provider fails. Stale entries are already served within StaleWhileRevalidate,
+	// so it must not be shorter
+	StaleIfError time.Duration `yaml:"stale_if_error"`
+
+	// Bounds and eviction. Policy is one of "lru", "lfu" or "tinylfu"
+	MaxEntries int    `yaml:"max_entries"`
+	MaxBytes   int64  `yaml:"max_bytes"`
+	Policy     string `yaml:"policy"`
+
+	// Backend is "memory" or "redis". The redis backend shares results across
+	// replicas and falls back to a local memory cache while Redis is unreachable
+	Backend   string `yaml:"backend"`
+	KeyPrefix string `yaml:"key_prefix"`
+}
+
+// WarmerConfig configures cache warming from a seed file of JSON lines and from the
+// most popular live searches
+type WarmerConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	SeedFile string        `yaml:"seed_file"`
+	Interval time.Duration `yaml:"interval"`
+
+	// TopN popular searches are warmed each cycle, out of Tracked distinct searches counted
+	TopN    int `yaml:"top_n"`
+	Tracked int `yaml:"tracked"`
+
+	// Warm searches in flight at once, and started per second, so warming stays
+	// within the providers' rate budget
+	Concurrency  int     `yaml:"concurrency"`
+	MaxPerSecond float64 `yaml:"max_per_second"`
+}
+
+// CurrencyConfig configures currency conversion. Every offer is converted into the
+// requested currency, or Default, before offers are compared
+type CurrencyConfig struct {
+	Default string `yaml:"default"`
+
+	// Source of exchange rates: "static" serves Rates against Base, "file" reloads
+	// a JSON rates file and "http" fetches a JSON rates document from URL. The file
+	// and http sources refresh every RefreshInterval
+	Source          string             `yaml:"source"`
+	Base            string             `yaml:"base"`
+	Rates           map[string]float64 `yaml:"rates"` // units of the currency per unit of Base
+	File            string             `yaml:"file"`
+	URL             string             `yaml:"url"`
+	RefreshInterval time.Duration      `yaml:"refresh_interval"`
+	Timeout         time.Duration      `yaml:"timeout"` // of one http fetch
+}
+
+// RateLimitConfig configures the per-client rate limits. Requests without an API
+// key get DefaultTier; requests with one get the tier APIKeys maps it to
+type RateLimitConfig struct {
+	// Algorithm is "token_bucket", "sliding_window", "sliding_log" or "gcra". The
+	// bucket algorithms use a tier's Burst; the window algorithms only its Requests per Window
+	Algorithm string `yaml:"algorithm"`
+
+	// KeyBy is "ip", "api_key" or "api_key_ip". Anonymous requests are always keyed by IP
+	KeyBy       string                   `yaml:"key_by"`
+	DefaultTier string                   `yaml:"default_tier"`
+	Tiers       map[string]RateLimitTier `yaml:"tiers"`
+	APIKeys     map[string]string        `yaml:"api_keys"` // API key -> tier name
+
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+
+	// Backend is "memory" or "redis". The redis backend shares each client's
+	// allowance across replicas, requires the gcra algorithm and falls back to
+	// limiting in memory while Redis is unreachable
+	Backend   string `yaml:"backend"`
+	KeyPrefix string `yaml:"key_prefix"`
+
+	// IPv6Prefix groups IPv6 clients keyed by IP into networks of this many bits, e.g.
+	// 64 so that a host cannot dodge its limit by rotating through its /64. Zero keys
+	// every address on its own
+	IPv6Prefix int `yaml:"ipv6_prefix"`
+}
+
+// RateLimitTier is one named set of limits: a bucket of Burst tokens refilled at
+// Requests per Window
+type RateLimitTier struct {
+	Burst    int           `yaml:"burst"`
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+}
+
+// RedisConfig configures the connection to a server speaking the Redis protocol
+type RedisConfig struct {
+	Addr        string        `yaml:"addr"`
+	Password    string        `yaml:"password"`
+	DB          int           `yaml:"db"`
+	DialTimeout time.Duration `yaml:"dial_timeout"`
+	IOTimeout   time.Duration `yaml:"io_timeout"`
+	PoolSize    int           `yaml:"pool_size"`
+
+	// RetryInterval is how long to use the local fallback after Redis fails
+	// before trying it again
+	RetryInterval time.Duration `yaml:"retry_interval"`
+}
+
+// AdminConfig configures the operator endpoints under /admin. They are only mounted
+// when enabled, and every call must present Token as a bearer token
+type AdminConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"`
+}
+
+// Default returns the built-in configuration
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			RequestTimeout:    5 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			ShutdownTimeout:   10 * time.Second,
+		},
+		Aggregator: AggregatorConfig{
+			ProviderTimeout: 2 * time.Second,
+			CityAliases: map[string]string{
+				"marrakesh": "marrakech",
+			},
+			Matching: MatchingConfig{
+				Similarity:  "jaro_winkler",
+				Threshold:   0.9,
+				MaxDistance: 250,
+			},
+			Breaker: BreakerConfig{
+				Enabled:             true,
+				ConsecutiveFailures: 5,
+				FailureRatio:        0.5,
+				MinRequests:         10,
+				WindowSize:          20,
+				CoolDown:            10 * time.Second,
+				HalfOpenProbes:      1,
+			},
+			Retry: RetryConfig{
+				Enabled:            true,
+				MaxAttempts:        3,
+				BaseDelay:          50 * time.Millisecond,
+				MaxDelay:           400 * time.Millisecond,
+				BudgetRatio:        0.2,
+				BudgetMinPerSecond: 1,
+			},
+			Hedge: HedgeConfig{
+				Enabled:      false,
+				Quantile:     0.95,
+				MinDelay:     20 * time.Millisecond,
+				MinSamples:   20,
+				WindowSize:   200,
+				MaxPerSecond: 10,
+			},
+		},
+		Cache: CacheConfig{
+			TTL:                  30 * time.Second,
+			CleanupInterval:      60 * time.Second,
+			ProviderTTL:          30 * time.Second,
+			StaleWhileRevalidate: 30 * time.Second,
+			StaleIfError:         5 * time.Minute,
+			MaxEntries:           10000,
+			MaxBytes:             64 << 20,
+			Policy:               "tinylfu",
+			Backend:              "memory",
+			KeyPrefix:            "hostaggr:search:",
+		},
+		RateLimit: RateLimitConfig{
+			Algorithm:   "token_bucket",
+			KeyBy:       "api_key",
+			DefaultTier: "free",
+			Tiers: map[string]RateLimitTier{
+				"free":     {Burst: 10, Requests: 10, Window: time.Minute},
+				"partner":  {Burst: 60, Requests: 600, Window: time.Minute},
+				"internal": {Burst: 200, Requests: 6000, Window: time.Minute},
+			},
+			CleanupInterval: 5 * time.Minute,
+			IdleTimeout:     10 * time.Minute,
+			Backend:         "memory",
+			KeyPrefix:       "hostaggr:ratelimit:",
+		},
+		Warmer: WarmerConfig{
+			Enabled:      false,
+			Interval:     20 * time.Second,
+			TopN:         20,
+			Tracked:      1000,
+			Concurrency:  4,
+			MaxPerSecond: 2,
+		},
+		Currency: CurrencyConfig{
+			Default: "EUR",
+			Source:  "static",
+			Base:    "EUR",
+			Rates: map[string]float64{
+				"USD": 1.08,
+				"GBP": 0.85,
+				"CHF": 0.95,
+				"MAD": 10.8,
+				"JPY": 162,
+			},
+			RefreshInterval: time.Hour,
+			Timeout:         5 * time.Second,
+		},
+		Redis: RedisConfig{
+			Addr:          "localhost:6379",
+			DialTimeout:   500 * time.Millisecond,
+			IOTimeout:     200 * time.Millisecond,
+			PoolSize:      16,
+			RetryInterval: 5 * time.Second,
+		},
+	}
+}
+
+// Validate checks that every setting is usable and reports all problems at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	errs = appendPositive(errs, "server.request_timeout", c.Server.RequestTimeout)
+	errs = appendPositive(errs, "server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	errs = appendPositive(errs, "server.shutdown_timeout", c.Server.ShutdownTimeout)
+	for _, p := range c.Server.TrustedProxies {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				errs = append(errs, fmt.Errorf("server.trusted_proxies must hold CIDRs or IP addresses, got %q", p))
+			}
+		}
+	}
+
+	errs = appendPositive(errs, "aggregator.provider_timeout", c.Aggregator.ProviderTimeout)
+	if c.Aggregator.ProviderTimeout > c.Server.RequestTimeout {
+		errs = append(errs, errors.New("aggregator.provider_timeout must not exceed server.request_timeout"))
+	}
+
+	for alias, city := range c.Aggregator.CityAliases {
+		if strings.TrimSpace(alias) == "" || strings.TrimSpace(city) == "" {
+			errs = append(errs, fmt.Errorf("aggregator.city_aliases must not contain empty names, got %q -> %q", alias, city))
+		}
+	}
+
+	m := c.Aggregator.Matching
+	switch m.Similarity {
+	case "jaro_winkler", "trigram":
+	default:
+		errs = append(errs, fmt.Errorf("aggregator.matching.similarity must be jaro_winkler or trigram, got %q", m.Similarity))
+	}
+	if m.Threshold <= 0 || m.Threshold > 1 {
+		errs = append(errs, fmt.Errorf("aggregator.matching.threshold must be in (0, 1], got %g", m.Threshold))
+	}
+	if m.MaxDistance < 0 {
+		errs = append(errs, errors.New("aggregator.matching.max_distance must not be negative"))
+	}
+	for key, property := range m.Overrides {
+		provider, hotelID, ok := strings.Cut(key, ":")
+		if !ok || provider == "" || hotelID == "" || property == "" {
+			errs = append(errs, fmt.Errorf("aggregator.matching.overrides must map provider:hotel_id to a property id or -, got %q -> %q", key, property))
+		}
+	}
+
+	if b := c.Aggregator.Breaker; b.Enabled {
+		if b.ConsecutiveFailures <= 0 {
+			errs = append(errs, errors.New("aggregator.breaker.consecutive_failures must be a positive integer"))
+		}
+		if b.FailureRatio <= 0 || b.FailureRatio > 1 {
+			errs = append(errs, fmt.Errorf("aggregator.breaker.failure_ratio must be in (0, 1], got %g", b.FailureRatio))
+		}
+		if b.MinRequests <= 0 || b.WindowSize < b.MinRequests {
+			errs = append(errs, errors.New("aggregator.breaker.window_size must be at least aggregator.breaker.min_requests, both positive"))
+		}
+		errs = appendPositive(errs, "aggregator.breaker.cool_down", b.CoolDown)
+		if b.HalfOpenProbes <= 0 {
+			errs = append(errs, errors.New("aggregator.breaker.half_open_probes must be a positive integer"))
+		}
+	}
+
+	if r := c.Aggregator.Retry; r.Enabled {
+		if r.MaxAttempts <= 0 {
+			errs = append(errs, errors.New("aggregator.retry.max_attempts must be a positive integer"))
+		}
+		errs = appendPositive(errs, "aggregator.retry.base_delay", r.BaseDelay)
+		if r.MaxDelay < r.BaseDelay {
+			errs = append(errs, errors.New("aggregator.retry.max_delay must not be less than aggregator.retry.base_delay"))
+		}
+		if r.BudgetRatio < 0 || r.BudgetMinPerSecond < 0 {
+			errs = append(errs, errors.New("aggregator.retry budget settings must not be negative"))
+		}
+	}
+
+	if h := c.Aggregator.Hedge; h.Enabled {
+		if h.Quantile <= 0 || h.Quantile >= 1 {
+			errs = append(errs, fmt.Errorf("aggregator.hedge.quantile must be in (0, 1), got %g", h.Quantile))
+		}
+		if h.MinDelay < 0 {
+			errs = append(errs, errors.New("aggregator.hedge.min_delay must not be negative"))
+		}
+		if h.MinSamples <= 0 || h.WindowSize < h.MinSamples {
+			errs = append(errs, errors.New("aggregator.hedge.window_size must be at least aggregator.hedge.min_samples, both positive"))
+		}
+		if h.MaxPerSecond <= 0 {
+			errs = append(errs, errors.New("aggregator.hedge.max_per_second must be positive"))
+		}
+	}
+
+	errs = appendPositive(errs, "cache.ttl", c.Cache.TTL)
+	errs = appendPositive(errs, "cache.cleanup_interval", c.Cache.CleanupInterval)
+	errs = appendPositive(errs, "cache.provider_ttl", c.Cache.ProviderTTL)
+	if c.Cache.StaleWhileRevalidate < 0 || c.Cache.StaleIfError < 0 {
+		errs = append(errs, errors.New("cache.stale_while_revalidate and cache.stale_if_error must not be negative"))
+	}
+	if c.Cache.StaleIfError < c.Cache.StaleWhileRevalidate {
+		errs = append(errs, fmt.Errorf("cache.stale_if_error (%s) must not be shorter than cache.stale_while_revalidate (%s)", c.Cache.StaleIfError, c.Cache.StaleWhileRevalidate))
+	}
+	if c.Cache.MaxEntries <= 0 || c.Cache.MaxBytes <= 0 {
+		errs = append(errs, errors.New("cache.max_entries and cache.max_bytes must be positive"))
+	}
+	switch c.Cache.Policy {
+	case "lru", "lfu", "tinylfu":
+	default:
+		errs = append(errs, fmt.Errorf("cache.policy must be lru, lfu or tinylfu, got %q", c.Cache.Policy))
+	}
+	switch c.Cache.Backend {
+	case "memory", "redis":
+	default:
+		errs = append(errs, fmt.Errorf("cache.backend must be memory or redis, got %q", c.Cache.Backend))
+	}
+
+	switch c.RateLimit.Algorithm {
+	case "token_bucket", "sliding_window", "sliding_log", "gcra":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.algorithm must be token_bucket, sliding_window, sliding_log or gcra, got %q", c.RateLimit.Algorithm))
+	}
+	switch c.RateLimit.KeyBy {
+	case "ip", "api_key", "api_key_ip":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.key_by must be ip, api_key or api_key_ip, got %q", c.RateLimit.KeyBy))
+	}
+	if _, ok := c.RateLimit.Tiers[c.RateLimit.DefaultTier]; !ok {
+		errs = append(errs, fmt.Errorf("rate_limit.default_tier %q is not a configured tier", c.RateLimit.DefaultTier))
+	}
+	for name, t := range c.RateLimit.Tiers {
+		if t.Burst <= 0 || t.Requests <= 0 {
+			errs = append(errs, fmt.Errorf("rate_limit.tiers.%s.burst and requests must be positive integers", name))
+		}
+		errs = appendPositive(errs, "rate_limit.tiers."+name+".window", t.Window)
+		if c.RateLimit.IdleTimeout < t.Window {
+			errs = append(errs, fmt.Errorf("rate_limit.idle_timeout must not be shorter than rate_limit.tiers.%s.window", name))
+		}
+	}
+	for key, tier := range c.RateLimit.APIKeys {
+		if _, ok := c.RateLimit.Tiers[tier]; !ok || key == "" {
+			errs = append(errs, fmt.Errorf("rate_limit.api_keys maps a key to unknown tier %q", tier))
+		}
+	}
+	errs = appendPositive(errs, "rate_limit.cleanup_interval", c.RateLimit.CleanupInterval)
+	errs = appendPositive(errs, "rate_limit.idle_timeout", c.RateLimit.IdleTimeout)
+	if c.RateLimit.IPv6Prefix < 0 || c.RateLimit.IPv6Prefix > 128 {
+		errs = append(errs, fmt.Errorf("rate_limit.ipv6_prefix must be between 0 and 128, got %d", c.RateLimit.IPv6Prefix))
+	}
+	switch c.RateLimit.Backend {
+	case "memory":
+	case "redis":
+		if c.RateLimit.Algorithm != "gcra" {
+			errs = append(errs, fmt.Errorf("rate_limit.backend redis requires the gcra algorithm, got %q", c.RateLimit.Algorithm))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.backend must be memory or redis, got %q", c.RateLimit.Backend))
+	}
+
+	if !isCurrencyCode(c.Currency.Default) {
+		errs = append(errs, fmt.Errorf("currency.default must be an ISO 4217 code, got %q", c.Currency.Default))
+	}
+	switch c.Currency.Source {
+	case "static":
+		if !isCurrencyCode(c.Currency.Base) || len(c.Currency.Rates) == 0 {
+			errs = append(errs, errors.New("currency.source static needs an ISO 4217 currency.base and currency.rates"))
+		}
+		for code, rate := range c.Currency.Rates {
+			if !isCurrencyCode(code) || rate <= 0 {
+				errs = append(errs, fmt.Errorf("currency.rates must map ISO 4217 codes to positive rates, got %s: %g", code, rate))
+			}
+		}
+	case "file":
+		if c.Currency.File == "" {
+			errs = append(errs, errors.New("currency.source file needs currency.file"))
+		}
+		errs = appendPositive(errs, "currency.refresh_interval", c.Currency.RefreshInterval)
+	case "http":
+		if c.Currency.URL == "" {
+			errs = append(errs, errors.New("currency.source http needs currency.url"))
+		}
+		errs = appendPositive(errs, "currency.refresh_interval", c.Currency.RefreshInterval)
+		errs = appendPositive(errs, "currency.timeout", c.Currency.Timeout)
+	default:
+		errs = append(errs, fmt.Errorf("currency.source must be static, file or http, got %q", c.Currency.Source))
+	}
+
+	// Redis settings matter only to the components that use it
+	if c.Cache.Backend == "redis" || c.RateLimit.Backend == "redis" {
+		errs = c.Redis.validate(errs)
+	}
+
+	if w := c.Warmer; w.Enabled {
+		errs = appendPositive(errs, "warmer.interval", w.Interval)
+		if w.TopN < 0 || w.Tracked < w.TopN {
+			errs = append(errs, errors.New("warmer.tracked must be at least warmer.top_n, both not negative"))
+		}
+		if w.SeedFile == "" && w.TopN == 0 {
+			errs = append(errs, errors.New("warmer needs a warmer.seed_file or a positive warmer.top_n"))
+		}
+		if w.Concurrency <= 0 {
+			errs = append(errs, errors.New("warmer.concurrency must be a positive integer"))
+		}
+		if w.MaxPerSecond <= 0 {
+			errs = append(errs, errors.New("warmer.max_per_second must be positive"))
+		}
+	}
+
+	if c.Admin.Enabled && len(c.Admin.Token) < 16 {
+		errs = append(errs, errors.New("admin.token must be at least 16 characters when admin.enabled is set"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// isCurrencyCode reports whether s has the form of an ISO 4217 code. Whether the
+// currency is supported is checked by the currency package
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the Redis settings, which only matter when a component uses Redis
+func (r RedisConfig) validate(errs []error) []error {
+	if r.Addr == "" {
+		errs = append(errs, errors.New("redis.addr must not be empty"))
+	}
+	if r.DB < 0 || r.PoolSize <= 0 {
+		errs = append(errs, errors.New("redis.db must not be negative and redis.pool_size must be positive"))
+	}
+	errs = appendPositive(errs, "redis.dial_timeout", r.DialTimeout)
+	errs = appendPositive(errs, "redis.io_timeout", r.IOTimeout)
+	errs = appendPositive(errs, "redis.retry_interval", r.RetryInterval)
+	return errs
+}
+
+func appendPositive(errs []error, key string, d time.Duration) []error {
+	if d <= 0 {
+		return append(errs, fmt.Errorf("%s must be a positive duration, got %s", key, d))
+	}
+	return errs
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateRejectsStaleIfErrorShorterThanStaleWhileRevalidate(t *testing.T) {
+	cfg := Default()
+	cfg.Cache.StaleWhileRevalidate = time.Minute
+	cfg.Cache.StaleIfError = 30 * time.Second
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cache.stale_if_error") {
+		t.Fatalf("Validate() = %v, want a cache.stale_if_error error", err)
+	}
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment variable read by Load
+const EnvPrefix = "HOSTAGGR_"
+
+// Load builds the configuration in layers: built-in defaults, then the file named
+// by -config (or HOSTAGGR_CONFIG), then HOSTAGGR_* environment variables, then
+// command-line flags. The result is validated before it is returned
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("hostaggr", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML or JSON config file")
+	bindFlags(fs, &cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// Remember explicit flags so they can be re-applied on top of the file and environment
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	path := *configPath
+	if _, ok := explicit["config"]; !ok {
+		if v, ok := lookupEnv(EnvPrefix + "CONFIG"); ok {
+			path = v
+		}
+	}
+
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// Environment variables mirror the flag names: cache.ttl -> HOSTAGGR_CACHE_TTL
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		if v, ok := lookupEnv(EnvName(f.Name)); ok {
+			if err := f.Value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", EnvName(f.Name), err))
+			}
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return Config{}, fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// EnvName returns the environment variable that overrides the given flag name
+func EnvName(flagName string) string {
+	r := strings.NewReplacer(".", "_", "-", "_")
+	return EnvPrefix + strings.ToUpper(r.Replace(flagName))
+}
+
+// bindFlags registers one flag per setting, pointing directly at the config fields
+func bindFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Server.Addr, "server.addr", cfg.Server.Addr, "HTTP listen address")
+	fs.DurationVar(&cfg.Server.RequestTimeout, "server.request_timeout", cfg.Server.RequestTimeout, "deadline for a single search request")
+	fs.DurationVar(&cfg.Server.ReadHeaderTimeout, "server.read_header_timeout", cfg.Server.ReadHeaderTimeout, "deadline for reading request headers")
+	fs.DurationVar(&cfg.Server.ShutdownTimeout, "server.shutdown_timeout", cfg.Server.ShutdownTimeout, "time allowed to drain in-flight requests on shutdown")
+	fs.Var((*listFlag)(&cfg.Server.TrustedProxies), "server.trusted_proxies", "comma-separated CIDRs or addresses of proxies whose forwarding headers are trusted")
+
+	fs.DurationVar(&cfg.Aggregator.ProviderTimeout, "aggregator.provider_timeout", cfg.Aggregator.ProviderTimeout, "deadline for the provider fan-out")
+	fs.Var((*pairsFlag)(&cfg.Aggregator.CityAliases), "aggregator.city_aliases", "comma-separated alias=city pairs, replacing the default table")
+	fs.StringVar(&cfg.Aggregator.Matching.MappingFile, "aggregator.matching.mapping_file", cfg.Aggregator.Matching.MappingFile, "YAML or JSON file mapping provider hotel IDs to canonical properties")
+	fs.Var((*pairsFlag)(&cfg.Aggregator.Matching.Overrides), "aggregator.matching.overrides", "comma-separated provider:hotel_id=property pairs, - keeping an offer apart")
+	fs.StringVar(&cfg.Aggregator.Matching.Similarity, "aggregator.matching.similarity", cfg.Aggregator.Matching.Similarity, "hotel name similarity: jaro_winkler or trigram")
+	fs.Float64Var(&cfg.Aggregator.Matching.Threshold, "aggregator.matching.threshold", cfg.Aggregator.Matching.Threshold, "confidence from which offers are matched as one property")
+	fs.Float64Var(&cfg.Aggregator.Matching.MaxDistance, "aggregator.matching.max_distance", cfg.Aggregator.Matching.MaxDistance, "meters beyond which located offers are different properties, 0 to ignore locations")
+	fs.BoolVar(&cfg.Aggregator.Breaker.Enabled, "aggregator.breaker.enabled", cfg.Aggregator.Breaker.Enabled, "skip providers whose circuit breaker is open")
+	fs.IntVar(&cfg.Aggregator.Breaker.ConsecutiveFailures, "aggregator.breaker.consecutive_failures", cfg.Aggregator.Breaker.ConsecutiveFailures, "consecutive failures that open the breaker")
+	fs.Float64Var(&cfg.Aggregator.Breaker.FailureRatio, "aggregator.breaker.failure_ratio", cfg.Aggregator.Breaker.FailureRatio, "failure ratio over the window that opens the breaker")
+	fs.IntVar(&cfg.Aggregator.Breaker.MinRequests, "aggregator.breaker.min_requests", cfg.Aggregator.Breaker.MinRequests, "calls in the window before the failure ratio applies")
+	fs.IntVar(&cfg.Aggregator.Breaker.WindowSize, "aggregator.breaker.window_size", cfg.Aggregator.Breaker.WindowSize, "number of recent calls the failure ratio is computed over")
+	fs.DurationVar(&cfg.Aggregator.Breaker.CoolDown, "aggregator.breaker.cool_down", cfg.Aggregator.Breaker.CoolDown, "time an open breaker waits before probing")
+	fs.IntVar(&cfg.Aggregator.Breaker.HalfOpenProbes, "aggregator.breaker.half_open_probes", cfg.Aggregator.Breaker.HalfOpenProbes, "successful probes needed to close a half-open breaker")
+	fs.BoolVar(&cfg.Aggregator.Retry.Enabled, "aggregator.retry.enabled", cfg.Aggregator.Retry.Enabled, "retry transient provider errors")
+	fs.IntVar(&cfg.Aggregator.Retry.MaxAttempts, "aggregator.retry.max_attempts", cfg.Aggregator.Retry.MaxAttempts, "provider calls per search including the first")
+	fs.DurationVar(&cfg.Aggregator.Retry.BaseDelay, "aggregator.retry.base_delay", cfg.Aggregator.Retry.BaseDelay, "backoff before the first retry")
+	fs.DurationVar(&cfg.Aggregator.Retry.MaxDelay, "aggregator.retry.max_delay", cfg.Aggregator.Retry.MaxDelay, "upper bound on the backoff between retries")
+	fs.Float64Var(&cfg.Aggregator.Retry.BudgetRatio, "aggregator.retry.budget_ratio", cfg.Aggregator.Retry.BudgetRatio, "retries earned per first attempt")
+	fs.Float64Var(&cfg.Aggregator.Retry.BudgetMinPerSecond, "aggregator.retry.budget_min_per_second", cfg.Aggregator.Retry.BudgetMinPerSecond, "retries always allowed per second")
+	fs.BoolVar(&cfg.Aggregator.Hedge.Enabled, "aggregator.hedge.enabled", cfg.Aggregator.Hedge.Enabled, "fire a second provider call when the first is slower than usual")
+	fs.Float64Var(&cfg.Aggregator.Hedge.Quantile, "aggregator.hedge.quantile", cfg.Aggregator.Hedge.Quantile, "observed latency quantile after which a hedge is fired")
+	fs.DurationVar(&cfg.Aggregator.Hedge.MinDelay, "aggregator.hedge.min_delay", cfg.Aggregator.Hedge.MinDelay, "lower bound on the hedge delay")
+	fs.IntVar(&cfg.Aggregator.Hedge.MinSamples, "aggregator.hedge.min_samples", cfg.Aggregator.Hedge.MinSamples, "latency samples needed before hedging a provider")
+	fs.IntVar(&cfg.Aggregator.Hedge.WindowSize, "aggregator.hedge.window_size", cfg.Aggregator.Hedge.WindowSize, "recent latencies the quantile is computed over")
+	fs.Float64Var(&cfg.Aggregator.Hedge.MaxPerSecond, "aggregator.hedge.max_per_second", cfg.Aggregator.Hedge.MaxPerSecond, "hedged calls allowed per second across all providers")
+
+	fs.DurationVar(&cfg.Cache.TTL, "cache.ttl", cfg.Cache.TTL, "lifetime of a cached search result")
+	fs.DurationVar(&cfg.Cache.CleanupInterval, "cache.cleanup_interval", cfg.Cache.CleanupInterval, "how often expired cache entries are swept")
+	fs.DurationVar(&cfg.Cache.ProviderTTL, "cache.provider_ttl", cfg.Cache.ProviderTTL, "lifetime of one provider's cached raw results")
+	fs.DurationVar(&cfg.Cache.StaleWhileRevalidate, "cache.stale_while_revalidate", cfg.Cache.StaleWhileRevalidate, "how long past the TTL a stale entry is served while it is refreshed")
+	fs.DurationVar(&cfg.Cache.StaleIfError, "cache.stale_if_error", cfg.Cache.StaleIfError, "how long past the TTL a stale entry may replace a failed search")
+	fs.IntVar(&cfg.Cache.MaxEntries, "cache.max_entries", cfg.Cache.MaxEntries, "maximum number of cached search results")
+	fs.Int64Var(&cfg.Cache.MaxBytes, "cache.max_bytes", cfg.Cache.MaxBytes, "approximate memory budget of the cache in bytes")
+	fs.StringVar(&cfg.Cache.Policy, "cache.policy", cfg.Cache.Policy, "eviction policy: lru, lfu or tinylfu")
+	fs.StringVar(&cfg.Cache.Backend, "cache.backend", cfg.Cache.Backend, "cache backend: memory or redis")
+	fs.StringVar(&cfg.Cache.KeyPrefix, "cache.key_prefix", cfg.Cache.KeyPrefix, "prefix of cache keys stored in redis")
+
+	fs.StringVar(&cfg.RateLimit.Algorithm, "rate_limit.algorithm", cfg.RateLimit.Algorithm, "rate limiting algorithm: token_bucket, sliding_window, sliding_log or gcra")
+	fs.StringVar(&cfg.RateLimit.KeyBy, "rate_limit.key_by", cfg.RateLimit.KeyBy, "bucket clients by ip, api_key or api_key_ip")
+	fs.StringVar(&cfg.RateLimit.DefaultTier, "rate_limit.default_tier", cfg.RateLimit.DefaultTier, "tier of requests without an API key")
+	fs.Var((*tiersFlag)(&cfg.RateLimit.Tiers), "rate_limit.tiers", "comma-separated name=burst:requests:window tiers, replacing the default set")
+	fs.Var((*pairsFlag)(&cfg.RateLimit.APIKeys), "rate_limit.api_keys", "comma-separated key=tier pairs")
+	fs.DurationVar(&cfg.RateLimit.CleanupInterval, "rate_limit.cleanup_interval", cfg.RateLimit.CleanupInterval, "how often idle rate limit state is swept")
+	fs.DurationVar(&cfg.RateLimit.IdleTimeout, "rate_limit.idle_timeout", cfg.RateLimit.IdleTimeout, "idle time after which a client's rate limit state is dropped")
+	fs.StringVar(&cfg.RateLimit.Backend, "rate_limit.backend", cfg.RateLimit.Backend, "rate limit backend: memory or redis")
+	fs.StringVar(&cfg.RateLimit.KeyPrefix, "rate_limit.key_prefix", cfg.RateLimit.KeyPrefix, "prefix of rate limit keys stored in redis")
+	fs.IntVar(&cfg.RateLimit.IPv6Prefix, "rate_limit.ipv6_prefix", cfg.RateLimit.IPv6Prefix, "prefix length IPv6 clients are grouped by, 0 to key each address")
+
+	fs.BoolVar(&cfg.Warmer.Enabled, "warmer.enabled", cfg.Warmer.Enabled, "pre-populate the cache with seeded and popular searches")
+	fs.StringVar(&cfg.Warmer.SeedFile, "warmer.seed_file", cfg.Warmer.SeedFile, "JSON lines file of searches to keep warm")
+	fs.DurationVar(&cfg.Warmer.Interval, "warmer.interval", cfg.Warmer.Interval, "time between warm cycles")
+	fs.IntVar(&cfg.Warmer.TopN, "warmer.top_n", cfg.Warmer.TopN, "most popular live searches warmed each cycle")
+	fs.IntVar(&cfg.Warmer.Tracked, "warmer.tracked", cfg.Warmer.Tracked, "distinct live searches counted for popularity")
+	fs.IntVar(&cfg.Warmer.Concurrency, "warmer.concurrency", cfg.Warmer.Concurrency, "warm searches in flight at once")
+	fs.Float64Var(&cfg.Warmer.MaxPerSecond, "warmer.max_per_second", cfg.Warmer.MaxPerSecond, "warm searches started per second")
+
+	fs.StringVar(&cfg.Currency.Default, "currency.default", cfg.Currency.Default, "ISO 4217 currency of searches that do not request one")
+	fs.StringVar(&cfg.Currency.Source, "currency.source", cfg.Currency.Source, "exchange rate source: static, file or http")
+	fs.StringVar(&cfg.Currency.Base, "currency.base", cfg.Currency.Base, "base currency of the static rates")
+	fs.StringVar(&cfg.Currency.File, "currency.file", cfg.Currency.File, "JSON rates file read by the file source")
+	fs.StringVar(&cfg.Currency.URL, "currency.url", cfg.Currency.URL, "JSON rates endpoint fetched by the http source")
+	fs.DurationVar(&cfg.Currency.RefreshInterval, "currency.refresh_interval", cfg.Currency.RefreshInterval, "how often the file or http source reloads rates")
+	fs.DurationVar(&cfg.Currency.Timeout, "currency.timeout", cfg.Currency.Timeout, "timeout of one rates fetch by the http source")
+
+	fs.StringVar(&cfg.Redis.Addr, "redis.addr", cfg.Redis.Addr, "host:port of the redis server")
+	fs.StringVar(&cfg.Redis.Password, "redis.password", cfg.Redis.Password, "redis AUTH password")
+	fs.IntVar(&cfg.Redis.DB, "redis.db", cfg.Redis.DB, "redis database number")
+	fs.DurationVar(&cfg.Redis.DialTimeout, "redis.dial_timeout", cfg.Redis.DialTimeout, "timeout for connecting to redis")
+	fs.DurationVar(&cfg.Redis.IOTimeout, "redis.io_timeout", cfg.Redis.IOTimeout, "timeout for one redis command")
+	fs.IntVar(&cfg.Redis.PoolSize, "redis.pool_size", cfg.Redis.PoolSize, "idle redis connections kept for reuse")
+	fs.DurationVar(&cfg.Redis.RetryInterval, "redis.retry_interval", cfg.Redis.RetryInterval, "how long to use the local fallback before retrying an unreachable redis")
+
+	fs.BoolVar(&cfg.Admin.Enabled, "admin.enabled", cfg.Admin.Enabled, "mount the /admin endpoints")
+	fs.StringVar(&cfg.Admin.Token, "admin.token", cfg.Admin.Token, "bearer token required by the /admin endpoints")
+}
+
+// loadFile decodes a YAML or JSON file on top of cfg. JSON is valid YAML, so one decoder handles both.
+// Maps the file sets replace the defaults rather than merging into them, so a file
+// can drop a default tier or city alias
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	if hasKey(&doc, "rate_limit", "tiers") {
+		cfg.RateLimit.Tiers = nil
+	}
+	if hasKey(&doc, "aggregator", "city_aliases") {
+		cfg.Aggregator.CityAliases = nil
+	}
+	if hasKey(&doc, "currency", "rates") {
+		cfg.Currency.Rates = nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// hasKey reports whether the YAML document sets the nested key path
+func hasKey(n *yaml.Node, path ...string) bool {
+	if n.Kind == yaml.DocumentNode {
+		if len(n.Content) == 0 {
+			return false
+		}
+		n = n.Content[0]
+	}
+	for _, key := range path {
+		if n.Kind != yaml.MappingNode {
+			return false
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				next = n.Content[i+1]
+			}
+		}
+		if next == nil {
+			return false
+		}
+		n = next
+	}
+	return true
+}
+
+// pairsFlag parses a map from comma-separated key=value pairs. Setting it replaces
+// the whole map, so a flag or environment variable fully overrides the file
+type pairsFlag map[string]string
+
+func (f *pairsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(*f))
+	for k, v := range *f {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *pairsFlag) Set(s string) error {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid pair %q, want key=value", pair)
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	*f = m
+	return nil
+}
+
+// listFlag parses a comma-separated list. Setting it replaces the whole list, so a
+// flag or environment variable fully overrides the file
+type listFlag []string
+
+func (f *listFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(s string) error {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*f = list
+	return nil
+}
+
+// tiersFlag parses rate limit tiers from comma-separated name=burst:requests:window
+// entries, e.g. free=10:10:1m. Setting it replaces every tier, so a flag or
+// environment variable fully overrides the file
+type tiersFlag map[string]RateLimitTier
+
+func (f *tiersFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	tiers := make([]string, 0, len(*f))
+	for name, t := range *f {
+		tiers = append(tiers, fmt.Sprintf("%s=%d:%d:%s", name, t.Burst, t.Requests, t.Window))
+	}
+	sort.Strings(tiers)
+	return strings.Join(tiers, ",")
+}
+
+func (f *tiersFlag) Set(s string) error {
+	m := make(map[string]RateLimitTier)
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		parts := strings.Split(spec, ":")
+		if !ok || len(parts) != 3 {
+			return fmt.Errorf("invalid tier %q, want name=burst:requests:window", entry)
+		}
+		burst, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return fmt.Errorf("tier %q burst: %w", name, err)
+		}
+		requests, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("tier %q requests: %w", name, err)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(parts[2]))
+		if err != nil {
+			return fmt.Errorf("tier %q window: %w", name, err)
+		}
+		m[strings.TrimSpace(name)] = RateLimitTier{Burst: burst, Requests: requests, Window: window}
+	}
+	*f = m
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func noEnv(string) (string, bool) { return "", false }
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileReplacesDefaultMaps(t *testing.T) {
+	path := writeConfig(t, `
+aggregator:
+  city_aliases:
+    fez: fes
+rate_limit:
+  default_tier: basic
+  tiers:
+    basic: {burst: 5, requests: 5, window: 1m}
+`)
+
+	cfg, err := Load([]string{"-config", path}, noEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cfg.Aggregator.CityAliases["marrakesh"]; ok || cfg.Aggregator.CityAliases["fez"] != "fes" {
+		t.Errorf("city aliases = %v, want only fez", cfg.Aggregator.CityAliases)
+	}
+	if len(cfg.RateLimit.Tiers) != 1 {
+		t.Errorf("tiers = %v, want only basic", cfg.RateLimit.Tiers)
+	}
+}
+
+func TestLoadFileKeepsDefaultMapsItDoesNotSet(t *testing.T) {
+	path := writeConfig(t, "cache:\n  ttl: 1m\n")
+
+	cfg, err := Load([]string{"-config", path}, noEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.RateLimit.Tiers) != len(Default().RateLimit.Tiers) || cfg.Aggregator.CityAliases["marrakesh"] != "marrakech" {
+		t.Errorf("defaults lost: tiers %v, aliases %v", cfg.RateLimit.Tiers, cfg.Aggregator.CityAliases)
+	}
+}
+
+func TestTiersFromEnvironment(t *testing.T) {
+	env := map[string]string{
+		"HOSTAGGR_RATE_LIMIT_TIERS":        "free=3:30:1m,gold=50:500:1m",
+		"HOSTAGGR_RATE_LIMIT_DEFAULT_TIER": "free",
+	}
+	cfg, err := Load(nil, func(k string) (string, bool) { v, ok := env[k]; return v, ok })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]RateLimitTier{
+		"free": {Burst: 3, Requests: 30, Window: time.Minute},
+		"gold": {Burst: 50, Requests: 500, Window: time.Minute},
+	}
+	if len(cfg.RateLimit.Tiers) != len(want) {
+		t.Fatalf("tiers = %v, want %v", cfg.RateLimit.Tiers, want)
+	}
+	for name, tier := range want {
+		if cfg.RateLimit.Tiers[name] != tier {
+			t.Errorf("tier %s = %+v, want %+v", name, cfg.RateLimit.Tiers[name], tier)
+		}
+	}
+}
+
+func TestTiersFlagRejectsMalformedEntries(t *testing.T) {
+	for _, v := range []string{"free=10:10", "free", "free=a:10:1m", "free=10:10:soon"} {
+		var f tiersFlag
+		if err := f.Set(v); err == nil {
+			t.Errorf("Set(%q) succeeded", v)
+		}
+	}
+}
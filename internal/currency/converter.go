@@ -0,0 +1,53 @@
+package currency
+
+import (
+	"hostaggr/internal/config"
+	"hostaggr/internal/obs"
+)
+
+// Converter pairs a rate source with the currency searches are priced in when the
+// caller does not ask for one
+type Converter struct {
+	source FXRateSource
+	def    string
+}
+
+// NewConverter builds the configured rate source
+func NewConverter(cfg config.CurrencyConfig, metrics *obs.Metrics) (*Converter, error) {
+	def, err := Normalize(cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := NewSource(cfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Converter{source: source, def: def}, nil
+}
+
+// NewConverterWithSource uses a caller-supplied source, e.g. a stub in tests
+func NewConverterWithSource(source FXRateSource, defaultCurrency string) (*Converter, error) {
+	def, err := Normalize(defaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{source: source, def: def}, nil
+}
+
+// Default returns the currency of searches that do not request one
+func (c *Converter) Default() string {
+	return c.def
+}
+
+// Rates returns the source's latest snapshot. A nil snapshot still converts
+// amounts to their own currency
+func (c *Converter) Rates() (*Rates, error) {
+	return c.source.Rates()
+}
+
+// Stop stops the rate source
+func (c *Converter) Stop() {
+	c.source.Stop()
+}
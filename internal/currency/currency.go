@@ -0,0 +1,46 @@
+// Package currency handles amounts of ISO 4217 currencies and converts between them.
+// Money holds integer minor units (cents for EUR, yen for JPY) so that sums and
+// comparisons are exact, and conversions round once, with an explicit mode
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrUnknownCurrency is returned for a code that is not a supported ISO 4217 currency
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrNoRate is returned when the rates in effect cannot convert between two currencies
+	ErrNoRate = errors.New("no exchange rate")
+)
+
+// exponents holds the minor unit digits of the supported ISO 4217 currencies
+var exponents = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CLP": 0,
+	"CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2, "EUR": 2, "GBP": 2, "HKD": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "MAD": 2, "MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PHP": 2,
+	"PLN": 2, "QAR": 2, "RON": 2, "SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "TND": 3,
+	"TRY": 2, "TWD": 2, "UAH": 2, "USD": 2, "VND": 0, "XOF": 0, "ZAR": 2,
+}
+
+// Exponent returns the number of minor unit digits of a currency
+func Exponent(code string) (int, error) {
+	exp, ok := exponents[code]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return exp, nil
+}
+
+// Normalize returns the canonical upper-case form of a currency code, or an error
+// wrapping ErrUnknownCurrency
+func Normalize(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if _, err := Exponent(code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
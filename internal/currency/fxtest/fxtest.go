@@ -0,0 +1,76 @@
+// Package fxtest provides a local stand-in for an HTTP exchange rate endpoint, so
+// currency.HTTPSource can be exercised without network access
+package fxtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"hostaggr/internal/currency"
+)
+
+// Server serves a rates document at its URL. The document and failures can be
+// changed while it runs
+type Server struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	rates    currency.Rates
+	status   int // non-zero makes every request fail with this status
+	requests int
+}
+
+// NewServer starts a server serving rates
+func NewServer(rates currency.Rates) *Server {
+	s := &Server{rates: rates}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	rates, status := s.rates, s.status
+	s.mu.Unlock()
+
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+// URL returns the address to configure currency.HTTPSource with
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// SetRates replaces the served document
+func (s *Server) SetRates(rates currency.Rates) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rates = rates
+}
+
+// Fail makes every later request fail with status; zero serves rates again
+func (s *Server) Fail(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// Requests returns how many requests have been served, failed ones included
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.srv.Close()
+}
@@ -0,0 +1,304 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCurrencyMismatch is returned when combining or comparing amounts of different currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	// ErrInexact is returned when an amount has more decimals than its currency and
+	// the rounding mode does not allow rounding
+	ErrInexact = errors.New("amount needs rounding")
+	// ErrOutOfRange is returned for amounts that do not fit in int64 minor units
+	ErrOutOfRange = errors.New("amount out of range")
+)
+
+// RoundingMode decides how amounts between two minor units are rounded
+type RoundingMode int
+
+// Rounding modes
+const (
+	RoundHalfEven RoundingMode = iota // to the nearest, ties to the even unit (banker's rounding)
+	RoundHalfUp                       // to the nearest, ties away from zero
+	RoundHalfDown                     // to the nearest, ties toward zero
+	RoundUp                           // away from zero
+	RoundDown                         // toward zero, i.e. truncate
+	RoundCeiling                      // toward positive infinity
+	RoundFloor                        // toward negative infinity
+	RoundExact                        // no rounding: inexact amounts are an error
+)
+
+// Money is an exact amount of one currency, held in integer minor units. The zero
+// value has no currency and is only useful as "no amount"
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// New returns minor units of the currency
+func New(minor int64, code string) (Money, error) {
+	code, err := Normalize(code)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{minor: minor, currency: code}, nil
+}
+
+// Parse reads a decimal amount of the currency such as "129.90", rounding with mode
+// when it has more decimals than the currency's exponent
+func Parse(amount, code string, mode RoundingMode) (Money, error) {
+	code, err := Normalize(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	exp, _ := Exponent(code)
+	minor, err := round(r.Mul(r, pow10(exp)), mode)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %s %s: %w", amount, code, err)
+	}
+	return Money{minor: minor, currency: code}, nil
+}
+
+// MustParse is Parse of an exact amount that panics on error, for literals
+func MustParse(amount, code string) Money {
+	m, err := Parse(amount, code, RoundExact)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// FromFloat converts a binary floating-point amount, as some providers quote them.
+// The float is read as the shortest decimal that represents it, so 129.9 is
+// 129.90 rather than 129.900000000000005684...
+func FromFloat(amount float64, code string, mode RoundingMode) (Money, error) {
+	return Parse(strconv.FormatFloat(amount, 'f', -1, 64), code, mode)
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Currency returns the ISO 4217 code, empty for the zero value
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// IsZero reports whether m is the zero value, with no currency
+func (m Money) IsZero() bool {
+	return m == Money{}
+}
+
+// Sign returns -1, 0 or +1 as the amount is negative, zero or positive
+func (m Money) Sign() int {
+	switch {
+	case m.minor < 0:
+		return -1
+	case m.minor > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Neg returns the amount with the opposite sign
+func (m Money) Neg() Money {
+	m.minor = -m.minor
+	return m
+}
+
+// Add returns m + o. Both must be of the same currency
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return Money{}, err
+	}
+	sum := m.minor + o.minor
+	if (sum > m.minor) != (o.minor > 0) {
+		return Money{}, ErrOutOfRange
+	}
+	m.minor = sum
+	return m, nil
+}
+
+// Sub returns m - o. Both must be of the same currency
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(o.Neg())
+}
+
+// Mul returns m times n, e.g. a nightly price over a stay
+func (m Money) Mul(n int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.minor), big.NewInt(n))
+	if !product.IsInt64() {
+		return Money{}, ErrOutOfRange
+	}
+	m.minor = product.Int64()
+	return m, nil
+}
+
+// Div returns m divided by n, rounded with mode, e.g. a stay price per night
+func (m Money) Div(n int64, mode RoundingMode) (Money, error) {
+	if n == 0 {
+		return Money{}, errors.New("division by zero")
+	}
+	minor, err := round(big.NewRat(m.minor, n), mode)
+	if err != nil {
+		return Money{}, err
+	}
+	m.minor = minor
+	return m, nil
+}
+
+// MulRat returns m times the exact fraction r, rounded with mode, e.g. a tax rate
+func (m Money) MulRat(r *big.Rat, mode RoundingMode) (Money, error) {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(m.minor), r)
+	minor, err := round(product, mode)
+	if err != nil {
+		return Money{}, err
+	}
+	m.minor = minor
+	return m, nil
+}
+
+// Cmp returns -1, 0 or +1 as m is less than, equal to or greater than o. Both must
+// be of the same currency
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.sameCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.minor < o.minor:
+		return -1, nil
+	case m.minor > o.minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Less reports whether m is less than o. Amounts of different currencies are
+// ordered by currency code, so that sorting mixed amounts is at least deterministic
+func (m Money) Less(o Money) bool {
+	if m.currency != o.currency {
+		return m.currency < o.currency
+	}
+	return m.minor < o.minor
+}
+
+func (m Money) sameCurrency(o Money) error {
+	if m.currency != o.currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	return nil
+}
+
+// Decimal returns the amount with every digit of the currency's exponent, e.g. "129.90"
+func (m Money) Decimal() string {
+	exp, _ := Exponent(m.currency)
+
+	digits := strconv.FormatInt(m.minor, 10)
+	sign := ""
+	if m.minor < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// String returns the amount and currency, e.g. "129.90 EUR"
+func (m Money) String() string {
+	if m.IsZero() {
+		return "0"
+	}
+	return m.Decimal() + " " + m.currency
+}
+
+// Float64 returns the nearest float64, for display or metrics only
+func (m Money) Float64() float64 {
+	f, _ := strconv.ParseFloat(m.Decimal(), 64)
+	return f
+}
+
+// MarshalJSON renders the amount as a plain JSON number without trailing zeros,
+// e.g. 129.9 or 75, as float64 prices used to be. The currency is not included:
+// it travels in a sibling field
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.Decimal()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return []byte(s), nil
+}
+
+// pow10 returns 10^n as a fraction; n may be negative
+func pow10(n int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// round rounds r to an integer with mode
+func round(r *big.Rat, mode RoundingMode) (int64, error) {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+
+	if rem.Sign() != 0 {
+		sign := int64(r.Sign())
+
+		// Compare the dropped fraction with one half: 2*|rem| against the denominator
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		toHalf := half.Cmp(r.Denom())
+
+		away := false
+		switch mode {
+		case RoundHalfEven:
+			away = toHalf > 0 || (toHalf == 0 && q.Bit(0) == 1)
+		case RoundHalfUp:
+			away = toHalf >= 0
+		case RoundHalfDown:
+			away = toHalf > 0
+		case RoundUp:
+			away = true
+		case RoundDown:
+		case RoundCeiling:
+			away = sign > 0
+		case RoundFloor:
+			away = sign < 0
+		default:
+			return 0, ErrInexact
+		}
+		if away {
+			q.Add(q, big.NewInt(sign))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrOutOfRange
+	}
+	return q.Int64(), nil
+}
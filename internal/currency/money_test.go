@@ -0,0 +1,173 @@
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		amount, code string
+		mode         RoundingMode
+		minor        int64
+		want         string
+	}{
+		{"129.90", "EUR", RoundExact, 12990, "129.90 EUR"},
+		{"129.9", "eur", RoundExact, 12990, "129.90 EUR"},
+		{" 75 ", "USD", RoundExact, 7500, "75.00 USD"},
+		{"-0.5", "EUR", RoundExact, -50, "-0.50 EUR"},
+		{"0.05", "EUR", RoundExact, 5, "0.05 EUR"},
+		{"12000", "JPY", RoundExact, 12000, "12000 JPY"},
+		{"1.234", "KWD", RoundExact, 1234, "1.234 KWD"},
+		{"0.125", "EUR", RoundHalfEven, 12, "0.12 EUR"},
+		{"0.135", "EUR", RoundHalfEven, 14, "0.14 EUR"},
+		{"0.125", "EUR", RoundHalfUp, 13, "0.13 EUR"},
+		{"0.125", "EUR", RoundHalfDown, 12, "0.12 EUR"},
+		{"0.121", "EUR", RoundUp, 13, "0.13 EUR"},
+		{"0.129", "EUR", RoundDown, 12, "0.12 EUR"},
+		{"-0.121", "EUR", RoundCeiling, -12, "-0.12 EUR"},
+		{"-0.121", "EUR", RoundFloor, -13, "-0.13 EUR"},
+		{"99.5", "JPY", RoundHalfEven, 100, "100 JPY"},
+	} {
+		m, err := Parse(tc.amount, tc.code, tc.mode)
+		if err != nil {
+			t.Errorf("Parse(%q, %s) = %v", tc.amount, tc.code, err)
+			continue
+		}
+		if m.Minor() != tc.minor || m.String() != tc.want {
+			t.Errorf("Parse(%q, %s) = %s (%d minor), want %s (%d minor)", tc.amount, tc.code, m, m.Minor(), tc.want, tc.minor)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse("1.00", "XXX", RoundExact); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("unknown currency: %v, want ErrUnknownCurrency", err)
+	}
+	if _, err := Parse("1.005", "EUR", RoundExact); !errors.Is(err, ErrInexact) {
+		t.Errorf("extra decimals: %v, want ErrInexact", err)
+	}
+	if _, err := Parse("1.5", "JPY", RoundExact); !errors.Is(err, ErrInexact) {
+		t.Errorf("decimals on a currency without minor units: %v, want ErrInexact", err)
+	}
+	if _, err := Parse("1e30", "EUR", RoundExact); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("huge amount: %v, want ErrOutOfRange", err)
+	}
+	if _, err := Parse("twelve", "EUR", RoundExact); err == nil {
+		t.Error("Parse() accepted a non-numeric amount")
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	m, err := FromFloat(129.9, "EUR", RoundExact)
+	if err != nil || m.Minor() != 12990 {
+		t.Fatalf("FromFloat(129.9) = %s, %v, want 129.90 EUR", m, err)
+	}
+	m, err = FromFloat(0.1+0.2, "EUR", RoundHalfEven)
+	if err != nil || m.Minor() != 30 {
+		t.Fatalf("FromFloat(0.1+0.2) = %s, %v, want 0.30 EUR", m, err)
+	}
+}
+
+func TestExponent(t *testing.T) {
+	for code, want := range map[string]int{"EUR": 2, "JPY": 0, "KWD": 3} {
+		if got, err := Exponent(code); err != nil || got != want {
+			t.Errorf("Exponent(%s) = %d, %v, want %d", code, got, err, want)
+		}
+	}
+	if _, err := Exponent("eur"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Exponent of a non-canonical code = %v, want ErrUnknownCurrency", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := MustParse("10.05", "EUR"), MustParse("0.95", "EUR")
+
+	if sum, err := a.Add(b); err != nil || sum.String() != "11.00 EUR" {
+		t.Errorf("Add = %s, %v", sum, err)
+	}
+	if diff, err := b.Sub(a); err != nil || diff.String() != "-9.10 EUR" || diff.Sign() != -1 {
+		t.Errorf("Sub = %s, %v", diff, err)
+	}
+	if p, err := a.Mul(3); err != nil || p.String() != "30.15 EUR" {
+		t.Errorf("Mul = %s, %v", p, err)
+	}
+	if q, err := a.Div(2, RoundHalfEven); err != nil || q.String() != "5.02 EUR" { // 5.025
+		t.Errorf("Div = %s, %v", q, err)
+	}
+	if q, err := a.Div(2, RoundHalfUp); err != nil || q.String() != "5.03 EUR" {
+		t.Errorf("Div half up = %s, %v", q, err)
+	}
+	if _, err := a.Div(0, RoundHalfEven); err == nil {
+		t.Error("Div by zero succeeded")
+	}
+	if c, err := a.Cmp(b); err != nil || c != 1 {
+		t.Errorf("Cmp = %d, %v", c, err)
+	}
+	if !b.Less(a) || a.Less(b) {
+		t.Error("Less does not order amounts")
+	}
+}
+
+func TestArithmeticRejectsMixedCurrencies(t *testing.T) {
+	eur, usd := MustParse("1.00", "EUR"), MustParse("1.00", "USD")
+
+	if _, err := eur.Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := eur.Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := eur.Cmp(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp = %v, want ErrCurrencyMismatch", err)
+	}
+	// Mixed amounts still sort deterministically, by currency code
+	if !eur.Less(usd) || usd.Less(eur) {
+		t.Error("Less does not order mixed currencies by code")
+	}
+}
+
+func TestArithmeticOverflow(t *testing.T) {
+	huge, _ := New(math.MaxInt64, "EUR")
+	one, two := MustParse("0.01", "EUR"), MustParse("0.02", "EUR")
+
+	if _, err := huge.Add(one); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Add = %v, want ErrOutOfRange", err)
+	}
+	if _, err := huge.Neg().Sub(two); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Sub = %v, want ErrOutOfRange", err)
+	}
+	if _, err := huge.Mul(2); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Mul = %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, amount := range []string{"129.90", "75.00", "0.05", "-12.30"} {
+		m := MustParse(amount, "EUR")
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A plain JSON number, as float64 prices used to be
+		var f float64
+		if err := json.Unmarshal(data, &f); err != nil {
+			t.Fatalf("Marshal(%s) = %s, not a JSON number", m, data)
+		}
+
+		back, err := Parse(string(data), "EUR", RoundExact)
+		if err != nil || back != m {
+			t.Errorf("round trip of %s through %s = %s, %v", m, data, back, err)
+		}
+	}
+
+	if data, _ := json.Marshal(MustParse("129.90", "EUR")); string(data) != "129.9" {
+		t.Errorf("Marshal(129.90 EUR) = %s, want 129.9", data)
+	}
+	if data, _ := json.Marshal(MustParse("12000", "JPY")); string(data) != "12000" {
+		t.Errorf("Marshal(12000 JPY) = %s, want 12000", data)
+	}
+}
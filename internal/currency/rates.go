@@ -0,0 +1,100 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// Rates is a snapshot of exchange rates against one base currency. It is also the
+// JSON document read by the file and HTTP sources
+type Rates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"` // units of the currency per unit of Base
+	AsOf  time.Time          `json:"as_of"`
+}
+
+// validate checks the snapshot and canonicalises its codes
+func (r *Rates) validate() error {
+	base, err := Normalize(r.Base)
+	if err != nil {
+		return fmt.Errorf("base: %w", err)
+	}
+	if len(r.Rates) == 0 {
+		return errors.New("no rates")
+	}
+
+	rates := make(map[string]float64, len(r.Rates)+1)
+	for code, rate := range r.Rates {
+		c, err := Normalize(code)
+		if err != nil {
+			return err
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return fmt.Errorf("rate of %s must be a positive number, got %g", c, rate)
+		}
+		rates[c] = rate
+	}
+	rates[base] = 1
+
+	r.Base = base
+	r.Rates = rates
+	return nil
+}
+
+// rate returns the units of to per unit of from, crossing through the base
+// currency. Rates are taken as the shortest decimals that represent them, so 1.08
+// is exactly 108/100
+func (r *Rates) rate(from, to string) (*big.Rat, error) {
+	if r == nil {
+		return nil, fmt.Errorf("%w from %s to %s: no rates loaded", ErrNoRate, from, to)
+	}
+	fromRate, ok := r.Rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRate, from)
+	}
+	toRate, ok := r.Rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRate, to)
+	}
+	return new(big.Rat).Quo(decimalRat(toRate), decimalRat(fromRate)), nil
+}
+
+// decimalRat returns the shortest decimal that represents f as a fraction
+func decimalRat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return r
+}
+
+// Convert converts m into the currency to, rounding with mode. Converting to the
+// same currency needs no rates, so it also works on nil
+func (r *Rates) Convert(m Money, to string, mode RoundingMode) (Money, error) {
+	to, err := Normalize(to)
+	if err != nil {
+		return Money{}, err
+	}
+	if m.currency == to {
+		return m, nil
+	}
+	fromExp, err := Exponent(m.currency)
+	if err != nil {
+		return Money{}, err
+	}
+	toExp, _ := Exponent(to)
+
+	rate, err := r.rate(m.currency, to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	// Minor units scale by the rate and by the difference in exponents
+	converted, err := m.MulRat(rate.Mul(rate, pow10(toExp-fromExp)), mode)
+	if err != nil {
+		return Money{}, err
+	}
+	converted.currency = to
+	return converted, nil
+}
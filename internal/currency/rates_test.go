@@ -0,0 +1,88 @@
+package currency
+
+import (
+	"errors"
+	"testing"
+)
+
+func testRates(t *testing.T) *Rates {
+	t.Helper()
+	r := &Rates{Base: "eur", Rates: map[string]float64{"usd": 1.08, "JPY": 162.5, "KWD": 0.33}}
+	if err := r.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRatesConvert(t *testing.T) {
+	r := testRates(t)
+
+	for _, tc := range []struct {
+		amount, from, to string
+		mode             RoundingMode
+		want             string
+	}{
+		{"100.00", "EUR", "USD", RoundHalfEven, "108.00 USD"},
+		{"10.01", "EUR", "USD", RoundHalfEven, "10.81 USD"},  // 10.8108
+		{"100.00", "USD", "JPY", RoundHalfEven, "15046 JPY"}, // crosses the base: 15046.296...
+		{"10.00", "EUR", "KWD", RoundHalfEven, "3.300 KWD"},  // more minor digits
+		{"1.00", "EUR", "JPY", RoundHalfEven, "162 JPY"},     // 162.5, tie to even
+		{"1.00", "EUR", "JPY", RoundHalfUp, "163 JPY"},
+		{"1.00", "EUR", "JPY", RoundDown, "162 JPY"},
+		{"-1.00", "EUR", "JPY", RoundFloor, "-163 JPY"},
+		{"3.00", "EUR", "eur", RoundExact, "3.00 EUR"}, // same currency, unchanged
+	} {
+		got, err := r.Convert(MustParse(tc.amount, tc.from), tc.to, tc.mode)
+		if err != nil {
+			t.Errorf("Convert(%s %s, %s) = %v", tc.amount, tc.from, tc.to, err)
+			continue
+		}
+		if got.String() != tc.want {
+			t.Errorf("Convert(%s %s, %s) = %s, want %s", tc.amount, tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestRatesConvertExactRejectsRounding(t *testing.T) {
+	_, err := testRates(t).Convert(MustParse("1.00", "EUR"), "JPY", RoundExact)
+	if !errors.Is(err, ErrInexact) {
+		t.Fatalf("Convert() = %v, want ErrInexact", err)
+	}
+}
+
+func TestRatesConvertErrors(t *testing.T) {
+	r := testRates(t)
+	eur := MustParse("10.00", "EUR")
+
+	if _, err := r.Convert(eur, "XXX", RoundHalfEven); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("Convert to an unknown currency = %v, want ErrUnknownCurrency", err)
+	}
+	if _, err := r.Convert(eur, "GBP", RoundHalfEven); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Convert to a currency without a rate = %v, want ErrNoRate", err)
+	}
+	if _, err := r.Convert(MustParse("10.00", "GBP"), "EUR", RoundHalfEven); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Convert from a currency without a rate = %v, want ErrNoRate", err)
+	}
+
+	var none *Rates
+	if _, err := none.Convert(eur, "USD", RoundHalfEven); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Convert without rates = %v, want ErrNoRate", err)
+	}
+	if got, err := none.Convert(eur, "EUR", RoundHalfEven); err != nil || got != eur {
+		t.Errorf("Convert to the same currency without rates = %s, %v", got, err)
+	}
+}
+
+func TestRatesValidate(t *testing.T) {
+	for name, r := range map[string]Rates{
+		"unknown base":  {Base: "XXX", Rates: map[string]float64{"USD": 1.08}},
+		"no rates":      {Base: "EUR"},
+		"unknown code":  {Base: "EUR", Rates: map[string]float64{"XXX": 1}},
+		"zero rate":     {Base: "EUR", Rates: map[string]float64{"USD": 0}},
+		"negative rate": {Base: "EUR", Rates: map[string]float64{"USD": -1.08}},
+	} {
+		if err := r.validate(); err == nil {
+			t.Errorf("%s: validate() succeeded", name)
+		}
+	}
+}
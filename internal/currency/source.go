@@ -0,0 +1,269 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/obs"
+)
+
+// FXRateSource supplies the exchange rates in effect
+type FXRateSource interface {
+	// Rates returns the latest snapshot. Sources that reload in the background keep
+	// serving the last good one while reloads fail, and return an error only when
+	// they never loaded one
+	Rates() (*Rates, error)
+	// Stop releases background goroutines. It is safe to call more than once
+	Stop()
+}
+
+// Rate source names accepted by config
+const (
+	SourceStatic = "static"
+	SourceFile   = "file"
+	SourceHTTP   = "http"
+)
+
+// Reload outcomes, as reported in metrics
+const (
+	reloadUpdated   = "updated"
+	reloadUnchanged = "unchanged"
+	reloadFailed    = "error"
+)
+
+// NewSource builds the configured rate source
+func NewSource(cfg config.CurrencyConfig, metrics *obs.Metrics) (FXRateSource, error) {
+	switch cfg.Source {
+	case SourceStatic:
+		return NewStaticSource(Rates{Base: cfg.Base, Rates: cfg.Rates})
+	case SourceFile:
+		return NewFileSource(cfg.File, cfg.RefreshInterval, metrics)
+	case SourceHTTP:
+		return NewHTTPSource(cfg.URL, cfg.RefreshInterval, cfg.Timeout, metrics)
+	default:
+		return nil, fmt.Errorf("unknown rate source %q", cfg.Source)
+	}
+}
+
+// StaticSource serves a fixed table, typically from config
+type StaticSource struct {
+	rates *Rates
+}
+
+// NewStaticSource validates rates and serves them unchanged
+func NewStaticSource(rates Rates) (*StaticSource, error) {
+	if err := rates.validate(); err != nil {
+		return nil, fmt.Errorf("static rates: %w", err)
+	}
+	return &StaticSource{rates: &rates}, nil
+}
+
+// Rates returns the fixed table
+func (s *StaticSource) Rates() (*Rates, error) {
+	return s.rates, nil
+}
+
+// Stop does nothing; a static source has no background work
+func (s *StaticSource) Stop() {}
+
+// reloader keeps the last good snapshot of a source and reloads it every interval.
+// load returns nil rates and no error when the source has not changed
+type reloader struct {
+	name     string
+	load     func(ctx context.Context) (*Rates, error)
+	interval time.Duration
+	metrics  *obs.Metrics
+
+	mu    sync.RWMutex
+	rates *Rates
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// start begins reloading in the background
+func (r *reloader) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	// Start background reload goroutine
+	go r.run(ctx)
+}
+
+// run reloads once per interval until ctx is cancelled
+func (r *reloader) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r.reload(ctx)
+	}
+}
+
+// reload loads the source once, keeping the previous snapshot on failure
+func (r *reloader) reload(ctx context.Context) error {
+	rates, err := r.load(ctx)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return err
+	case err != nil:
+		r.metrics.FXReload(r.name, reloadFailed)
+		slog.Warn("exchange rate reload failed, keeping previous rates", "source", r.name, "error", err)
+		return err
+	case rates == nil:
+		r.metrics.FXReload(r.name, reloadUnchanged)
+		return nil
+	}
+
+	r.mu.Lock()
+	r.rates = rates
+	r.mu.Unlock()
+
+	r.metrics.FXReload(r.name, reloadUpdated)
+	slog.Info("exchange rates loaded", "source", r.name, "base", rates.Base, "currencies", len(rates.Rates), "as_of", rates.AsOf)
+	return nil
+}
+
+// Rates returns the last good snapshot
+func (r *reloader) Rates() (*Rates, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.rates == nil {
+		return nil, fmt.Errorf("%w: %s source has not loaded any rates yet", ErrNoRate, r.name)
+	}
+	return r.rates, nil
+}
+
+// Stop ends background reloading and waits for it to exit
+func (r *reloader) Stop() {
+	r.stopOnce.Do(func() {
+		r.cancel()
+		<-r.done
+	})
+}
+
+// decodeRates reads and validates a rates document
+func decodeRates(rd io.Reader) (*Rates, error) {
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+
+	var rates Rates
+	if err := dec.Decode(&rates); err != nil {
+		return nil, fmt.Errorf("decode rates: %w", err)
+	}
+	if err := rates.validate(); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+// FileSource serves a JSON rates file, re-reading it whenever its modification time changes
+type FileSource struct {
+	reloader
+	path    string
+	modTime time.Time // of the last good load; only touched by load
+}
+
+// NewFileSource loads the file and checks it for changes every interval. The first
+// load must succeed; later failures keep the previous rates
+func NewFileSource(path string, interval time.Duration, metrics *obs.Metrics) (*FileSource, error) {
+	s := &FileSource{path: path}
+	s.reloader = reloader{name: SourceFile, load: s.load, interval: interval, metrics: metrics}
+
+	if err := s.reload(context.Background()); err != nil {
+		return nil, fmt.Errorf("load rates file: %w", err)
+	}
+	s.start()
+
+	return s, nil
+}
+
+func (s *FileSource) load(ctx context.Context) (*Rates, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil, nil
+	}
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rates, err := decodeRates(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.modTime = info.ModTime()
+	return rates, nil
+}
+
+// HTTPSource fetches a JSON rates document from a URL every interval
+type HTTPSource struct {
+	reloader
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSource starts fetching rates from rawURL. An unreachable endpoint is not an
+// error: conversions fail until a fetch succeeds, and amounts already in the
+// requested currency are unaffected
+func NewHTTPSource(rawURL string, interval, timeout time.Duration, metrics *obs.Metrics) (*HTTPSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("rates URL must be an absolute http or https URL, got %q", rawURL)
+	}
+
+	s := &HTTPSource{
+		url:    rawURL,
+		client: &http.Client{Timeout: timeout},
+	}
+	s.reloader = reloader{name: SourceHTTP, load: s.load, interval: interval, metrics: metrics}
+
+	s.reload(context.Background())
+	s.start()
+
+	return s, nil
+}
+
+func (s *HTTPSource) load(ctx context.Context) (*Rates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rates endpoint returned %s", resp.Status)
+	}
+	return decodeRates(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,173 @@
+package currency_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hostaggr/internal/currency"
+	"hostaggr/internal/currency/fxtest"
+)
+
+// eventually polls cond until it holds or a second has passed
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// usdRate returns the source's current USD rate, or zero when it has none
+func usdRate(s currency.FXRateSource) float64 {
+	r, err := s.Rates()
+	if err != nil {
+		return 0
+	}
+	return r.Rates["USD"]
+}
+
+func TestStaticSource(t *testing.T) {
+	s, err := currency.NewStaticSource(currency.Rates{Base: "eur", Rates: map[string]float64{"usd": 1.08}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	r, err := s.Rates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Codes are canonicalised and the base converts to itself
+	if r.Base != "EUR" || r.Rates["USD"] != 1.08 || r.Rates["EUR"] != 1 {
+		t.Fatalf("Rates() = %+v", r)
+	}
+
+	if _, err := currency.NewStaticSource(currency.Rates{Base: "EUR"}); err == nil {
+		t.Fatal("NewStaticSource() accepted a table without rates")
+	}
+}
+
+func writeRates(t *testing.T, path string, rates currency.Rates, modTime time.Time) {
+	t.Helper()
+	data, err := json.Marshal(rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Set the modification time explicitly, since the file may change faster than its resolution
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSourceReloadsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeRates(t, path, currency.Rates{Base: "EUR", Rates: map[string]float64{"USD": 1.08}}, modTime)
+
+	s, err := currency.NewFileSource(path, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if got := usdRate(s); got != 1.08 {
+		t.Fatalf("USD rate %g, want 1.08", got)
+	}
+
+	writeRates(t, path, currency.Rates{Base: "EUR", Rates: map[string]float64{"USD": 1.10}}, modTime.Add(time.Minute))
+	eventually(t, "the new rates", func() bool { return usdRate(s) == 1.10 })
+}
+
+func TestFileSourceKeepsLastGoodRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeRates(t, path, currency.Rates{Base: "EUR", Rates: map[string]float64{"USD": 1.08}}, modTime)
+
+	s, err := currency.NewFileSource(path, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// A broken rewrite is rejected and the previous rates stay in effect
+	if err := os.WriteFile(path, []byte(`{"base":"EUR","rates":{"USD":-1}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, modTime.Add(time.Minute), modTime.Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if got := usdRate(s); got != 1.08 {
+		t.Fatalf("USD rate %g after a bad reload, want 1.08", got)
+	}
+
+	// A removed file is a failed reload too
+	os.Remove(path)
+	time.Sleep(50 * time.Millisecond)
+	if got := usdRate(s); got != 1.08 {
+		t.Fatalf("USD rate %g after the file was removed, want 1.08", got)
+	}
+}
+
+func TestFileSourceRequiresFirstLoad(t *testing.T) {
+	if _, err := currency.NewFileSource(filepath.Join(t.TempDir(), "missing.json"), time.Minute, nil); err == nil {
+		t.Fatal("NewFileSource() succeeded without a rates file")
+	}
+}
+
+func TestHTTPSourceReloadsAndKeepsLastGoodRates(t *testing.T) {
+	srv := fxtest.NewServer(currency.Rates{Base: "EUR", Rates: map[string]float64{"USD": 1.08}})
+	defer srv.Close()
+
+	s, err := currency.NewHTTPSource(srv.URL(), 10*time.Millisecond, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if got := usdRate(s); got != 1.08 {
+		t.Fatalf("USD rate %g, want 1.08", got)
+	}
+
+	srv.SetRates(currency.Rates{Base: "EUR", Rates: map[string]float64{"USD": 1.10}})
+	eventually(t, "the new rates", func() bool { return usdRate(s) == 1.10 })
+
+	// Failed fetches keep the last good rates
+	srv.Fail(http.StatusServiceUnavailable)
+	failedFrom := srv.Requests()
+	eventually(t, "failed fetches", func() bool { return srv.Requests() >= failedFrom+3 })
+	if got := usdRate(s); got != 1.10 {
+		t.Fatalf("USD rate %g while the endpoint fails, want 1.10", got)
+	}
+
+	srv.Fail(0)
+	srv.SetRates(currency.Rates{Base: "EUR", Rates: map[string]float64{"USD": 1.12}})
+	eventually(t, "the rates after recovery", func() bool { return usdRate(s) == 1.12 })
+}
+
+func TestHTTPSourceStartsWithoutRates(t *testing.T) {
+	srv := fxtest.NewServer(currency.Rates{})
+	defer srv.Close()
+	srv.Fail(http.StatusInternalServerError)
+
+	// An unreachable endpoint is not fatal, but there is nothing to convert with yet
+	s, err := currency.NewHTTPSource(srv.URL(), time.Minute, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if _, err := s.Rates(); !errors.Is(err, currency.ErrNoRate) {
+		t.Fatalf("Rates() = %v, want ErrNoRate", err)
+	}
+
+	if _, err := currency.NewHTTPSource("ftp://rates.example", time.Minute, time.Second, nil); err == nil {
+		t.Fatal("NewHTTPSource() accepted a non-HTTP URL")
+	}
+}
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"hostaggr/internal/search"
+)
+
+// AdminHandler serves the operator endpoints for inspecting and purging the search cache
+type AdminHandler struct {
+	cache      search.CacheAdmin
+	aggregator *search.Aggregator // normalizes filter cities the way searches are
+	token      string
+}
+
+func NewAdminHandler(cache search.CacheAdmin, agg *search.Aggregator, token string) *AdminHandler {
+	return &AdminHandler{
+		cache:      cache,
+		aggregator: agg,
+		token:      token,
+	}
+}
+
+type cacheEntriesResponse struct {
+	Entries []search.CacheEntryInfo `json:"entries"`
+	Count   int                     `json:"count"`
+}
+
+type cacheDeleteResponse struct {
+	Removed int `json:"removed"`
+}
+
+// ListCacheEntries handles GET /admin/cache/entries
+// Lists usable entries with their age and TTL, optionally filtered by city,
+// checkin_from and checkin_to
+func (h *AdminHandler) ListCacheEntries(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.parseCacheFilter(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.cache.Entries(filter)
+	if err != nil {
+		audit(r, "cache.list", "error", err, "filter", filter)
+		writeError(w, http.StatusBadGateway, "cache backend unavailable")
+		return
+	}
+
+	audit(r, "cache.list", "ok", nil, "filter", filter, "count", len(entries))
+	writeJSON(w, http.StatusOK, cacheEntriesResponse{Entries: entries, Count: len(entries)})
+}
+
+// GetCacheEntry handles GET /admin/cache/entry?key=...
+// The key is the one reported by ListCacheEntries
+func (h *AdminHandler) GetCacheEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key parameter is required")
+		return
+	}
+
+	entry, found, err := h.cache.Entry(key)
+	if err != nil {
+		audit(r, "cache.get", "error", err, "key", key)
+		writeCacheAdminError(w, err)
+		return
+	}
+	if !found {
+		audit(r, "cache.get", "not_found", nil, "key", key)
+		writeError(w, http.StatusNotFound, "cache entry not found")
+		return
+	}
+
+	audit(r, "cache.get", "ok", nil, "key", key)
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// DeleteCacheEntry handles DELETE /admin/cache/entry?key=...
+func (h *AdminHandler) DeleteCacheEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key parameter is required")
+		return
+	}
+
+	found, err := h.cache.DeleteKey(key)
+	if err != nil {
+		audit(r, "cache.delete_key", "error", err, "key", key)
+		writeCacheAdminError(w, err)
+		return
+	}
+	if !found {
+		audit(r, "cache.delete_key", "not_found", nil, "key", key)
+		writeError(w, http.StatusNotFound, "cache entry not found")
+		return
+	}
+
+	audit(r, "cache.delete_key", "ok", nil, "key", key)
+	writeJSON(w, http.StatusOK, cacheDeleteResponse{Removed: 1})
+}
+
+// DeleteCacheEntries handles DELETE /admin/cache/entries
+// At least one of city, checkin_from or checkin_to is required; use the flush
+// endpoint to remove everything
+func (h *AdminHandler) DeleteCacheEntries(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.parseCacheFilter(w, r)
+	if !ok {
+		return
+	}
+	if filter.IsZero() {
+		writeError(w, http.StatusBadRequest, "city, checkin_from or checkin_to is required; use POST /admin/cache/flush to remove everything")
+		return
+	}
+
+	removed, err := h.cache.Delete(filter)
+	if err != nil {
+		audit(r, "cache.delete", "error", err, "filter", filter, "removed", removed)
+		writeError(w, http.StatusBadGateway, "cache backend unavailable")
+		return
+	}
+
+	audit(r, "cache.delete", "ok", nil, "filter", filter, "removed", removed)
+	writeJSON(w, http.StatusOK, cacheDeleteResponse{Removed: removed})
+}
+
+// FlushCache handles POST /admin/cache/flush
+func (h *AdminHandler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.cache.Flush()
+	if err != nil {
+		audit(r, "cache.flush", "error", err, "removed", removed)
+		writeError(w, http.StatusBadGateway, "cache backend unavailable")
+		return
+	}
+
+	audit(r, "cache.flush", "ok", nil, "removed", removed)
+	writeJSON(w, http.StatusOK, cacheDeleteResponse{Removed: removed})
+}
+
+// parseCacheFilter reads the city, normalized as searches are, and the check-in
+// range parameters. On failure it writes a 400 and returns false
+func (h *AdminHandler) parseCacheFilter(w http.ResponseWriter, r *http.Request) (search.CacheFilter, bool) {
+	q := r.URL.Query()
+	filter := search.CacheFilter{
+		City:        h.aggregator.NormalizeCity(q.Get("city")),
+		CheckInFrom: q.Get("checkin_from"),
+		CheckInTo:   q.Get("checkin_to"),
+	}
+
+	if filter.CheckInFrom != "" && !isValidDateFormat(filter.CheckInFrom) {
+		writeError(w, http.StatusBadRequest, "checkin_from must be in YYYY-MM-DD format")
+		return search.CacheFilter{}, false
+	}
+	if filter.CheckInTo != "" && !isValidDateFormat(filter.CheckInTo) {
+		writeError(w, http.StatusBadRequest, "checkin_to must be in YYYY-MM-DD format")
+		return search.CacheFilter{}, false
+	}
+
+	return filter, true
+}
+
+// writeCacheAdminError maps a key lookup error: a key that does not parse is the
+// caller's mistake, anything else is the backend's
+func writeCacheAdminError(w http.ResponseWriter, err error) {
+	if errors.Is(err, search.ErrMalformedCacheKey) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadGateway, "cache backend unavailable")
+}
+
+// writeJSON writes v as a JSON body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// audit records an admin action with the caller's address, whatever the outcome
+func audit(r *http.Request, action, outcome string, err error, attrs ...any) {
+	attrs = append([]any{
+		"audit", true,
+		"action", action,
+		"outcome", outcome,
+		"remote_addr", r.RemoteAddr,
+		"user_agent", r.UserAgent(),
+	}, attrs...)
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.Info("admin action", attrs...)
+}
@@ -0,0 +1,231 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+	"hostaggr/internal/search"
+)
+
+const adminToken = "s3cret-admin-token"
+
+// newAdminServer serves the admin endpoints over a memory cache holding three
+// Marrakech entries, one of them a provider's, and one Paris entry
+func newAdminServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	cache, err := search.NewCache(config.Default().Cache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cache.Stop)
+
+	cfg := config.Default().Aggregator
+	cfg.CityAliases = map[string]string{"Marrakesh": "Marrakech"}
+	fx, err := currency.NewConverterWithSource(noRates{}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := search.NewAggregator(nil, cache, fx, nil, cfg, nil)
+
+	hotels := []models.Hotel{{HotelID: "P1", Name: "Riad Palace", Price: currency.MustParse("180.00", "EUR")}}
+	for _, s := range []struct{ city, checkin string }{
+		{"Marrakech", "2026-11-20"},
+		{"Marrakech", "2026-12-24"},
+		{"Paris", "2026-12-01"},
+	} {
+		req, err := agg.Normalize(models.SearchRequest{City: s.city, CheckIn: s.checkin, Nights: 2, Adults: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.Set(req, hotels)
+		if s.checkin == "2026-11-20" {
+			cache.SetProvider("Mock1", req, []models.ProviderHotel{{HotelID: "H123", Name: "Riad Palace"}}, 0)
+		}
+	}
+
+	srv := httptest.NewServer(NewRouter(&Handler{}, NewAdminHandler(cache, agg, adminToken)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func adminRequest(t *testing.T, srv *httptest.Server, method, path string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func listEntries(t *testing.T, srv *httptest.Server, query string) cacheEntriesResponse {
+	t.Helper()
+	resp := adminRequest(t, srv, http.MethodGet, "/admin/cache/entries"+query)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("list%s: status %d", query, resp.StatusCode)
+	}
+	var body cacheEntriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestAdminRequiresBearerToken(t *testing.T) {
+	srv := newAdminServer(t)
+
+	for name, header := range map[string]string{
+		"missing":       "",
+		"other scheme":  "Basic " + adminToken,
+		"wrong":         "Bearer not-the-token-at-all",
+		"prefix":        "Bearer " + adminToken[:len(adminToken)-1],
+		"longer":        "Bearer " + adminToken + "x",
+		"empty":         "Bearer ",
+		"without space": "Bearer" + adminToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, srv.URL+"/admin/cache/flush", nil)
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+				t.Fatalf("status %d, WWW-Authenticate %q, want a 401 challenge", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// Nothing was flushed by the rejected requests
+	if got := listEntries(t, srv, "").Count; got != 4 {
+		t.Fatalf("%d entries after rejected flushes, want 4", got)
+	}
+}
+
+func TestAdminListFiltersEntries(t *testing.T) {
+	srv := newAdminServer(t)
+
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", 4},
+		{"?city=marrakech", 3},
+		{"?city=%20MARRAKESH%20", 3}, // normalized and resolved like a search
+		{"?city=Paris", 1},
+		{"?city=Rome", 0},
+		{"?checkin_from=2026-12-01", 2},
+		{"?checkin_to=2026-11-20", 2},
+		{"?checkin_from=2026-11-21&checkin_to=2026-12-23", 1},
+		{"?city=marrakech&checkin_from=2026-12-01", 1},
+	} {
+		if got := listEntries(t, srv, tc.query); got.Count != tc.want || len(got.Entries) != tc.want {
+			t.Errorf("list%s: %d entries, want %d", tc.query, got.Count, tc.want)
+		}
+	}
+
+	// The provider tier is listed with its provider and without a currency
+	var provider *search.CacheEntryInfo
+	for _, e := range listEntries(t, srv, "?checkin_to=2026-11-20").Entries {
+		if e.Tier == "provider" {
+			provider = &e
+		}
+	}
+	if provider == nil || provider.Provider != "Mock1" || provider.Currency != "" || provider.Hotels != 1 {
+		t.Fatalf("provider entry %+v, want Mock1's raw hotels", provider)
+	}
+
+	if resp := adminRequest(t, srv, http.MethodGet, "/admin/cache/entries?checkin_from=20-11-2026"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d for a malformed date, want 400", resp.StatusCode)
+	}
+}
+
+func TestAdminGetsAndDeletesEntriesByKey(t *testing.T) {
+	srv := newAdminServer(t)
+	key := url.QueryEscape(listEntries(t, srv, "?city=paris").Entries[0].Key)
+
+	resp := adminRequest(t, srv, http.MethodGet, "/admin/cache/entry?key="+key)
+	var entry struct {
+		Key    string            `json:"key"`
+		Hotels []json.RawMessage `json:"hotels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entry); resp.StatusCode != http.StatusOK || err != nil || url.QueryEscape(entry.Key) != key || len(entry.Hotels) != 1 {
+		t.Fatalf("get: status %d, entry %+v, %v", resp.StatusCode, entry, err)
+	}
+
+	if resp := adminRequest(t, srv, http.MethodDelete, "/admin/cache/entry?key="+key); resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete: status %d", resp.StatusCode)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if resp := adminRequest(t, srv, method, "/admin/cache/entry?key="+key); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s after delete: status %d, want 404", method, resp.StatusCode)
+		}
+	}
+
+	if resp := adminRequest(t, srv, http.MethodGet, "/admin/cache/entry?key=not-a-key"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("malformed key: status %d, want 400", resp.StatusCode)
+	}
+}
+
+// captureLogs sends the default logger's JSON records to the returned buffer for
+// the rest of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestAdminDeletesByFilterAndAudits(t *testing.T) {
+	srv := newAdminServer(t)
+	logs := captureLogs(t)
+
+	resp := adminRequest(t, srv, http.MethodDelete, "/admin/cache/entries?city=Marrakesh")
+	var body cacheDeleteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); resp.StatusCode != http.StatusOK || err != nil || body.Removed != 3 {
+		t.Fatalf("delete: status %d, body %+v, %v, want 3 removed", resp.StatusCode, body, err)
+	}
+	if got := listEntries(t, srv, ""); got.Count != 1 || got.Entries[0].City != "paris" {
+		t.Fatalf("left %+v, want only the Paris entry", got.Entries)
+	}
+
+	var record struct {
+		Msg     string         `json:"msg"`
+		Audit   bool           `json:"audit"`
+		Action  string         `json:"action"`
+		Outcome string         `json:"outcome"`
+		Removed int            `json:"removed"`
+		Filter  map[string]any `json:"filter"`
+	}
+	line, _, _ := strings.Cut(logs.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		t.Fatalf("audit line %q: %v", line, err)
+	}
+	if !record.Audit || record.Action != "cache.delete" || record.Outcome != "ok" || record.Removed != 3 || record.Filter["city"] != "marrakech" {
+		t.Fatalf("audit record %+v", record)
+	}
+
+	// Deleting everything takes the flush endpoint
+	if resp := adminRequest(t, srv, http.MethodDelete, "/admin/cache/entries"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unfiltered delete: status %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPResolver finds the address of the client behind any trusted proxies.
+// Forwarding headers are only believed when the peer is a trusted proxy, since
+// anyone else can send them
+type clientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// newClientIPResolver trusts the given CIDRs and single addresses. Config validation
+// has already rejected malformed entries, so they are skipped here
+func newClientIPResolver(proxies []string) *clientIPResolver {
+	c := &clientIPResolver{}
+	for _, p := range proxies {
+		if prefix, err := netip.ParsePrefix(p); err == nil {
+			if prefix.Addr().Is4In6() {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), max(prefix.Bits()-96, 0))
+			}
+			c.trusted = append(c.trusted, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(p); err == nil {
+			addr = addr.Unmap().WithZone("")
+			c.trusted = append(c.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return c
+}
+
+// clientIP returns the client address of r. The peer is the client unless it is a
+// trusted proxy. Otherwise the hops recorded by the proxies are walked from the
+// nearest outwards and the first one that is not a trusted proxy is the client.
+// Forwarded takes precedence over X-Forwarded-For, which takes precedence over X-Real-IP
+func (c *clientIPResolver) clientIP(r *http.Request) string {
+	peer, ok := parseHost(r.RemoteAddr)
+	if !ok {
+		// Not an IP peer, e.g. a unix socket; keep whatever identifies it
+		return r.RemoteAddr
+	}
+	if !c.isTrusted(peer) {
+		return peer.String()
+	}
+
+	client := peer
+	hops := forwardedHops(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHost(hops[i])
+		if !ok {
+			// An obfuscated or unknown hop: the proxy that recorded it is as close
+			// to the client as can be trusted
+			break
+		}
+		client = addr
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy
+func (c *clientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range c.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops returns the client and proxy addresses recorded by the first
+// forwarding header present, the original client first
+func forwardedHops(h http.Header) []string {
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		return parseForwarded(values)
+	}
+
+	if values := h.Values("X-Forwarded-For"); len(values) > 0 {
+		var hops []string
+		for _, v := range values {
+			for _, hop := range strings.Split(v, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+		return hops
+	}
+
+	if v := h.Get("X-Real-IP"); v != "" {
+		return []string{strings.TrimSpace(v)}
+	}
+
+	return nil
+}
+
+// parseForwarded returns the for= node of every element of RFC 7239 Forwarded
+// headers. An element without one yields an empty hop, since its proxy did not
+// disclose the address it saw
+func parseForwarded(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+
+			hop := ""
+			for _, pair := range splitQuoted(element, ';') {
+				name, value, ok := strings.Cut(pair, "=")
+				if ok && strings.EqualFold(strings.TrimSpace(name), "for") {
+					hop = unquote(strings.TrimSpace(value))
+					break
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s at every sep outside a quoted string
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++ // skip the escaped character
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote removes the quotes and escapes of an RFC 7230 quoted string; a token is returned as is
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseHost parses an address with or without a port, IPv6 possibly in brackets.
+// IPv4-mapped IPv6 addresses are unmapped and zones dropped, so that one client
+// always has one form
+func parseHost(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = addrPort.Addr()
+	}
+
+	return addr.Unmap().WithZone(""), true
+}
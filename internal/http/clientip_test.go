@@ -0,0 +1,213 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8:ff::/48", "::ffff:172.16.0.0/108", "192.0.2.10"}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "direct client",
+			remote: "198.51.100.7:51000",
+			want:   "198.51.100.7",
+		},
+		{
+			name:    "spoofed XFF from an untrusted peer",
+			remote:  "198.51.100.7:51000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.1"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "spoofed Forwarded from an untrusted peer",
+			remote:  "198.51.100.7:51000",
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.1"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "trusted proxy",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.1"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "chain walked to the first untrusted hop",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.66, 203.0.113.1, 10.9.9.9, 192.0.2.10"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "chain over repeated headers",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.66, 203.0.113.1", "10.9.9.9"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "every hop trusted",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"10.4.4.4, 10.9.9.9"}},
+			want:    "10.4.4.4",
+		},
+		{
+			name:   "Forwarded over XFF",
+			remote: "10.1.2.3:443",
+			headers: map[string][]string{
+				"Forwarded":       {"for=203.0.113.1;proto=https"},
+				"X-Forwarded-For": {"203.0.113.99"},
+				"X-Real-Ip":       {"203.0.113.98"},
+			},
+			want: "203.0.113.1",
+		},
+		{
+			name:   "XFF over X-Real-IP",
+			remote: "10.1.2.3:443",
+			headers: map[string][]string{
+				"X-Forwarded-For": {"203.0.113.1"},
+				"X-Real-Ip":       {"203.0.113.98"},
+			},
+			want: "203.0.113.1",
+		},
+		{
+			name:    "X-Real-IP",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Real-Ip": {" 203.0.113.98 "}},
+			want:    "203.0.113.98",
+		},
+		{
+			name:    "Forwarded chain",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.66, for=203.0.113.1", "for=10.9.9.9;by=10.1.2.3"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "Forwarded quoted IPv6 with port",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {`For="[2001:db8:cafe::17]:4711"`}},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:    "Forwarded quoted value containing separators",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {`for="203.0.113.1";host="a,b;c", for=10.9.9.9`}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "Forwarded escaped quoted value",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {`for="203.0.113.\1"`}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "Forwarded unknown hop stops the walk",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.1, for=unknown, for=10.9.9.9"}},
+			want:    "10.9.9.9",
+		},
+		{
+			name:    "Forwarded obfuscated hop stops the walk",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.1, for=_hidden"}},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "Forwarded element without for",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.1, proto=https"}},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "malformed XFF falls back to the peer",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"not-an-ip"}},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "empty XFF falls back to the peer",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {""}},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "malformed Forwarded falls back to the peer",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"Forwarded": {`for="203.0.113.1`}},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "XFF with port",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.1:8080"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "XFF bracketed IPv6 without port",
+			remote:  "10.1.2.3:443",
+			headers: map[string][]string{"X-Forwarded-For": {"[2001:db8:cafe::17]"}},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:   "IPv6 peer",
+			remote: "[2001:db8:cafe::17]:51000",
+			want:   "2001:db8:cafe::17",
+		},
+		{
+			name:    "trusted IPv6 proxy",
+			remote:  "[2001:db8:ff:1::1]:443",
+			headers: map[string][]string{"X-Forwarded-For": {"2001:db8:cafe::17"}},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:   "4-in-6 peer unmapped",
+			remote: "[::ffff:198.51.100.7]:51000",
+			want:   "198.51.100.7",
+		},
+		{
+			name:    "4-in-6 peer matches an IPv4 proxy",
+			remote:  "[::ffff:10.1.2.3]:443",
+			headers: map[string][]string{"X-Forwarded-For": {"::ffff:203.0.113.1"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:    "IPv4 peer matches a 4-in-6 proxy",
+			remote:  "172.16.5.5:443",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.1"}},
+			want:    "203.0.113.1",
+		},
+		{
+			name:   "zone dropped",
+			remote: "[fe80::1%eth0]:51000",
+			want:   "fe80::1",
+		},
+		{
+			name:    "not an IP peer",
+			remote:  "@",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.1"}},
+			want:    "@",
+		},
+	}
+
+	c := newClientIPResolver(trusted)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for name, values := range tt.headers {
+				for _, v := range values {
+					r.Header.Add(name, v)
+				}
+			}
+
+			if got := c.clientIP(r); got != tt.want {
+				t.Fatalf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
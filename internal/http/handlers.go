@@ -3,24 +3,33 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"hostaggr/internal/config"
 	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
 	"hostaggr/internal/search"
 )
 
 type Handler struct {
-	aggregator  *search.Aggregator
-	rateLimiter *search.RateLimiter
+	aggregator     *search.Aggregator
+	rateLimiter    search.Limiter
+	clientIPs      *clientIPResolver
+	requestTimeout time.Duration
+	metrics        *obs.Metrics
 }
 
-func NewHandler(agg *search.Aggregator, rl *search.RateLimiter) *Handler {
+func NewHandler(agg *search.Aggregator, rl search.Limiter, cfg config.ServerConfig, metrics *obs.Metrics) *Handler {
 	return &Handler{
-		aggregator:  agg,
-		rateLimiter: rl,
+		aggregator:     agg,
+		rateLimiter:    rl,
+		clientIPs:      newClientIPResolver(cfg.TrustedProxies),
+		requestTimeout: cfg.RequestTimeout,
+		metrics:        metrics,
 	}
 }
 
@@ -29,157 +38,219 @@ type errorResponse struct {
 }
 
 type healthResponse struct {
-	Status string `json:"status"`
+	Status    string                    `json:"status"`
+	Providers map[string]providerHealth `json:"providers,omitempty"`
+}
+
+type providerHealth struct {
+	Breaker             string     `json:"breaker"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailureRatio        float64    `json:"failure_ratio"`
+	Requests            int        `json:"requests"`
+	OpenUntil           *time.Time `json:"open_until,omitempty"`
 }
 
 // SearchHotels handles GET /search requests
 func (h *Handler) SearchHotels(w http.ResponseWriter, r *http.Request) {
-	// Extract IP address
-	ip := extractIP(r)
-
 	// Check rate limit
-	if !h.rateLimiter.Allow(ip) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusTooManyRequests)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "rate limit exceeded",
-		})
+	if !h.allow(w, r) {
 		return
 	}
 
 	// Parse and validate query parameters
+	req, ok := h.parseSearchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Create context with the configured request timeout
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	// Perform search. The aggregator normalizes the request and rejects what it cannot
+	response, err := h.aggregator.Search(ctx, req)
+	if errors.Is(err, search.ErrInvalidSearch) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	// Return JSON response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// allow applies the rate limit to the client and sets the RateLimit-* headers,
+// writing a 401 for an unknown API key or a 429 and returning false when exceeded
+func (h *Handler) allow(w http.ResponseWriter, r *http.Request) bool {
+	client := search.Client{
+		IP:     h.clientIPs.clientIP(r),
+		APIKey: r.Header.Get("X-API-Key"),
+	}
+
+	d, err := h.rateLimiter.Allow(client)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return false
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+
+	if !d.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.RetryAfter), 1)))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+
+	return true
+}
+
+// ceilSeconds rounds d up to whole seconds, as the rate limit headers require
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// parseSearchRequest validates the search query parameters shared by /search and
+// /search/stream. The aggregator normalizes the request. On failure it writes a 400 and returns false
+func (h *Handler) parseSearchRequest(w http.ResponseWriter, r *http.Request) (models.SearchRequest, bool) {
 	city := r.URL.Query().Get("city")
 	if city == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "city parameter is required",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "city parameter is required")
+		return models.SearchRequest{}, false
 	}
 
 	checkin := r.URL.Query().Get("checkin")
 	if checkin == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "checkin parameter is required",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "checkin parameter is required")
+		return models.SearchRequest{}, false
 	}
 
 	// Validate checkin format (YYYY-MM-DD)
 	if !isValidDateFormat(checkin) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "checkin must be in YYYY-MM-DD format",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "checkin must be in YYYY-MM-DD format")
+		return models.SearchRequest{}, false
 	}
 
 	nightsStr := r.URL.Query().Get("nights")
 	if nightsStr == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "nights parameter is required",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "nights parameter is required")
+		return models.SearchRequest{}, false
 	}
 
 	nights, err := strconv.Atoi(nightsStr)
 	if err != nil || nights <= 0 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "nights must be a positive integer",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "nights must be a positive integer")
+		return models.SearchRequest{}, false
 	}
 
 	adultsStr := r.URL.Query().Get("adults")
 	if adultsStr == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "adults parameter is required",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "adults parameter is required")
+		return models.SearchRequest{}, false
 	}
 
 	adults, err := strconv.Atoi(adultsStr)
 	if err != nil || adults <= 0 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "adults must be a positive integer",
-		})
-		return
+		writeError(w, http.StatusBadRequest, "adults must be a positive integer")
+		return models.SearchRequest{}, false
 	}
 
-	// Create search request
-	req := models.SearchRequest{
-		City:    city,
-		CheckIn: checkin,
-		Nights:  nights,
-		Adults:  adults,
+	// Optional latency budget for partial results
+	var maxWait time.Duration
+	if maxWaitStr := r.URL.Query().Get("max_wait_ms"); maxWaitStr != "" {
+		maxWaitMs, err := strconv.Atoi(maxWaitStr)
+		if err != nil || maxWaitMs <= 0 {
+			writeError(w, http.StatusBadRequest, "max_wait_ms must be a positive integer")
+			return models.SearchRequest{}, false
+		}
+		maxWait = time.Duration(maxWaitMs) * time.Millisecond
 	}
 
-	// Create context with 5-second timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	// Perform search
-	response, err := h.aggregator.Search(ctx, req)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(errorResponse{
-			Error: "internal server error",
-		})
-		return
+	// Create search request. An empty currency gets the configured default and
+	// empty offers lists them all
+	req := models.SearchRequest{
+		City:     city,
+		CheckIn:  checkin,
+		Nights:   nights,
+		Adults:   adults,
+		Currency: r.URL.Query().Get("currency"),
+		Offers:   r.URL.Query().Get("offers"),
+		MaxWait:  maxWait,
 	}
 
-	// Return JSON response
+	return req, true
+}
+
+// writeError writes a JSON error body with the given status code
+func writeError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{
+		Error: message,
+	})
 }
 
 // Health handles GET /healthz requests
+// The service stays live while providers are unhealthy, so status is "degraded"
+// rather than an error code when any circuit breaker is not closed
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{
+		Status: "ok",
+	}
+
+	if snapshots := h.aggregator.ProviderHealth(); snapshots != nil {
+		response.Providers = make(map[string]providerHealth, len(snapshots))
+		for name, snap := range snapshots {
+			ph := providerHealth{
+				Breaker:             snap.State.String(),
+				ConsecutiveFailures: snap.ConsecutiveFailures,
+				FailureRatio:        snap.FailureRatio,
+				Requests:            snap.Requests,
+			}
+			if snap.State == search.BreakerOpen {
+				openUntil := snap.OpenUntil
+				ph.OpenUntil = &openUntil
+			}
+			if snap.State != search.BreakerClosed {
+				response.Status = "degraded"
+			}
+			response.Providers[name] = ph
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(healthResponse{
-		Status: "ok",
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 // Metrics handles GET /metrics requests
+// Responds in the Prometheus text format unless JSON is requested with ?format=json
+// or an Accept header that prefers application/json
 func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
-	// Placeholder for metrics - will be implemented when obs.Metrics is created
-	metrics := map[string]interface{}{
-		"requests_total":  0,
-		"cache_hits":      0,
-		"cache_misses":    0,
-		"provider_errors": 0,
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(h.metrics.Summary())
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(metrics)
+	h.metrics.WritePrometheus(w)
 }
 
-func extractIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	ip := r.RemoteAddr
-	// RemoteAddr includes port, strip it
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
 	}
-
-	return ip
+	accept := r.Header.Get("Accept")
+	return strings.HasPrefix(accept, "application/json")
 }
 
 func isValidDateFormat(date string) bool {
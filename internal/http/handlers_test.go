@@ -0,0 +1,134 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/search"
+	"hostaggr/internal/search/limitertest"
+)
+
+// newLimitedHandler returns a handler whose anonymous tier allows a burst of 3
+// refilled at one request per 10s, and whose pro tier a burst of 5 at one per second
+func newLimitedHandler(t *testing.T) (*Handler, *limitertest.Clock) {
+	t.Helper()
+	cfg := config.Default().RateLimit
+	cfg.Algorithm = search.AlgorithmTokenBucket
+	cfg.KeyBy = search.KeyByAPIKey
+	cfg.DefaultTier = "free"
+	cfg.Tiers = map[string]config.RateLimitTier{
+		"free": {Burst: 3, Requests: 6, Window: time.Minute},
+		"pro":  {Burst: 5, Requests: 60, Window: time.Minute},
+	}
+	cfg.APIKeys = map[string]string{"pro-key": "pro"}
+
+	clock := limitertest.NewClock()
+	limiter, err := search.NewRateLimiterWithClock(cfg, nil, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(limiter.Stop)
+
+	return &Handler{rateLimiter: limiter, clientIPs: newClientIPResolver(nil)}, clock
+}
+
+type limitHeaders struct {
+	status                  int
+	limit, remaining, reset int
+	retryAfter              int // -1 when absent
+}
+
+func callAllow(t *testing.T, h *Handler, ip, apiKey string) limitHeaders {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/search", nil)
+	r.RemoteAddr = ip + ":40000"
+	if apiKey != "" {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+
+	allowed := h.allow(w, r)
+	if allowed != (w.Code == http.StatusOK) {
+		t.Fatalf("allow() = %t with status %d", allowed, w.Code)
+	}
+
+	header := func(name string) int {
+		v := w.Header().Get(name)
+		if v == "" {
+			return -1
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			t.Fatalf("%s: %q is not an integer", name, v)
+		}
+		return n
+	}
+	return limitHeaders{
+		status:     w.Code,
+		limit:      header("RateLimit-Limit"),
+		remaining:  header("RateLimit-Remaining"),
+		reset:      header("RateLimit-Reset"),
+		retryAfter: header("Retry-After"),
+	}
+}
+
+func TestAllowSetsRateLimitHeaders(t *testing.T) {
+	h, clock := newLimitedHandler(t)
+
+	steps := []struct {
+		advance time.Duration
+		want    limitHeaders
+	}{
+		// Each request spent takes 10s to earn back
+		{0, limitHeaders{http.StatusOK, 3, 2, 10, -1}},
+		{0, limitHeaders{http.StatusOK, 3, 1, 20, -1}},
+		{0, limitHeaders{http.StatusOK, 3, 0, 30, -1}},
+		{0, limitHeaders{http.StatusTooManyRequests, 3, 0, 30, 10}},
+		// Half a token earned: Retry-After counts down
+		{5 * time.Second, limitHeaders{http.StatusTooManyRequests, 3, 0, 25, 5}},
+		// Under a second to go still says 1, never 0
+		{4500 * time.Millisecond, limitHeaders{http.StatusTooManyRequests, 3, 0, 21, 1}},
+		{time.Second, limitHeaders{http.StatusOK, 3, 0, 30, -1}},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if got := callAllow(t, h, "192.0.2.1", ""); got != step.want {
+			t.Fatalf("request %d: got %+v, want %+v", i+1, got, step.want)
+		}
+	}
+
+	// Other clients have their own allowance
+	if got := callAllow(t, h, "192.0.2.2", ""); got != (limitHeaders{http.StatusOK, 3, 2, 10, -1}) {
+		t.Fatalf("another IP got %+v, want a full allowance", got)
+	}
+}
+
+func TestAllowKeysByAPIKey(t *testing.T) {
+	h, _ := newLimitedHandler(t)
+
+	// The key's tier applies, and its allowance follows the key across addresses
+	for i, ip := range []string{"192.0.2.1", "198.51.100.7", "2001:db8::1"} {
+		want := limitHeaders{http.StatusOK, 5, 4 - i, i + 1, -1}
+		if got := callAllow(t, h, ip, "pro-key"); got != want {
+			t.Fatalf("request %d from %s: got %+v, want %+v", i+1, ip, got, want)
+		}
+	}
+
+	// Anonymous requests from the same address are limited separately
+	if got := callAllow(t, h, "192.0.2.1", ""); got.limit != 3 || got.remaining != 2 {
+		t.Fatalf("anonymous request got %+v, want the free tier's full allowance", got)
+	}
+}
+
+func TestAllowRejectsUnknownAPIKey(t *testing.T) {
+	h, _ := newLimitedHandler(t)
+
+	got := callAllow(t, h, "192.0.2.1", "no-such-key")
+	if got != (limitHeaders{http.StatusUnauthorized, -1, -1, -1, -1}) {
+		t.Fatalf("got %+v, want a 401 without rate limit headers", got)
+	}
+}
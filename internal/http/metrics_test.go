@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hostaggr/internal/obs"
+)
+
+func TestMetricsNegotiatesFormat(t *testing.T) {
+	h := &Handler{metrics: obs.NewMetrics()}
+
+	for _, tc := range []struct {
+		name, query, accept string
+		json                bool
+	}{
+		{name: "default"},
+		{name: "format=json", query: "?format=json", json: true},
+		{name: "format=prometheus", query: "?format=prometheus"},
+		{name: "Accept", accept: "application/json", json: true},
+		{name: "Accept with parameters", accept: "application/json; charset=utf-8", json: true},
+		{name: "Accept text", accept: "text/plain"},
+		{name: "query wins over Accept", query: "?format=text", accept: "application/json"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics"+tc.query, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			h.Metrics(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d", w.Code)
+			}
+			ct := w.Header().Get("Content-Type")
+			if tc.json {
+				if ct != "application/json" {
+					t.Fatalf("Content-Type %q, want application/json", ct)
+				}
+				var v map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+					t.Fatalf("body is not JSON: %v", err)
+				}
+				return
+			}
+			if ct != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Fatalf("Content-Type %q, want the Prometheus text format", ct)
+			}
+			if !strings.Contains(w.Body.String(), "# TYPE ") {
+				t.Fatalf("body is not the Prometheus text format:\n%s", w.Body)
+			}
+		})
+	}
+}
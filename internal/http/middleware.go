@@ -0,0 +1,56 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"hostaggr/internal/obs"
+)
+
+// instrument records the status and latency of every request, labelled by route pattern
+func instrument(metrics *obs.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			// Use the matched pattern rather than the raw path to keep label cardinality bounded
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			metrics.ObserveHTTPRequest(route, status, time.Since(start))
+		})
+	}
+}
+
+// requireToken rejects requests that do not carry token as a bearer token. Rejections
+// are audit-logged so that probing of the admin endpoints is visible
+func requireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				audit(r, r.Method+" "+r.URL.Path, "unauthorized", nil)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="hostaggr-admin"`)
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// NewRouter mounts the handler endpoints on a chi router. The admin endpoints are
+// only mounted when admin is not nil
+func NewRouter(h *Handler, admin *AdminHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(instrument(h.metrics))
+	r.Use(middleware.Recoverer)
+
+	r.Get("/search", h.SearchHotels)
+	r.Get("/search/stream", h.SearchStream)
+	r.Get("/healthz", h.Health)
+	r.Get("/metrics", h.Metrics)
+
+	if admin != nil {
+		r.Route("/admin/cache", func(r chi.Router) {
+			r.Use(requireToken(admin.token))
+
+			r.Get("/entries", admin.ListCacheEntries)
+			r.Delete("/entries", admin.DeleteCacheEntries)
+			r.Get("/entry", admin.GetCacheEntry)
+			r.Delete("/entry", admin.DeleteCacheEntry)
+			r.Post("/flush", admin.FlushCache)
+		})
+	}
+
+	return r
+}
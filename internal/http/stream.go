@@ -0,0 +1,111 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"hostaggr/internal/models"
+	"hostaggr/internal/search"
+)
+
+// Server-Sent Event names emitted by SearchStream
+const (
+	eventHotels        = "hotels"
+	eventProviderError = "provider_error"
+	eventDone          = "done"
+)
+
+type hotelsEvent struct {
+	Provider string         `json:"provider"`
+	Hotels   []models.Hotel `json:"hotels"`
+}
+
+type providerErrorEvent struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+}
+
+// SearchStream handles GET /search/stream requests
+// It accepts the same parameters as SearchHotels and emits one "hotels" event per
+// provider answer with the running result set, one "provider_error" event per failed
+// provider and a final "done" event carrying the stats. max_wait_ms is accepted but
+// has no effect, since every result is delivered as soon as it arrives
+func (h *Handler) SearchStream(w http.ResponseWriter, r *http.Request) {
+	// Check rate limit
+	if !h.allow(w, r) {
+		return
+	}
+
+	// Parse and validate query parameters
+	req, ok := h.parseSearchRequest(w, r)
+	if !ok {
+		return
+	}
+	req.MaxWait = 0
+
+	rc := http.NewResponseController(w)
+
+	// The stream starts with the first event, so that a request the aggregator
+	// rejects still gets a 400
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	// The request context is cancelled when the client disconnects, which cancels
+	// the outstanding provider calls
+	ctx, cancel := context.WithTimeout(r.Context(), h.requestTimeout)
+	defer cancel()
+
+	emit := func(ev search.StreamEvent) error {
+		start()
+		if ev.Err != nil {
+			return writeEvent(w, rc, eventProviderError, providerErrorEvent{
+				Provider: ev.Provider,
+				Error:    ev.Err.Error(),
+			})
+		}
+		return writeEvent(w, rc, eventHotels, hotelsEvent{
+			Provider: ev.Provider,
+			Hotels:   ev.Hotels,
+		})
+	}
+
+	response, err := h.aggregator.SearchStream(ctx, req, emit)
+	if !started && errors.Is(err, search.ErrInvalidSearch) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		// The client is gone or the stream broke; there is nobody left to tell
+		return
+	}
+
+	start()
+	writeEvent(w, rc, eventDone, response.Stats)
+}
+
+// writeEvent writes one SSE event with a JSON payload and flushes it to the client
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
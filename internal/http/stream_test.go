@@ -0,0 +1,247 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+	"hostaggr/internal/providers"
+	"hostaggr/internal/search"
+)
+
+// stubProvider answers with one hotel in the searched city, or with err when set
+type stubProvider struct {
+	name string
+	err  error
+}
+
+func (p stubProvider) Name() string               { return p.name }
+func (p stubProvider) Pricing() providers.Pricing { return providers.Pricing{Basis: models.PerNight} }
+
+func (p stubProvider) Search(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []models.ProviderHotel{{
+		HotelID: "H-" + p.name,
+		Name:    p.name + " Palace",
+		City:    req.City,
+		Price:   currency.MustParse("100.00", "EUR"),
+	}}, nil
+}
+
+func failingProvider(name string) stubProvider {
+	return stubProvider{name: name, err: providers.NewError(name, providers.KindPermanent, errors.New("upstream broke"))}
+}
+
+// noRates is a rate source without rates, which still prices EUR offers in EUR
+type noRates struct{}
+
+func (noRates) Rates() (*currency.Rates, error) { return nil, nil }
+func (noRates) Stop()                           {}
+
+// staleCache holds a single search result past its TTL, usable only when every
+// provider fails
+type staleCache struct {
+	*search.Cache
+	hotels []models.Hotel
+}
+
+func (c staleCache) Get(models.SearchRequest) ([]models.Hotel, search.Freshness, bool) {
+	return c.hotels, search.StaleIfError, true
+}
+
+// newTestServer serves the handler's routes over provs, with the default rate limits
+func newTestServer(t *testing.T, cache search.ResultCache, provs ...providers.Provider) *httptest.Server {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Aggregator.Breaker.Enabled = false
+	cfg.Aggregator.Retry.Enabled = false
+	cfg.Aggregator.Hedge.Enabled = false
+
+	fx, err := currency.NewConverterWithSource(noRates{}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := search.NewAggregator(provs, cache, fx, nil, cfg.Aggregator, nil)
+
+	limiter, err := search.NewRateLimiter(cfg.RateLimit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(limiter.Stop)
+
+	h := NewHandler(agg, limiter, cfg.Server, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", h.SearchHotels)
+	mux.HandleFunc("/search/stream", h.SearchStream)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents reads a whole event stream
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var ev sseEvent
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			events = append(events, ev)
+			ev = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		default:
+			t.Fatalf("unexpected line %q in the stream", line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func getStream(t *testing.T, srv *httptest.Server, query string) *http.Response {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/search/stream?" + query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+const streamQuery = "city=Marrakech&checkin=2026-11-20&nights=2&adults=2"
+
+type streamedHotels struct {
+	Provider string `json:"provider"`
+	Hotels   []struct {
+		HotelID string `json:"hotel_id"`
+	} `json:"hotels"`
+}
+
+func TestSearchStreamFraming(t *testing.T) {
+	srv := newTestServer(t, nil, stubProvider{name: "Good"}, failingProvider("Bad"))
+	resp := getStream(t, srv, streamQuery)
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, Content-Type %q, want an event stream", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	events := readEvents(t, resp)
+	if len(events) != 3 {
+		t.Fatalf("%d events %+v, want hotels, provider_error and done", len(events), events)
+	}
+
+	// Providers answer in any order, but done is always last
+	seen := map[string]bool{}
+	for _, ev := range events[:2] {
+		seen[ev.name] = true
+		switch ev.name {
+		case eventHotels:
+			var payload streamedHotels
+			if err := json.Unmarshal([]byte(ev.data), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Provider != "Good" || len(payload.Hotels) != 1 || payload.Hotels[0].HotelID != "H-Good" {
+				t.Fatalf("hotels event %s, want Good's hotel", ev.data)
+			}
+		case eventProviderError:
+			var payload providerErrorEvent
+			if err := json.Unmarshal([]byte(ev.data), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Provider != "Bad" || payload.Error == "" {
+				t.Fatalf("provider_error event %s, want Bad's error", ev.data)
+			}
+		default:
+			t.Fatalf("unexpected %q event before done", ev.name)
+		}
+	}
+	if !seen[eventHotels] || !seen[eventProviderError] {
+		t.Fatalf("events %+v, want one hotels and one provider_error", events)
+	}
+
+	done := events[2]
+	var stats models.Stats
+	if err := json.Unmarshal([]byte(done.data), &stats); done.name != eventDone || err != nil {
+		t.Fatalf("last event %+v, want done with the stats", done)
+	}
+	if stats.ProvidersSucceeded != 1 || stats.ProvidersFailed != 1 || stats.Cache != "miss" {
+		t.Fatalf("done stats %+v", stats)
+	}
+}
+
+func TestSearchStreamRejectsInvalidSearchBeforeStreaming(t *testing.T) {
+	srv := newTestServer(t, nil, stubProvider{name: "Good"})
+
+	// The handler's own checks pass; the aggregator rejects the currency
+	resp := getStream(t, srv, streamQuery+"&currency=XYZ")
+	if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, Content-Type %q, want a JSON 400", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var body errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || !strings.Contains(body.Error, "currency") {
+		t.Fatalf("body %+v, %v, want the currency error", body, err)
+	}
+}
+
+func TestSearchStreamFallsBackToStaleResult(t *testing.T) {
+	cache, err := search.NewCache(config.Default().Cache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cache.Stop)
+	stale := staleCache{Cache: cache, hotels: []models.Hotel{{
+		HotelID: "P-cached",
+		Name:    "Cached Palace",
+		Price:   currency.MustParse("180.00", "EUR"),
+	}}}
+
+	srv := newTestServer(t, stale, failingProvider("Bad1"), failingProvider("Bad2"))
+	events := readEvents(t, getStream(t, srv, streamQuery))
+
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev.name)
+	}
+	want := []string{eventProviderError, eventProviderError, eventHotels, eventDone}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("events %v, want %v", names, want)
+	}
+
+	var fallback streamedHotels
+	if err := json.Unmarshal([]byte(events[2].data), &fallback); err != nil {
+		t.Fatal(err)
+	}
+	if fallback.Provider != "cache" || len(fallback.Hotels) != 1 || fallback.Hotels[0].HotelID != "P-cached" {
+		t.Fatalf("fallback event %s, want the stale cached hotel", events[2].data)
+	}
+
+	var stats models.Stats
+	if err := json.Unmarshal([]byte(events[3].data), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ProvidersSucceeded != 0 || stats.ProvidersFailed != 2 || stats.Cache != "stale-error" {
+		t.Fatalf("done stats %+v, want a stale-error fallback", stats)
+	}
+}
@@ -0,0 +1,23 @@
+package matching
+
+import (
+	"math"
+
+	"hostaggr/internal/models"
+)
+
+// earthRadius is the mean radius of the Earth in meters
+const earthRadius = 6371008.8
+
+// distance returns the great-circle distance between two points in meters
+func distance(a, b models.Location) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,83 @@
+package matching
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping is the curated mapping file: the canonical properties and the ID each
+// provider knows them by. YAML or JSON, e.g.
+//
+//	properties:
+//	  - id: atlas-marrakech
+//	    name: Hotel Atlas
+//	    providers: {Mock1: H123, Mock2: A-5521}
+type Mapping struct {
+	Properties []Property `yaml:"properties"`
+}
+
+// Property is one canonical property. Name, when set, replaces the names providers
+// give it in responses
+type Property struct {
+	ID        string            `yaml:"id"`
+	Name      string            `yaml:"name"`
+	Providers map[string]string `yaml:"providers"` // provider name -> provider hotel ID
+}
+
+// LoadMapping reads and checks a mapping file
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mapping file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var m Mapping
+	if err := dec.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse mapping file %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("mapping file %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// validate checks that property IDs are unique and that no provider hotel is
+// mapped to two properties
+func (m *Mapping) validate() error {
+	var errs []error
+	ids := make(map[string]bool, len(m.Properties))
+	owners := make(map[offerKey]string)
+
+	for i, p := range m.Properties {
+		if strings.TrimSpace(p.ID) == "" {
+			errs = append(errs, fmt.Errorf("property %d has no id", i))
+			continue
+		}
+		if ids[p.ID] {
+			errs = append(errs, fmt.Errorf("property %q is listed twice", p.ID))
+		}
+		ids[p.ID] = true
+
+		for provider, hotelID := range p.Providers {
+			if provider == "" || hotelID == "" {
+				errs = append(errs, fmt.Errorf("property %q maps an empty provider or hotel id", p.ID))
+				continue
+			}
+			key := offerKey{provider: provider, hotelID: hotelID}
+			if owner, ok := owners[key]; ok && owner != p.ID {
+				errs = append(errs, fmt.Errorf("%s is mapped to both %q and %q", key, owner, p.ID))
+			}
+			owners[key] = p.ID
+		}
+	}
+	return errors.Join(errs...)
+}
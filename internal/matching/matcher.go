@@ -0,0 +1,332 @@
+// Package matching recognises offers from different providers as the same property.
+// Providers number hotels in their own namespaces, so each offer is given a
+// canonical property ID by, in order of precedence, a manual override table, a
+// curated mapping file and fuzzy matching on name, city and location
+package matching
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
+)
+
+// Match methods, from the most to the least certain
+const (
+	MethodOverride = "override" // the manual override table
+	MethodMapping  = "mapping"  // the curated mapping file
+	MethodFuzzy    = "fuzzy"    // name, city and location similarity
+	MethodNone     = "none"     // matched no other offer: a property of its own
+)
+
+// KeepApart is the override value that keeps an offer from matching any other
+const KeepApart = "-"
+
+// offerKey identifies a hotel in its provider's namespace
+type offerKey struct {
+	provider string
+	hotelID  string
+}
+
+func (k offerKey) String() string {
+	return k.provider + ":" + k.hotelID
+}
+
+// Result is the property an offer was matched to
+type Result struct {
+	PropertyID string
+	Name       string // curated name of the property, empty if none
+	Method     string
+	Confidence float64 // 1 for overrides, mapped and unmatched offers
+}
+
+// Matcher maps offers to canonical properties. It is immutable once built, so one
+// Matcher serves concurrent searches
+type Matcher struct {
+	overrides   map[offerKey]string // -> property ID or KeepApart
+	mapped      map[offerKey]string // -> property ID
+	names       map[string]string   // property ID -> curated name
+	similarity  similarityFunc
+	threshold   float64
+	maxDistance float64 // meters, zero ignores locations
+	metrics     *obs.Metrics
+}
+
+// New builds a Matcher from cfg, loading its mapping file if one is configured
+func New(cfg config.MatchingConfig, metrics *obs.Metrics) (*Matcher, error) {
+	m := &Matcher{
+		overrides:   make(map[offerKey]string, len(cfg.Overrides)),
+		mapped:      make(map[offerKey]string),
+		names:       make(map[string]string),
+		threshold:   cfg.Threshold,
+		maxDistance: cfg.MaxDistance,
+		metrics:     metrics,
+	}
+
+	switch cfg.Similarity {
+	case JaroWinkler:
+		m.similarity = jaroWinkler
+	case Trigram:
+		m.similarity = trigramSimilarity
+	default:
+		return nil, fmt.Errorf("unknown similarity %q", cfg.Similarity)
+	}
+
+	for key, property := range cfg.Overrides {
+		provider, hotelID, ok := strings.Cut(key, ":")
+		if !ok || provider == "" || hotelID == "" || property == "" {
+			return nil, fmt.Errorf("override %q -> %q must map provider:hotel_id to a property id", key, property)
+		}
+		m.overrides[offerKey{provider: provider, hotelID: hotelID}] = property
+	}
+
+	if cfg.MappingFile != "" {
+		mapping, err := LoadMapping(cfg.MappingFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range mapping.Properties {
+			if p.Name != "" {
+				m.names[p.ID] = p.Name
+			}
+			for provider, hotelID := range p.Providers {
+				m.mapped[offerKey{provider: provider, hotelID: hotelID}] = p.ID
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// group is a property being assembled from the offers of one search
+type group struct {
+	id       string // identifies the group within one Match call only
+	property string // canonical ID from an override or the mapping file, if any
+	keys     map[offerKey]bool
+	apart    bool // kept apart by an override: takes no other offers
+	fuzzy    bool // took offers by fuzzy matching
+}
+
+// bareID returns the hotel ID all of the group's offers share, or false if the
+// providers know the property by different IDs
+func (g *group) bareID() (string, bool) {
+	id := ""
+	for k := range g.keys {
+		if id != "" && k.hotelID != id {
+			return "", false
+		}
+		id = k.hotelID
+	}
+	return id, true
+}
+
+// hashID derives an ID from the group's offers, independent of their order
+func (g *group) hashID() string {
+	keys := make([]string, 0, len(g.keys))
+	for k := range g.keys {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return "p-" + hex.EncodeToString(sum[:6])
+}
+
+// conflicts reports whether the group already holds another hotel of the provider.
+// A provider lists a property under one ID, so two of its IDs are two properties
+func (g *group) conflicts(k offerKey) bool {
+	for other := range g.keys {
+		if other.provider == k.provider && other.hotelID != k.hotelID {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the property of every offer, in the order of hotels. Offers must
+// name their Provider. city canonicalises city names, so offers spelling a city
+// differently still compare equal. A nil Matcher trusts providers to share one ID
+// namespace, as offers were matched before.
+//
+// Property IDs are kept stable for clients: an override or the mapping file names
+// the property; otherwise it keeps the provider's hotel ID when every matched offer
+// shares it and no other property claims it. Only a fuzzy match across differing
+// IDs gets an ID hashed from its offers, which changes with the offers that
+// answered; the mapping file pins such properties
+func (m *Matcher) Match(hotels []models.ProviderHotel, city func(string) string) []Result {
+	results := make([]Result, len(hotels))
+	if m == nil {
+		for i, h := range hotels {
+			results[i] = Result{PropertyID: h.HotelID, Method: MethodNone, Confidence: 1}
+		}
+		return results
+	}
+
+	groups := make(map[string]*group)
+	byKey := make(map[offerKey]*group)
+	assigned := make(map[offerKey]Result)
+	join := func(i int, id string, method string, confidence float64, apart bool) {
+		k := keyOf(hotels[i])
+		g, ok := groups[id]
+		if !ok {
+			g = &group{id: id, keys: make(map[offerKey]bool), apart: apart}
+			if !apart && (method == MethodOverride || method == MethodMapping) {
+				g.property = id
+			}
+			groups[id] = g
+		}
+		g.keys[k] = true
+		byKey[k] = g
+		results[i] = Result{PropertyID: id, Name: m.names[id], Method: method, Confidence: confidence}
+		assigned[k] = results[i]
+	}
+
+	// Overrides and the mapping file are certain
+	var rest []int
+	for i, h := range hotels {
+		k := keyOf(h)
+		if property, ok := m.overrides[k]; ok {
+			if property == KeepApart {
+				join(i, k.String(), MethodOverride, 1, true)
+			} else {
+				join(i, property, MethodOverride, 1, false)
+			}
+			continue
+		}
+		if property, ok := m.mapped[k]; ok {
+			join(i, property, MethodMapping, 1, false)
+			continue
+		}
+		rest = append(rest, i)
+	}
+
+	// Fuzzy matching in key order, so results do not depend on which provider
+	// answered first
+	sort.SliceStable(rest, func(a, b int) bool {
+		return keyOf(hotels[rest[a]]).String() < keyOf(hotels[rest[b]]).String()
+	})
+
+	names := make([]string, len(hotels))
+	cities := make([]string, len(hotels))
+	for i, h := range hotels {
+		names[i] = normalizeName(h.Name)
+		cities[i] = city(h.City)
+	}
+
+	for _, i := range rest {
+		k := keyOf(hotels[i])
+
+		// Another offer for a hotel already matched, e.g. a second room type
+		if r, ok := assigned[k]; ok {
+			results[i] = r
+			continue
+		}
+
+		// A group scores as its most similar member, unless any of its members
+		// is too far away to be the same property
+		scores := make(map[*group]float64)
+		vetoed := make(map[*group]bool)
+		for j := range hotels {
+			g, ok := byKey[keyOf(hotels[j])]
+			if !ok || g.apart || vetoed[g] || g.conflicts(k) {
+				continue
+			}
+			s, far := m.score(hotels[i], hotels[j], names[i], names[j], cities[i], cities[j])
+			if far {
+				vetoed[g] = true
+				continue
+			}
+			scores[g] = max(scores[g], s)
+		}
+
+		var best *group
+		bestScore := 0.0
+		for g, s := range scores {
+			if vetoed[g] {
+				continue
+			}
+			if s > bestScore || (s == bestScore && best != nil && g.id < best.id) {
+				best, bestScore = g, s
+			}
+		}
+
+		if best != nil && bestScore >= m.threshold {
+			// Three decimals are all the precision a similarity score has
+			join(i, best.id, MethodFuzzy, math.Round(bestScore*1000)/1000, false)
+			best.fuzzy = true
+		} else {
+			join(i, k.String(), MethodNone, 1, false)
+		}
+	}
+
+	// The first offer of a fuzzy group was matched too, by those that joined it
+	ids := canonicalIDs(groups)
+	for i, r := range results {
+		g := groups[r.PropertyID]
+		if r.Method == MethodNone && g.fuzzy {
+			results[i].Method = MethodFuzzy
+		}
+		results[i].PropertyID = ids[g]
+	}
+
+	for _, r := range results {
+		m.metrics.HotelMatched(r.Method)
+	}
+	return results
+}
+
+// canonicalIDs names every group: by its override or mapped property, else by the
+// hotel ID its offers share, else by a hash of its offers. A shared hotel ID that
+// another group also uses, or that is a curated property ID, is hashed as well, so
+// two properties never merge in the response
+func canonicalIDs(groups map[string]*group) map[*group]string {
+	ids := make(map[*group]string, len(groups))
+	claims := make(map[string]int)
+	for _, g := range groups {
+		if g.property != "" {
+			ids[g] = g.property
+			claims[g.property]++
+		} else if id, ok := g.bareID(); ok {
+			ids[g] = id
+			claims[id]++
+		} else {
+			ids[g] = g.hashID()
+		}
+	}
+	for _, g := range groups {
+		if g.property == "" && claims[ids[g]] > 1 {
+			ids[g] = g.hashID()
+		}
+	}
+	return ids
+}
+
+// score is the confidence that two offers are the same property: the similarity of
+// their names, raised by proximity when both have a location. Offers in different
+// cities, or further apart than the maximum distance, cannot be the same property
+// and are reported as far
+func (m *Matcher) score(a, b models.ProviderHotel, nameA, nameB, cityA, cityB string) (score float64, far bool) {
+	if cityA != cityB {
+		return 0, true
+	}
+	s := m.similarity(nameA, nameB)
+
+	if a.Location != nil && b.Location != nil && m.maxDistance > 0 {
+		d := distance(*a.Location, *b.Location)
+		if d > m.maxDistance {
+			return 0, true
+		}
+		// Proximity closes up to half the gap to certainty
+		s += (1 - s) * (1 - d/m.maxDistance) / 2
+	}
+	return s, false
+}
+
+func keyOf(h models.ProviderHotel) offerKey {
+	return offerKey{provider: h.Provider, hotelID: h.HotelID}
+}
@@ -0,0 +1,122 @@
+package matching
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/models"
+)
+
+func newTestMatcher(t *testing.T, mapping string, overrides map[string]string) *Matcher {
+	t.Helper()
+	cfg := config.Default().Aggregator.Matching
+	cfg.Overrides = overrides
+	if mapping != "" {
+		cfg.MappingFile = filepath.Join(t.TempDir(), "mapping.yaml")
+		if err := os.WriteFile(cfg.MappingFile, []byte(mapping), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func offer(provider, hotelID, name string) models.ProviderHotel {
+	return models.ProviderHotel{Provider: provider, HotelID: hotelID, Name: name, City: "Marrakech"}
+}
+
+func propertyIDs(m *Matcher, hotels ...models.ProviderHotel) []string {
+	results := m.Match(hotels, strings.ToLower)
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.PropertyID
+	}
+	return ids
+}
+
+func TestMatchKeepsSharedProviderIDs(t *testing.T) {
+	m := newTestMatcher(t, "", nil)
+
+	// The ID does not depend on which providers answered
+	for _, hotels := range [][]models.ProviderHotel{
+		{offer("Mock1", "H123", "Hotel Atlas"), offer("Mock2", "H123", "Hotel Atlas")},
+		{offer("Mock2", "H123", "Hotel Atlas")},
+		{offer("Mock1", "H123", "Hotel Atlas")},
+	} {
+		for _, id := range propertyIDs(m, hotels...) {
+			if id != "H123" {
+				t.Fatalf("property ID %q, want H123", id)
+			}
+		}
+	}
+}
+
+func TestMatchUsesMappedPropertyIDs(t *testing.T) {
+	m := newTestMatcher(t, `
+properties:
+  - id: atlas-marrakech
+    name: Hotel Atlas
+    providers: {Mock1: H123, Mock2: A-5521}
+`, nil)
+
+	for _, hotels := range [][]models.ProviderHotel{
+		{offer("Mock1", "H123", "Hotel Atlas"), offer("Mock2", "A-5521", "Atlas")},
+		{offer("Mock2", "A-5521", "Atlas")},
+	} {
+		for _, id := range propertyIDs(m, hotels...) {
+			if id != "atlas-marrakech" {
+				t.Fatalf("property ID %q, want atlas-marrakech", id)
+			}
+		}
+	}
+}
+
+func TestMatchHashesFuzzyGroupsOfDifferentIDs(t *testing.T) {
+	m := newTestMatcher(t, "", nil)
+	a, b := offer("Mock1", "H123", "Hotel Atlas"), offer("Mock2", "A-5521", "Hotel Atlas")
+
+	ids := propertyIDs(m, a, b)
+	if ids[0] != ids[1] || !strings.HasPrefix(ids[0], "p-") {
+		t.Fatalf("property IDs %v, want one hashed ID", ids)
+	}
+	if again := propertyIDs(m, b, a); again[0] != ids[0] {
+		t.Fatalf("property ID %q in the other order, want %q", again[0], ids[0])
+	}
+
+	// Alone, each offer keeps its provider's ID
+	if id := propertyIDs(m, b)[0]; id != "A-5521" {
+		t.Fatalf("property ID %q, want A-5521", id)
+	}
+}
+
+func TestMatchNeverMergesPropertiesSharingAnID(t *testing.T) {
+	m := newTestMatcher(t, "", nil)
+
+	ids := propertyIDs(m, offer("Mock1", "H999", "Sofitel Palais"), offer("Mock2", "H999", "Dar Soukkar"))
+	if ids[0] == ids[1] {
+		t.Fatalf("different hotels share property ID %q", ids[0])
+	}
+}
+
+func TestMatchKeepsOverriddenOffersApart(t *testing.T) {
+	m := newTestMatcher(t, "", map[string]string{"Mock2:H123": KeepApart})
+
+	ids := propertyIDs(m, offer("Mock1", "H123", "Hotel Atlas"), offer("Mock2", "H123", "Hotel Atlas"))
+	if ids[0] == ids[1] {
+		t.Fatalf("offer kept apart shares property ID %q", ids[0])
+	}
+}
+
+func TestNilMatcherTrustsProviderIDs(t *testing.T) {
+	var m *Matcher
+	ids := propertyIDs(m, offer("Mock1", "H123", "Hotel Atlas"), offer("Mock2", "H123", "Atlas"))
+	if ids[0] != "H123" || ids[1] != "H123" {
+		t.Fatalf("property IDs %v, want H123 for both", ids)
+	}
+}
@@ -0,0 +1,137 @@
+package matching
+
+import (
+	"strings"
+	"unicode"
+
+	"hostaggr/internal/textfold"
+)
+
+// Similarity measures of normalized names
+const (
+	JaroWinkler = "jaro_winkler"
+	Trigram     = "trigram"
+)
+
+// similarityFunc scores two normalized names from 0 (nothing in common) to 1 (equal)
+type similarityFunc func(a, b string) float64
+
+// nameStopWords carry no identity: "Hotel Atlas" and "Atlas" are the same property
+var nameStopWords = map[string]bool{
+	"hotel": true, "hotels": true, "the": true, "and": true, "by": true,
+}
+
+// normalizeName folds case and diacritics, turns punctuation into spaces and drops
+// stop words, so "Hôtel  Le Méridien-N'Fis" becomes "le meridien n fis"
+func normalizeName(s string) string {
+	words := strings.FieldsFunc(textfold.Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0:0]
+	for _, w := range words {
+		if !nameStopWords[w] {
+			kept = append(kept, w)
+		}
+	}
+	// A name made only of stop words is still a name
+	if len(kept) == 0 {
+		kept = words
+	}
+	return strings.Join(kept, " ")
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of a and b, which favours strings
+// sharing a prefix, as names of the same property usually do
+func jaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	// Characters match when equal and no further apart than the window
+	window := max(len(ra), len(rb))/2 - 1
+	window = max(window, 0)
+
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i, r := range ra {
+		lo, hi := max(0, i-window), min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && rb[j] == r {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// Half the matched characters that appear in a different order
+	transpositions, j := 0, 0
+	for i, r := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if r != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	// Boost by the common prefix, up to four characters
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// trigramSimilarity returns the Dice coefficient of the character trigrams of a
+// and b, which tolerates reordered words better than Jaro-Winkler
+func trigramSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t, n := range ta {
+		shared += min(n, tb[t])
+	}
+	return 2 * float64(shared) / float64(total(ta)+total(tb))
+}
+
+// trigrams counts the trigrams of each word, padded so that word boundaries count
+func trigrams(s string) map[string]int {
+	grams := make(map[string]int)
+	for _, w := range strings.Fields(s) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			grams[string(r[i:i+3])]++
+		}
+	}
+	return grams
+}
+
+func total(grams map[string]int) int {
+	n := 0
+	for _, c := range grams {
+		n += c
+	}
+	return n
+}
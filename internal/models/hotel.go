@@ -1,19 +1,288 @@
 package models
 
-// ProviderHotel represents raw data from a provider
+import (
+	"encoding/json"
+	"time"
+
+	"hostaggr/internal/currency"
+)
+
+// Location is a point on Earth in decimal degrees
+type Location struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// ProviderHotel represents raw data from a provider. HotelID is in the provider's
+// own namespace. Price carries its currency and covers one night or the whole stay
+// as Basis says. The aggregator fills Provider, and Basis from the provider's
+// declared pricing when the provider leaves it empty, and FetchedAt with the time
+// the provider answered. Charges are in the currency of Price
 type ProviderHotel struct {
-	HotelID  string  `json:"hotel_id"`
-	Name     string  `json:"name"`
-	City     string  `json:"city"`
-	Currency string  `json:"currency"`
-	Price    float64 `json:"price"`
-	Nights   int     `json:"nights"`
+	Provider  string
+	HotelID   string
+	Name      string
+	City      string
+	Location  *Location // optional
+	Price     currency.Money
+	Basis     PriceBasis
+	Charges   []Charge
+	Nights    int
+	FetchedAt time.Time
+}
+
+// providerHotelJSON is the wire form of ProviderHotel, with the currency beside a
+// plain numeric price
+type providerHotelJSON struct {
+	Provider  string       `json:"provider,omitempty"`
+	HotelID   string       `json:"hotel_id"`
+	Name      string       `json:"name"`
+	City      string       `json:"city"`
+	Location  *Location    `json:"location,omitempty"`
+	Currency  string       `json:"currency"`
+	Price     json.Number  `json:"price"`
+	Basis     PriceBasis   `json:"basis,omitempty"`
+	Charges   []chargeJSON `json:"charges,omitempty"`
+	Nights    int          `json:"nights"`
+	FetchedAt time.Time    `json:"fetched_at,omitzero"`
+}
+
+func (h ProviderHotel) MarshalJSON() ([]byte, error) {
+	charges, err := encodeCharges(h.Charges, h.Price.Currency())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(providerHotelJSON{
+		Provider:  h.Provider,
+		HotelID:   h.HotelID,
+		Name:      h.Name,
+		City:      h.City,
+		Location:  h.Location,
+		Currency:  h.Price.Currency(),
+		Price:     encodeMoney(h.Price),
+		Basis:     h.Basis,
+		Charges:   charges,
+		Nights:    h.Nights,
+		FetchedAt: h.FetchedAt,
+	})
 }
 
-// Hotel represents normalized hotel data
+func (h *ProviderHotel) UnmarshalJSON(data []byte) error {
+	var w providerHotelJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	price, err := currency.Parse(w.Price.String(), w.Currency, currency.RoundHalfEven)
+	if err != nil {
+		return err
+	}
+	charges, err := decodeCharges(w.Charges, price.Currency())
+	if err != nil {
+		return err
+	}
+
+	*h = ProviderHotel{
+		Provider:  w.Provider,
+		HotelID:   w.HotelID,
+		Name:      w.Name,
+		City:      w.City,
+		Location:  w.Location,
+		Price:     price,
+		Basis:     w.Basis,
+		Charges:   charges,
+		Nights:    w.Nights,
+		FetchedAt: w.FetchedAt,
+	}
+	return nil
+}
+
+// Hotel represents normalized hotel data in the requested currency. HotelID is the
+// canonical property ID the offers were matched to, with MatchConfidence the
+// confidence of the weakest match. Price is the stay total, with every mandatory
+// tax and fee, and is what offers are compared on; NightlyPrice is that total
+// spread over the nights. Charges break the total down per stay. OriginalPrice is
+// the offer as the provider quoted it, on OriginalBasis. Those prices are the best
+// offer's; Offers lists every offer, cheapest first, and PriceSpread is how much
+// dearer the dearest is
 type Hotel struct {
-	HotelID  string  `json:"hotel_id"`
-	Name     string  `json:"name"`
-	Currency string  `json:"currency"`
-	Price    float64 `json:"price"`
+	HotelID         string
+	Name            string
+	MatchConfidence float64
+	Price           currency.Money
+	NightlyPrice    currency.Money
+	Charges         []Charge
+	OriginalPrice   currency.Money
+	OriginalBasis   PriceBasis
+	Offers          []Offer
+	PriceSpread     currency.Money
+}
+
+// hotelJSON is the wire form of Hotel, with each currency beside a plain numeric price
+type hotelJSON struct {
+	HotelID          string       `json:"hotel_id"`
+	Name             string       `json:"name"`
+	MatchConfidence  float64      `json:"match_confidence,omitempty"`
+	Currency         string       `json:"currency"`
+	Price            json.Number  `json:"price"`
+	NightlyPrice     json.Number  `json:"nightly_price,omitempty"`
+	Charges          []chargeJSON `json:"charges,omitempty"`
+	OriginalCurrency string       `json:"original_currency"`
+	OriginalPrice    json.Number  `json:"original_price"`
+	OriginalBasis    PriceBasis   `json:"original_basis,omitempty"`
+	Offers           []Offer      `json:"offers,omitempty"`
+	PriceSpread      json.Number  `json:"price_spread,omitempty"`
+}
+
+func (h Hotel) MarshalJSON() ([]byte, error) {
+	charges, err := encodeCharges(h.Charges, h.Price.Currency())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(hotelJSON{
+		HotelID:          h.HotelID,
+		Name:             h.Name,
+		MatchConfidence:  h.MatchConfidence,
+		Currency:         h.Price.Currency(),
+		Price:            encodeMoney(h.Price),
+		NightlyPrice:     encodeMoney(h.NightlyPrice),
+		Charges:          charges,
+		OriginalCurrency: h.OriginalPrice.Currency(),
+		OriginalPrice:    encodeMoney(h.OriginalPrice),
+		OriginalBasis:    h.OriginalBasis,
+		Offers:           h.Offers,
+		PriceSpread:      encodeMoney(h.PriceSpread),
+	})
+}
+
+func (h *Hotel) UnmarshalJSON(data []byte) error {
+	var w hotelJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	price, err := currency.Parse(w.Price.String(), w.Currency, currency.RoundHalfEven)
+	if err != nil {
+		return err
+	}
+	nightly, err := decodeMoney(w.NightlyPrice, price.Currency())
+	if err != nil {
+		return err
+	}
+	charges, err := decodeCharges(w.Charges, price.Currency())
+	if err != nil {
+		return err
+	}
+	original, err := currency.Parse(w.OriginalPrice.String(), w.OriginalCurrency, currency.RoundHalfEven)
+	if err != nil {
+		return err
+	}
+	spread, err := decodeMoney(w.PriceSpread, price.Currency())
+	if err != nil {
+		return err
+	}
+
+	*h = Hotel{
+		HotelID:         w.HotelID,
+		Name:            w.Name,
+		MatchConfidence: w.MatchConfidence,
+		Price:           price,
+		NightlyPrice:    nightly,
+		Charges:         charges,
+		OriginalPrice:   original,
+		OriginalBasis:   w.OriginalBasis,
+		Offers:          w.Offers,
+		PriceSpread:     spread,
+	}
+	return nil
+}
+
+// BestOffersOnly returns hotels without their offer lists and price spreads, in
+// the shape responses had before offers were listed. hotels is not modified
+func BestOffersOnly(hotels []Hotel) []Hotel {
+	if hotels == nil {
+		return nil
+	}
+	best := make([]Hotel, len(hotels))
+	for i, h := range hotels {
+		h.Offers = nil
+		h.PriceSpread = currency.Money{}
+		best[i] = h
+	}
+	return best
+}
+
+// Offer is one provider's offer for a property. Prices are in the requested
+// currency as in Hotel; HotelID is in the provider's namespace
+type Offer struct {
+	Provider        string
+	HotelID         string
+	Price           currency.Money
+	NightlyPrice    currency.Money
+	OriginalPrice   currency.Money
+	OriginalBasis   PriceBasis
+	MatchConfidence float64
+	FetchedAt       time.Time
+	Best            bool // the offer the hotel's prices come from
+}
+
+// offerJSON is the wire form of Offer, with each currency beside a plain numeric price
+type offerJSON struct {
+	Provider         string      `json:"provider"`
+	HotelID          string      `json:"hotel_id"`
+	Currency         string      `json:"currency"`
+	Price            json.Number `json:"price"`
+	NightlyPrice     json.Number `json:"nightly_price,omitempty"`
+	OriginalCurrency string      `json:"original_currency"`
+	OriginalPrice    json.Number `json:"original_price"`
+	OriginalBasis    PriceBasis  `json:"original_basis,omitempty"`
+	MatchConfidence  float64     `json:"match_confidence,omitempty"`
+	FetchedAt        time.Time   `json:"fetched_at,omitzero"`
+	Best             bool        `json:"best"`
+}
+
+func (o Offer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(offerJSON{
+		Provider:         o.Provider,
+		HotelID:          o.HotelID,
+		Currency:         o.Price.Currency(),
+		Price:            encodeMoney(o.Price),
+		NightlyPrice:     encodeMoney(o.NightlyPrice),
+		OriginalCurrency: o.OriginalPrice.Currency(),
+		OriginalPrice:    encodeMoney(o.OriginalPrice),
+		OriginalBasis:    o.OriginalBasis,
+		MatchConfidence:  o.MatchConfidence,
+		FetchedAt:        o.FetchedAt,
+		Best:             o.Best,
+	})
+}
+
+func (o *Offer) UnmarshalJSON(data []byte) error {
+	var w offerJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	price, err := currency.Parse(w.Price.String(), w.Currency, currency.RoundHalfEven)
+	if err != nil {
+		return err
+	}
+	nightly, err := decodeMoney(w.NightlyPrice, price.Currency())
+	if err != nil {
+		return err
+	}
+	original, err := currency.Parse(w.OriginalPrice.String(), w.OriginalCurrency, currency.RoundHalfEven)
+	if err != nil {
+		return err
+	}
+
+	*o = Offer{
+		Provider:        w.Provider,
+		HotelID:         w.HotelID,
+		Price:           price,
+		NightlyPrice:    nightly,
+		OriginalPrice:   original,
+		OriginalBasis:   w.OriginalBasis,
+		MatchConfidence: w.MatchConfidence,
+		FetchedAt:       w.FetchedAt,
+		Best:            w.Best,
+	}
+	return nil
 }
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"hostaggr/internal/currency"
+)
+
+// PriceBasis says what a quoted amount covers
+type PriceBasis string
+
+// Price bases. Providers quote prices per night or per stay; charges may also be
+// levied per person and night, as city taxes usually are
+const (
+	PerNight       PriceBasis = "night"
+	PerStay        PriceBasis = "stay"
+	PerPersonNight PriceBasis = "person_night"
+)
+
+// Units returns how many times an amount on this basis is due over a stay, or
+// false for an unknown basis
+func (b PriceBasis) Units(nights, adults int) (int64, bool) {
+	switch b {
+	case PerNight:
+		return int64(nights), true
+	case PerStay:
+		return 1, true
+	case PerPersonNight:
+		return int64(nights) * int64(adults), true
+	default:
+		return 0, false
+	}
+}
+
+// ChargeKind is the kind of a tax or fee
+type ChargeKind string
+
+// Charge kinds
+const (
+	ChargeTax     ChargeKind = "tax"      // VAT and other national taxes
+	ChargeCityTax ChargeKind = "city_tax" // tourist or city tax
+	ChargeFee     ChargeKind = "fee"      // service, resort or cleaning fees
+)
+
+// Valid reports whether k is a known kind
+func (k ChargeKind) Valid() bool {
+	switch k {
+	case ChargeTax, ChargeCityTax, ChargeFee:
+		return true
+	default:
+		return false
+	}
+}
+
+// Charge is one line of a price breakdown. An included charge is already part of
+// the price it belongs to; an excluded one is due on top of it
+type Charge struct {
+	Kind     ChargeKind
+	Amount   currency.Money
+	Basis    PriceBasis
+	Included bool
+}
+
+// chargeJSON is the wire form of Charge. Its currency is the one of the price the
+// charge belongs to
+type chargeJSON struct {
+	Kind     ChargeKind  `json:"type"`
+	Amount   json.Number `json:"amount"`
+	Basis    PriceBasis  `json:"basis"`
+	Included bool        `json:"included"`
+}
+
+// encodeCharges returns the wire form of charges, failing on any charge that is
+// not in code
+func encodeCharges(charges []Charge, code string) ([]chargeJSON, error) {
+	if len(charges) == 0 {
+		return nil, nil
+	}
+	out := make([]chargeJSON, len(charges))
+	for i, c := range charges {
+		if c.Amount.Currency() != code {
+			return nil, fmt.Errorf("%s charge in %s on a price in %s: %w", c.Kind, c.Amount.Currency(), code, currency.ErrCurrencyMismatch)
+		}
+		out[i] = chargeJSON{Kind: c.Kind, Amount: encodeMoney(c.Amount), Basis: c.Basis, Included: c.Included}
+	}
+	return out, nil
+}
+
+// decodeCharges reads charges whose amounts are in code
+func decodeCharges(charges []chargeJSON, code string) ([]Charge, error) {
+	if len(charges) == 0 {
+		return nil, nil
+	}
+	out := make([]Charge, len(charges))
+	for i, c := range charges {
+		amount, err := decodeMoney(c.Amount, code)
+		if err != nil {
+			return nil, fmt.Errorf("%s charge: %w", c.Kind, err)
+		}
+		out[i] = Charge{Kind: c.Kind, Amount: amount, Basis: c.Basis, Included: c.Included}
+	}
+	return out, nil
+}
+
+// encodeMoney returns m as a plain JSON number, empty for the zero value
+func encodeMoney(m currency.Money) json.Number {
+	if m.IsZero() {
+		return ""
+	}
+	b, _ := m.MarshalJSON()
+	return json.Number(b)
+}
+
+// decodeMoney reads a plain JSON number of the currency code. A missing number is
+// the zero value, so documents written before a field existed still decode
+func decodeMoney(n json.Number, code string) (currency.Money, error) {
+	if n == "" {
+		return currency.Money{}, nil
+	}
+	return currency.Parse(n.String(), code, currency.RoundHalfEven)
+}
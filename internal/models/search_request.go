@@ -1,9 +1,31 @@
 package models
 
+import "time"
+
+// Values of SearchRequest.Offers
+const (
+	OffersAll  = "all"
+	OffersBest = "best"
+)
+
 // SearchRequest represents the incoming search query
 type SearchRequest struct {
 	City    string
 	CheckIn string
 	Nights  int
 	Adults  int
+
+	// Currency is the ISO 4217 code every price is converted into. Normalization
+	// fills in the configured default
+	Currency string
+
+	// Offers is OffersAll to list every provider's offer for each hotel, or
+	// OffersBest for only the cheapest. Normalization fills in OffersAll. Like
+	// MaxWait it only shapes the response and is not part of the cache key
+	Offers string
+
+	// MaxWait is an optional latency budget. When set, the search returns whatever
+	// has arrived once it elapses. It does not affect which results are returned
+	// and is not part of the cache key
+	MaxWait time.Duration
 }
@@ -9,17 +9,25 @@ type SearchResponse struct {
 
 // SearchInfo contains the search parameters
 type SearchInfo struct {
-	City    string `json:"city"`
-	CheckIn string `json:"checkin"`
-	Nights  int    `json:"nights"`
-	Adults  int    `json:"adults"`
+	City     string `json:"city"`
+	CheckIn  string `json:"checkin"`
+	Nights   int    `json:"nights"`
+	Adults   int    `json:"adults"`
+	Currency string `json:"currency"`
 }
 
 // Stats contains aggregation statistics
 type Stats struct {
-	ProvidersTotal     int    `json:"providers_total"`
-	ProvidersSucceeded int    `json:"providers_succeeded"`
-	ProvidersFailed    int    `json:"providers_failed"`
-	Cache              string `json:"cache"` // "hit" or "miss"
-	DurationMs         int64  `json:"duration_ms"`
+	ProvidersTotal     int               `json:"providers_total"`
+	ProvidersSucceeded int               `json:"providers_succeeded"`
+	ProvidersFailed    int               `json:"providers_failed"`
+	ProvidersSkipped   int               `json:"providers_skipped"`           // subset of failed short-circuited by an open breaker
+	ProvidersCached    int               `json:"providers_cached"`            // subset of succeeded answered from the per-provider cache
+	ProvidersPending   []string          `json:"providers_pending,omitempty"` // still running when the latency budget ran out
+	Breakers           map[string]string `json:"breakers,omitempty"`          // provider name -> breaker state
+	Retries            int               `json:"retries"`
+	Hedges             int               `json:"hedges"`
+	Cache              string            `json:"cache"`     // "hit", "stale", "stale-error" or "miss"
+	Coalesced          bool              `json:"coalesced"` // result shared with an identical concurrent search
+	DurationMs         int64             `json:"duration_ms"`
 }
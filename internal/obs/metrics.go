@@ -0,0 +1,403 @@
+package obs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Default histogram buckets
+var (
+	latencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5}
+	countBuckets   = []float64{0, 1, 2, 5, 10, 20, 50, 100}
+)
+
+// Metrics is the service-wide instrumentation. A nil *Metrics is valid and records nothing,
+// so components can be constructed without instrumentation
+type Metrics struct {
+	registry *Registry
+
+	httpRequests     *Counter
+	httpDuration     *Histogram
+	providerRequests *Counter
+	providerDuration *Histogram
+	providerErrors   *Counter
+	providerRetries  *Counter
+	budgetExhausted  *Counter
+	hedgesFired      *Counter
+	hedgesWon        *Counter
+	breakerState     *Gauge
+	breakerRejected  *Counter
+	cacheHits        *Counter
+	cacheMisses      *Counter
+	cacheStale       *Counter
+	cacheEvictions   *Counter
+	cacheEntries     *Gauge
+	cacheBytes       *Gauge
+	cacheBackendErrs *Counter
+	providerCacheHit *Counter
+	providerCacheMis *Counter
+	cacheBackendUp   *Gauge
+	rateLimited      *Counter
+	rateBackendErrs  *Counter
+	rateBackendUp    *Gauge
+	hotelsReturned   *Histogram
+	searchCoalesced  *Counter
+	cacheWarms       *Counter
+	fxReloads        *Counter
+	fxFailures       *Counter
+	hotelMatches     *Counter
+}
+
+// NewMetrics creates the service metrics on a fresh registry
+func NewMetrics() *Metrics {
+	r := NewRegistry()
+
+	return &Metrics{
+		registry: r,
+
+		httpRequests: r.NewCounter("hostaggr_http_requests_total",
+			"HTTP requests served, by route and status code.", "route", "status"),
+		httpDuration: r.NewHistogram("hostaggr_http_request_duration_seconds",
+			"HTTP request latency, by route.", latencyBuckets, "route"),
+		providerRequests: r.NewCounter("hostaggr_provider_requests_total",
+			"Provider search calls, by provider and outcome.", "provider", "outcome"),
+		providerDuration: r.NewHistogram("hostaggr_provider_request_duration_seconds",
+			"Provider search latency, by provider and outcome.", latencyBuckets, "provider", "outcome"),
+		providerErrors: r.NewCounter("hostaggr_provider_errors_total",
+			"Failed provider search calls, by provider and error kind.", "provider", "kind"),
+		providerRetries: r.NewCounter("hostaggr_provider_retries_total",
+			"Provider search calls retried after a transient error.", "provider"),
+		budgetExhausted: r.NewCounter("hostaggr_provider_retry_budget_exhausted_total",
+			"Retries skipped because the provider's retry budget was spent.", "provider"),
+		hedgesFired: r.NewCounter("hostaggr_provider_hedges_total",
+			"Hedged provider calls fired after the observed latency quantile.", "provider"),
+		hedgesWon: r.NewCounter("hostaggr_provider_hedge_wins_total",
+			"Hedged provider calls that answered before the original.", "provider"),
+		breakerState: r.NewGauge("hostaggr_provider_breaker_state",
+			"Circuit breaker state per provider (0 closed, 1 half-open, 2 open).", "provider"),
+		breakerRejected: r.NewCounter("hostaggr_provider_breaker_rejections_total",
+			"Provider calls skipped because the circuit breaker was open.", "provider"),
+		cacheHits: r.NewCounter("hostaggr_cache_hits_total",
+			"Search cache lookups that returned a result."),
+		cacheMisses: r.NewCounter("hostaggr_cache_misses_total",
+			"Search cache lookups that found nothing usable."),
+		cacheStale: r.NewCounter("hostaggr_cache_stale_served_total",
+			"Stale search results served, by reason (revalidate or error).", "reason"),
+		cacheEvictions: r.NewCounter("hostaggr_cache_evictions_total",
+			"Entries removed from the search cache, by reason.", "reason"),
+		cacheEntries: r.NewGauge("hostaggr_cache_entries",
+			"Entries currently held by the search cache."),
+		cacheBytes: r.NewGauge("hostaggr_cache_bytes",
+			"Estimated bytes currently held by the search cache."),
+		providerCacheHit: r.NewCounter("hostaggr_provider_cache_hits_total",
+			"Provider calls answered from the per-provider cache tier.", "provider"),
+		providerCacheMis: r.NewCounter("hostaggr_provider_cache_misses_total",
+			"Per-provider cache lookups that found nothing fresh.", "provider"),
+		cacheBackendErrs: r.NewCounter("hostaggr_cache_backend_errors_total",
+			"Failed calls to the remote cache backend, by operation.", "op"),
+		cacheBackendUp: r.NewGauge("hostaggr_cache_backend_up",
+			"Whether the remote cache backend is in use (1) or the local fallback is (0)."),
+		rateLimited: r.NewCounter("hostaggr_rate_limit_rejections_total",
+			"Requests rejected by the rate limiter, by tier.", "tier"),
+		rateBackendErrs: r.NewCounter("hostaggr_rate_limit_backend_errors_total",
+			"Failed calls to the remote rate limit backend, by operation.", "op"),
+		rateBackendUp: r.NewGauge("hostaggr_rate_limit_backend_up",
+			"Whether the remote rate limit backend is in use (1) or the local fallback is (0)."),
+		hotelsReturned: r.NewHistogram("hostaggr_search_hotels_returned",
+			"Hotels returned per search.", countBuckets),
+		searchCoalesced: r.NewCounter("hostaggr_search_coalesced_total",
+			"Searches that joined an identical in-flight provider fan-out."),
+		cacheWarms: r.NewCounter("hostaggr_cache_warm_total",
+			"Searches run by the cache warmer, by source (seed or traffic) and outcome.", "source", "outcome"),
+		fxReloads: r.NewCounter("hostaggr_fx_reloads_total",
+			"Exchange rate reloads, by source and outcome (updated, unchanged or error).", "source", "outcome"),
+		fxFailures: r.NewCounter("hostaggr_fx_conversion_failures_total",
+			"Offers dropped because they could not be converted, by source and target currency.", "from", "to"),
+		hotelMatches: r.NewCounter("hostaggr_hotel_matches_total",
+			"Provider offers matched to a property, by method (override, mapping, fuzzy or none).", "method"),
+	}
+}
+
+// Registry exposes the underlying registry so other packages can add their own metrics
+func (m *Metrics) Registry() *Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// ObserveHTTPRequest records one served HTTP request
+func (m *Metrics) ObserveHTTPRequest(route string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.Inc(route, strconv.Itoa(status))
+	m.httpDuration.Observe(d.Seconds(), route)
+}
+
+// ObserveProviderCall records the latency and outcome of one provider search
+func (m *Metrics) ObserveProviderCall(provider string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	switch {
+	case errors.Is(err, context.Canceled):
+		outcome = "canceled"
+	case err != nil:
+		outcome = "error"
+	}
+	m.providerRequests.Inc(provider, outcome)
+	m.providerDuration.Observe(d.Seconds(), provider, outcome)
+}
+
+// ProviderError records a failed provider call by error kind
+func (m *Metrics) ProviderError(provider, kind string) {
+	if m == nil {
+		return
+	}
+	m.providerErrors.Inc(provider, kind)
+}
+
+// ProviderRetry records a retried provider call
+func (m *Metrics) ProviderRetry(provider string) {
+	if m == nil {
+		return
+	}
+	m.providerRetries.Inc(provider)
+}
+
+// RetryBudgetExhausted records a retry skipped for lack of budget
+func (m *Metrics) RetryBudgetExhausted(provider string) {
+	if m == nil {
+		return
+	}
+	m.budgetExhausted.Inc(provider)
+}
+
+// HedgeFired records a hedged provider call
+func (m *Metrics) HedgeFired(provider string) {
+	if m == nil {
+		return
+	}
+	m.hedgesFired.Inc(provider)
+}
+
+// HedgeWon records a hedged call that answered before the original
+func (m *Metrics) HedgeWon(provider string) {
+	if m == nil {
+		return
+	}
+	m.hedgesWon.Inc(provider)
+}
+
+// SetBreakerState records the numeric circuit breaker state of a provider
+func (m *Metrics) SetBreakerState(provider string, state int) {
+	if m == nil {
+		return
+	}
+	m.breakerState.Set(float64(state), provider)
+}
+
+// BreakerRejected records a provider call skipped by an open circuit breaker
+func (m *Metrics) BreakerRejected(provider string) {
+	if m == nil {
+		return
+	}
+	m.breakerRejected.Inc(provider)
+}
+
+// CacheHit records a cache lookup that returned a result
+func (m *Metrics) CacheHit() {
+	if m == nil {
+		return
+	}
+	m.cacheHits.Inc()
+}
+
+// CacheMiss records a cache lookup that found nothing usable
+func (m *Metrics) CacheMiss() {
+	if m == nil {
+		return
+	}
+	m.cacheMisses.Inc()
+}
+
+// CacheStaleServed records a stale result served while revalidating or in place of an error
+func (m *Metrics) CacheStaleServed(reason string) {
+	if m == nil {
+		return
+	}
+	m.cacheStale.Inc(reason)
+}
+
+// CacheEviction records an entry removed from the cache for the given reason
+func (m *Metrics) CacheEviction(reason string) {
+	if m == nil {
+		return
+	}
+	m.cacheEvictions.Inc(reason)
+}
+
+// SetCacheEntries records the current number of cache entries
+func (m *Metrics) SetCacheEntries(n int) {
+	if m == nil {
+		return
+	}
+	m.cacheEntries.Set(float64(n))
+}
+
+// SetCacheBytes records the estimated bytes held by the cache
+func (m *Metrics) SetCacheBytes(n int64) {
+	if m == nil {
+		return
+	}
+	m.cacheBytes.Set(float64(n))
+}
+
+// ProviderCacheHit records a provider call answered from the per-provider cache tier
+func (m *Metrics) ProviderCacheHit(provider string) {
+	if m == nil {
+		return
+	}
+	m.providerCacheHit.Inc(provider)
+}
+
+// ProviderCacheMiss records a per-provider cache lookup that found nothing fresh
+func (m *Metrics) ProviderCacheMiss(provider string) {
+	if m == nil {
+		return
+	}
+	m.providerCacheMis.Inc(provider)
+}
+
+// CacheBackendError records a failed call to the remote cache backend
+func (m *Metrics) CacheBackendError(op string) {
+	if m == nil {
+		return
+	}
+	m.cacheBackendErrs.Inc(op)
+}
+
+// SetCacheBackendUp records whether the remote cache backend is in use
+func (m *Metrics) SetCacheBackendUp(up bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if up {
+		v = 1
+	}
+	m.cacheBackendUp.Set(v)
+}
+
+// RateLimitRejected records a request of the given tier rejected by the rate limiter
+func (m *Metrics) RateLimitRejected(tier string) {
+	if m == nil {
+		return
+	}
+	m.rateLimited.Inc(tier)
+}
+
+// RateLimitBackendError records a failed call to the remote rate limit backend
+func (m *Metrics) RateLimitBackendError(op string) {
+	if m == nil {
+		return
+	}
+	m.rateBackendErrs.Inc(op)
+}
+
+// SetRateLimitBackendUp records whether the remote rate limit backend is in use
+func (m *Metrics) SetRateLimitBackendUp(up bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if up {
+		v = 1
+	}
+	m.rateBackendUp.Set(v)
+}
+
+// ObserveHotelsReturned records the number of hotels returned by one search
+func (m *Metrics) ObserveHotelsReturned(n int) {
+	if m == nil {
+		return
+	}
+	m.hotelsReturned.Observe(float64(n))
+}
+
+// SearchCoalesced records a search served by another search's provider fan-out
+func (m *Metrics) SearchCoalesced() {
+	if m == nil {
+		return
+	}
+	m.searchCoalesced.Inc()
+}
+
+// CacheWarm records one search run by the cache warmer
+func (m *Metrics) CacheWarm(source, outcome string) {
+	if m == nil {
+		return
+	}
+	m.cacheWarms.Inc(source, outcome)
+}
+
+// FXReload records one reload of an exchange rate source
+func (m *Metrics) FXReload(source, outcome string) {
+	if m == nil {
+		return
+	}
+	m.fxReloads.Inc(source, outcome)
+}
+
+// FXConversionFailed records an offer that could not be converted between currencies
+func (m *Metrics) FXConversionFailed(from, to string) {
+	if m == nil {
+		return
+	}
+	m.fxFailures.Inc(from, to)
+}
+
+// HotelMatched records how a provider offer was matched to a property
+func (m *Metrics) HotelMatched(method string) {
+	if m == nil {
+		return
+	}
+	m.hotelMatches.Inc(method)
+}
+
+// WritePrometheus renders all metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.WritePrometheus(w)
+}
+
+// Summary is the JSON view of the metrics. The top-level totals keep the shape
+// of the original placeholder endpoint
+type Summary struct {
+	RequestsTotal  float64                   `json:"requests_total"`
+	CacheHits      float64                   `json:"cache_hits"`
+	CacheMisses    float64                   `json:"cache_misses"`
+	ProviderErrors float64                   `json:"provider_errors"`
+	Metrics        map[string]FamilySnapshot `json:"metrics"`
+}
+
+// Summary returns the JSON view of the metrics
+func (m *Metrics) Summary() Summary {
+	if m == nil {
+		return Summary{Metrics: map[string]FamilySnapshot{}}
+	}
+	return Summary{
+		RequestsTotal:  m.registry.Sum("hostaggr_http_requests_total"),
+		CacheHits:      m.registry.Sum("hostaggr_cache_hits_total"),
+		CacheMisses:    m.registry.Sum("hostaggr_cache_misses_total"),
+		ProviderErrors: m.registry.Sum("hostaggr_provider_errors_total"),
+		Metrics:        m.registry.Snapshot(),
+	}
+}
@@ -0,0 +1,364 @@
+package obs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// Registry holds metric families and renders them for scraping
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// family is one named metric with a fixed set of label names
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64 // upper bounds, histograms only
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is a single label combination within a family
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	counts      []uint64 // per-bucket observations, histograms only
+	count       uint64
+	sum         float64
+}
+
+// Counter is a monotonically increasing metric
+type Counter struct{ f *family }
+
+// Gauge is a metric that can go up and down
+type Gauge struct{ f *family }
+
+// Histogram samples observations into cumulative buckets
+type Histogram struct{ f *family }
+
+// NewCounter registers a counter family
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{f: r.register(name, help, counterType, labels, nil)}
+}
+
+// NewGauge registers a gauge family
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{f: r.register(name, help, gaugeType, labels, nil)}
+}
+
+// NewHistogram registers a histogram family with the given bucket upper bounds
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Histogram{f: r.register(name, help, histogramType, labels, b)}
+}
+
+func (r *Registry) register(name, help string, typ metricType, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.families[name]; exists {
+		panic(fmt.Sprintf("obs: metric %q registered twice", name))
+	}
+
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// get returns the series for the label values, creating it if needed. Caller must hold f.mu
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("obs: metric %q expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, exists := f.series[key]
+	if !exists {
+		s = &series{labelValues: append([]string(nil), values...)}
+		if f.typ == histogramType {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds a non-negative delta to the counter
+func (c *Counter) Add(delta float64, labels ...string) {
+	if delta < 0 {
+		return
+	}
+	c.f.mu.Lock()
+	c.f.get(labels).value += delta
+	c.f.mu.Unlock()
+}
+
+// Set replaces the gauge value
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.f.mu.Lock()
+	g.f.get(labels).value = v
+	g.f.mu.Unlock()
+}
+
+// Add adds delta (which may be negative) to the gauge
+func (g *Gauge) Add(delta float64, labels ...string) {
+	g.f.mu.Lock()
+	g.f.get(labels).value += delta
+	g.f.mu.Unlock()
+}
+
+// Observe records one sample
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.get(labels)
+	s.count++
+	s.sum += v
+	for i, bound := range h.f.buckets {
+		if v <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+}
+
+// Sum returns the total of a counter or gauge across all its label combinations
+func (r *Registry) Sum(name string) float64 {
+	r.mu.RLock()
+	f, exists := r.families[name]
+	r.mu.RUnlock()
+
+	if !exists {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	total := 0.0
+	for _, s := range f.series {
+		if f.typ == histogramType {
+			total += float64(s.count)
+		} else {
+			total += s.value
+		}
+	}
+	return total
+}
+
+// sortedFamilies returns families ordered by name for stable output
+func (r *Registry) sortedFamilies() []*family {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fams := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		fams = append(fams, f)
+	}
+	sort.Slice(fams, func(i, j int) bool {
+		return fams[i].name < fams[j].name
+	})
+	return fams
+}
+
+// sortedSeries returns a copy of the family's series ordered by label values. Caller must hold f.mu
+func (f *family) sortedSeries() []series {
+	out := make([]series, 0, len(f.series))
+	for _, s := range f.series {
+		cp := *s
+		cp.counts = append([]uint64(nil), s.counts...)
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].labelValues, "\xff") < strings.Join(out[j].labelValues, "\xff")
+	})
+	return out
+}
+
+// WritePrometheus renders every family in the Prometheus text exposition format (version 0.0.4)
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, f := range r.sortedFamilies() {
+		f.mu.Lock()
+		all := f.sortedSeries()
+		f.mu.Unlock()
+
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+
+		for _, s := range all {
+			if f.typ != histogramType {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), formatFloat(s.value))
+				continue
+			}
+
+			// Buckets are exposed cumulatively, ending with +Inf
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "le", "+Inf"), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.labelValues, "", ""), s.count)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// SeriesSnapshot is the JSON view of one label combination
+type SeriesSnapshot struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Value   *float64          `json:"value,omitempty"`
+	Count   *uint64           `json:"count,omitempty"`
+	Sum     *float64          `json:"sum,omitempty"`
+	Buckets map[string]uint64 `json:"buckets,omitempty"`
+}
+
+// FamilySnapshot is the JSON view of one metric family
+type FamilySnapshot struct {
+	Help   string           `json:"help"`
+	Type   string           `json:"type"`
+	Series []SeriesSnapshot `json:"series"`
+}
+
+// Snapshot returns a point-in-time copy of every family keyed by name
+func (r *Registry) Snapshot() map[string]FamilySnapshot {
+	out := make(map[string]FamilySnapshot)
+
+	for _, f := range r.sortedFamilies() {
+		f.mu.Lock()
+		all := f.sortedSeries()
+		f.mu.Unlock()
+
+		fs := FamilySnapshot{
+			Help:   f.help,
+			Type:   string(f.typ),
+			Series: make([]SeriesSnapshot, 0, len(all)),
+		}
+
+		for _, s := range all {
+			ss := SeriesSnapshot{}
+			if len(f.labels) > 0 {
+				ss.Labels = make(map[string]string, len(f.labels))
+				for i, name := range f.labels {
+					ss.Labels[name] = s.labelValues[i]
+				}
+			}
+
+			if f.typ == histogramType {
+				count, sum := s.count, s.sum
+				ss.Count = &count
+				ss.Sum = &sum
+				ss.Buckets = make(map[string]uint64, len(f.buckets)+1)
+				var cumulative uint64
+				for i, bound := range f.buckets {
+					cumulative += s.counts[i]
+					ss.Buckets[formatFloat(bound)] = cumulative
+				}
+				ss.Buckets["+Inf"] = s.count
+			} else {
+				v := s.value
+				ss.Value = &v
+			}
+
+			fs.Series = append(fs.Series, ss)
+		}
+
+		out[f.name] = fs
+	}
+
+	return out
+}
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(values[i]))
+		sb.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName)
+		sb.WriteString(`="`)
+		sb.WriteString(extraValue)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,97 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// testRegistry fills a registry registered out of name order, with series created
+// out of label order and label values that need escaping
+func testRegistry() *Registry {
+	r := NewRegistry()
+
+	latency := r.NewHistogram("test_latency_seconds", "Call latency.", []float64{1, 0.1, 0.5}, "provider")
+	latency.Observe(0.05, "b")
+	latency.Observe(0.3, "b")
+	latency.Observe(2, "b")
+	latency.Observe(0.1, "a")
+
+	requests := r.NewCounter("test_requests_total", "Requests by path.\nSecond line with a \\ backslash.", "path", "status")
+	requests.Inc("/search", "200")
+	requests.Add(2, `/say "hi"`, "200")
+	requests.Inc("/a\\b\nc", "500")
+	requests.Add(-1, "/search", "200") // counters never go down
+
+	up := r.NewGauge("test_up", "Whether the backend is up.")
+	up.Set(1)
+	up.Add(-0.5)
+
+	return r
+}
+
+func TestWritePrometheusGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRegistry().WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "registry.prom")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Fatalf("WritePrometheus output differs from %s:\n%s", golden, got)
+	}
+
+	// Rendering again gives the same bytes, whatever the map iteration order
+	var again bytes.Buffer
+	testRegistry().WritePrometheus(&again)
+	if again.String() != buf.String() {
+		t.Fatal("WritePrometheus output is not stable")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	snap := testRegistry().Snapshot()
+
+	h := snap["test_latency_seconds"]
+	if h.Type != "histogram" || len(h.Series) != 2 {
+		t.Fatalf("histogram snapshot %+v", h)
+	}
+	b := h.Series[1]
+	if b.Labels["provider"] != "b" || *b.Count != 3 || b.Buckets["0.1"] != 1 || b.Buckets["0.5"] != 2 || b.Buckets["1"] != 2 || b.Buckets["+Inf"] != 3 {
+		t.Fatalf("series b %+v, buckets %v", b, b.Buckets)
+	}
+
+	if _, err := json.Marshal(snap); err != nil {
+		t.Fatal(err)
+	}
+	if v := *snap["test_up"].Series[0].Value; v != 0.5 {
+		t.Fatalf("gauge %g, want 0.5", v)
+	}
+}
+
+func TestSum(t *testing.T) {
+	r := testRegistry()
+	if got := r.Sum("test_requests_total"); got != 4 {
+		t.Errorf("Sum(counter) = %g, want 4", got)
+	}
+	if got := r.Sum("test_latency_seconds"); got != 4 {
+		t.Errorf("Sum(histogram) = %g, want 4 observations", got)
+	}
+	if got := r.Sum("missing"); got != 0 {
+		t.Errorf("Sum(missing) = %g, want 0", got)
+	}
+}
@@ -0,0 +1,128 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrorKind classifies provider failures so callers can decide whether to retry
+type ErrorKind int
+
+const (
+	// KindUnknown is an unclassified failure and is treated as permanent
+	KindUnknown ErrorKind = iota
+	// KindTransient is a temporary failure that is likely to succeed on retry
+	KindTransient
+	// KindTimeout means the provider timed out on its side
+	KindTimeout
+	// KindRateLimited means the provider throttled the request
+	KindRateLimited
+	// KindUnavailable means the provider is temporarily down or overloaded
+	KindUnavailable
+	// KindInvalidRequest means the provider rejected the request itself
+	KindInvalidRequest
+	// KindPermanent is any other failure that will not go away on retry
+	KindPermanent
+	// KindCanceled means the caller gave up before the provider answered
+	KindCanceled
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindTransient:
+		return "transient"
+	case KindTimeout:
+		return "timeout"
+	case KindRateLimited:
+		return "rate_limited"
+	case KindUnavailable:
+		return "unavailable"
+	case KindInvalidRequest:
+		return "invalid_request"
+	case KindPermanent:
+		return "permanent"
+	case KindCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// Retryable reports whether errors of this kind are worth retrying
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case KindTransient, KindTimeout, KindRateLimited, KindUnavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// Error is a classified provider failure
+type Error struct {
+	Provider string
+	Kind     ErrorKind
+	Err      error
+
+	// RetryAfter is an optional hint from the provider, zero when absent
+	RetryAfter time.Duration
+}
+
+// NewError creates a classified error for the named provider
+func NewError(provider string, kind ErrorKind, err error) *Error {
+	return &Error{
+		Provider: provider,
+		Kind:     kind,
+		Err:      err,
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Provider, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf classifies any error returned by Provider.Search
+// Context errors map to KindCanceled or KindTimeout, unclassified errors to KindUnknown
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return KindUnknown
+	}
+
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe.Kind
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return KindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	}
+
+	return KindUnknown
+}
+
+// IsRetryable reports whether err is a classified failure worth retrying
+func IsRetryable(err error) bool {
+	var pe *Error
+	if !errors.As(err, &pe) {
+		return false
+	}
+	return pe.Kind.Retryable()
+}
+
+// RetryAfter returns the provider's retry hint, or zero
+func RetryAfter(err error) time.Duration {
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe.RetryAfter
+	}
+	return 0
+}
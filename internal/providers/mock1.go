@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"time"
 
+	"hostaggr/internal/currency"
 	"hostaggr/internal/models"
 )
 
@@ -19,6 +20,12 @@ func (m *Mock1) Name() string {
 	return "Mock1"
 }
 
+// Pricing declares that Mock1 quotes nightly rates with VAT included and the city
+// tax due on top
+func (m *Mock1) Pricing() Pricing {
+	return Pricing{Basis: models.PerNight}
+}
+
 // Search performs a hotel search with simulated latency and random failures
 func (m *Mock1) Search(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, error) {
 	// Random latency between 50-500ms
@@ -37,7 +44,7 @@ func (m *Mock1) Search(ctx context.Context, req models.SearchRequest) ([]models.
 
 	// 20% random failure rate
 	if rand.Float32() < 0.2 {
-		return nil, errors.New("Mock1: random provider failure")
+		return nil, NewError(m.Name(), KindTransient, errors.New("random provider failure"))
 	}
 
 	// Use inconsistent city casing
@@ -54,24 +61,26 @@ func (m *Mock1) Search(ctx context.Context, req models.SearchRequest) ([]models.
 			HotelID:  "H123",
 			Name:     "Hotel Atlas",
 			City:     randomCity,
-			Currency: "EUR",
-			Price:    129.90,
+			Location: &models.Location{Lat: 31.6295, Lon: -8.0102},
+			Price:    currency.MustParse("129.90", "EUR"),
+			Charges:  mock1Charges("11.81"),
 			Nights:   req.Nights,
 		},
 		{
-			HotelID:  "H456",
-			Name:     "Riad Zitoun",
-			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    89.50,
-			Nights:   req.Nights,
+			HotelID: "H456",
+			Name:    "Riad Zitoun",
+			City:    cityCasings[rand.Intn(len(cityCasings))],
+			Price:   currency.MustParse("89.50", "EUR"),
+			Charges: mock1Charges("8.14"),
+			Nights:  req.Nights,
 		},
 		{
 			HotelID:  "H789",
 			Name:     "Le Meridien",
 			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    199.00,
+			Location: &models.Location{Lat: 31.6123, Lon: -7.9771},
+			Price:    currency.MustParse("199.00", "EUR"),
+			Charges:  mock1Charges("18.09"),
 			Nights:   req.Nights,
 		},
 	}
@@ -79,6 +88,14 @@ func (m *Mock1) Search(ctx context.Context, req models.SearchRequest) ([]models.
 	return hotels, nil
 }
 
+// mock1Charges lists the VAT included in a nightly rate and the city tax on top
+func mock1Charges(vat string) []models.Charge {
+	return []models.Charge{
+		{Kind: models.ChargeTax, Amount: currency.MustParse(vat, "EUR"), Basis: models.PerNight, Included: true},
+		{Kind: models.ChargeCityTax, Amount: currency.MustParse("2.50", "EUR"), Basis: models.PerPersonNight},
+	}
+}
+
 // Helper functions for string casing
 func toTitle(s string) string {
 	if len(s) == 0 {
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"time"
 
+	"hostaggr/internal/currency"
 	"hostaggr/internal/models"
 )
 
@@ -19,6 +20,12 @@ func (m *Mock2) Name() string {
 	return "Mock2"
 }
 
+// Pricing declares that Mock2 quotes the whole stay, city tax included, with a
+// booking fee due on top
+func (m *Mock2) Pricing() Pricing {
+	return Pricing{Basis: models.PerStay}
+}
+
 // Search performs a hotel search with simulated latency and random failures
 func (m *Mock2) Search(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, error) {
 	// Random latency between 50-500ms
@@ -37,7 +44,7 @@ func (m *Mock2) Search(ctx context.Context, req models.SearchRequest) ([]models.
 
 	// 20% random failure rate
 	if rand.Float32() < 0.2 {
-		return nil, errors.New("Mock2: random provider failure")
+		return nil, NewError(m.Name(), KindTransient, errors.New("random provider failure"))
 	}
 
 	// Use inconsistent city casing
@@ -53,35 +60,53 @@ func (m *Mock2) Search(ctx context.Context, req models.SearchRequest) ([]models.
 			HotelID:  "H123",
 			Name:     "Hotel Atlas",
 			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    135.00,
+			Location: &models.Location{Lat: 31.6297, Lon: -8.0099},
+			Price:    stayPrice("135.00", req.Nights),
+			Charges:  mock2Charges(),
 			Nights:   req.Nights,
 		},
 		{
-			HotelID:  "H999",
-			Name:     "Sofitel Palais",
-			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    250.00,
-			Nights:   req.Nights,
+			HotelID: "H999",
+			Name:    "Sofitel Palais",
+			City:    cityCasings[rand.Intn(len(cityCasings))],
+			Price:   stayPrice("250.00", req.Nights),
+			Charges: mock2Charges(),
+			Nights:  req.Nights,
 		},
 		{
-			HotelID:  "H111",
-			Name:     "Dar Soukkar",
-			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    75.00,
-			Nights:   req.Nights,
+			HotelID: "H111",
+			Name:    "Dar Soukkar",
+			City:    cityCasings[rand.Intn(len(cityCasings))],
+			Price:   stayPrice("75.00", req.Nights),
+			Charges: mock2Charges(),
+			Nights:  req.Nights,
 		},
 		{
-			HotelID:  "H222",
-			Name:     "Kech Boutique",
-			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    110.00,
-			Nights:   req.Nights,
+			HotelID: "H222",
+			Name:    "Kech Boutique",
+			City:    cityCasings[rand.Intn(len(cityCasings))],
+			Price:   stayPrice("110.00", req.Nights),
+			Charges: mock2Charges(),
+			Nights:  req.Nights,
 		},
 	}
 
 	return hotels, nil
 }
+
+// stayPrice is a nightly rate over the stay, as Mock2 quotes it
+func stayPrice(nightly string, nights int) currency.Money {
+	price, err := currency.MustParse(nightly, "EUR").Mul(int64(nights))
+	if err != nil {
+		panic(err)
+	}
+	return price
+}
+
+// mock2Charges lists the city tax included in a stay price and the booking fee on top
+func mock2Charges() []models.Charge {
+	return []models.Charge{
+		{Kind: models.ChargeCityTax, Amount: currency.MustParse("2.50", "EUR"), Basis: models.PerPersonNight, Included: true},
+		{Kind: models.ChargeFee, Amount: currency.MustParse("15.00", "EUR"), Basis: models.PerStay},
+	}
+}
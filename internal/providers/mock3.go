@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"time"
 
+	"hostaggr/internal/currency"
 	"hostaggr/internal/models"
 )
 
@@ -19,6 +20,17 @@ func (m *Mock3) Name() string {
 	return "Mock3"
 }
 
+// Pricing declares that Mock3 quotes all-inclusive nightly rates
+func (m *Mock3) Pricing() Pricing {
+	return Pricing{Basis: models.PerNight}
+}
+
+// FreshFor declares that Mock3 republishes its rates every two minutes, so its
+// answers may be reused for longer than the configured provider TTL
+func (m *Mock3) FreshFor(req models.SearchRequest) time.Duration {
+	return 2 * time.Minute
+}
+
 // Search performs a hotel search with simulated latency and random failures
 func (m *Mock3) Search(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, error) {
 	// Random latency between 50-500ms
@@ -37,7 +49,7 @@ func (m *Mock3) Search(ctx context.Context, req models.SearchRequest) ([]models.
 
 	// 20% random failure rate
 	if rand.Float32() < 0.2 {
-		return nil, errors.New("Mock3: random provider failure")
+		return nil, NewError(m.Name(), KindTransient, errors.New("random provider failure"))
 	}
 
 	// Use inconsistent city casing
@@ -53,25 +65,23 @@ func (m *Mock3) Search(ctx context.Context, req models.SearchRequest) ([]models.
 			HotelID:  "H789",
 			Name:     "Le Meridien",
 			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    195.00,
+			Location: &models.Location{Lat: 31.6121, Lon: -7.9775},
+			Price:    currency.MustParse("195.00", "EUR"),
 			Nights:   req.Nights,
 		},
 		{
-			HotelID:  "H333",
-			Name:     "Royal Mansour",
-			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    450.00,
-			Nights:   req.Nights,
+			HotelID: "H333",
+			Name:    "Royal Mansour",
+			City:    cityCasings[rand.Intn(len(cityCasings))],
+			Price:   currency.MustParse("450.00", "EUR"),
+			Nights:  req.Nights,
 		},
 		{
-			HotelID:  "H444",
-			Name:     "La Mamounia",
-			City:     cityCasings[rand.Intn(len(cityCasings))],
-			Currency: "EUR",
-			Price:    380.00,
-			Nights:   req.Nights,
+			HotelID: "H444",
+			Name:    "La Mamounia",
+			City:    cityCasings[rand.Intn(len(cityCasings))],
+			Price:   currency.MustParse("380.00", "EUR"),
+			Nights:  req.Nights,
 		},
 	}
 
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"time"
 
 	"hostaggr/internal/models"
 )
@@ -13,4 +14,24 @@ type Provider interface {
 
 	// Name returns the unique identifier/name of the provider
 	Name() string
+
+	// Pricing declares what the provider's prices cover
+	Pricing() Pricing
+}
+
+// Pricing declares a provider's pricing semantics, so that offers from providers
+// that quote differently are compared like with like. Taxes and fees are listed
+// per offer in ProviderHotel.Charges, each flagged as included in the price or not
+type Pricing struct {
+	// Basis is what ProviderHotel.Price covers, models.PerNight or models.PerStay.
+	// Offers that set their own Basis override it
+	Basis models.PriceBasis
+}
+
+// FreshnessHinter is optionally implemented by providers that know how long their
+// results stay valid. The aggregator caches each provider's raw results for the
+// returned duration; zero means the configured default and a negative value means
+// the results must not be reused
+type FreshnessHinter interface {
+	FreshFor(req models.SearchRequest) time.Duration
 }
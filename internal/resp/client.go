@@ -0,0 +1,185 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Options configures a Client
+type Options struct {
+	Addr        string
+	Password    string
+	DB          int
+	DialTimeout time.Duration
+	IOTimeout   time.Duration // per command, unless ctx has an earlier deadline
+	PoolSize    int           // idle connections kept for reuse
+}
+
+// ErrClosed is returned by Do after Close
+var ErrClosed = errors.New("resp: client closed")
+
+// Client sends commands over a pool of connections. It is safe for concurrent use
+type Client struct {
+	opts Options
+	idle chan *conn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// NewClient creates a client. Connections are dialled lazily
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 1
+	}
+	return &Client{
+		opts: opts,
+		idle: make(chan *conn, opts.PoolSize),
+	}
+}
+
+// Do sends one command and returns its reply. A server error reply is returned as an Error
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, c.opts.IOTimeout, args)
+	if err != nil {
+		// The connection state is unknown after an I/O error
+		cn.Close()
+		return nil, err
+	}
+
+	c.put(cn)
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Ping checks that the server is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close closes idle connections and fails later calls. Connections in use are closed when returned
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.idle)
+	for cn := range c.idle {
+		cn.Close()
+	}
+	return nil
+}
+
+// get takes an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	select {
+	case cn, ok := <-c.idle:
+		if ok {
+			return cn, nil
+		}
+		return nil, ErrClosed
+	default:
+	}
+
+	return c.dial(ctx)
+}
+
+// put returns a healthy connection to the pool, closing it if the pool is full
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		cn.Close()
+		return
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	d := net.Dialer{Timeout: c.opts.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	// Authenticate and select the database once per connection
+	var setup [][]string
+	if c.opts.Password != "" {
+		setup = append(setup, []string{"AUTH", c.opts.Password})
+	}
+	if c.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.opts.DB)})
+	}
+	for _, args := range setup {
+		reply, err := cn.do(ctx, c.opts.IOTimeout, args)
+		if err == nil {
+			if e, ok := reply.(Error); ok {
+				err = e
+			}
+		}
+		if err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+// do writes args and reads one reply within the I/O timeout or ctx deadline, whichever is first
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := WriteValue(cn.w, args); err != nil {
+		return nil, err
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return ReadValue(cn.r)
+}
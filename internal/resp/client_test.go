@@ -0,0 +1,117 @@
+package resp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hostaggr/internal/resp"
+	"hostaggr/internal/resp/resptest"
+)
+
+func newServer(t *testing.T) *resptest.Server {
+	t.Helper()
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func newClient(t *testing.T, opts resp.Options) *resp.Client {
+	t.Helper()
+	if opts.IOTimeout == 0 {
+		opts.IOTimeout = time.Second
+	}
+	c := resp.NewClient(opts)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientSetGet(t *testing.T) {
+	srv := newServer(t)
+	c := newClient(t, resp.Options{Addr: srv.Addr()})
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping() = %v", err)
+	}
+	if _, err := c.Do(ctx, "SET", "k", "v", "PX", "1500"); err != nil {
+		t.Fatalf("SET = %v", err)
+	}
+
+	got, err := resp.String(c.Do(ctx, "GET", "k"))
+	if err != nil || got != "v" {
+		t.Fatalf("GET = %q, %v, want v", got, err)
+	}
+	if ttl, _ := srv.TTL("k"); ttl <= time.Second || ttl > 1500*time.Millisecond {
+		t.Fatalf("TTL = %v, want about 1.5s", ttl)
+	}
+
+	_, err = resp.String(c.Do(ctx, "GET", "missing"))
+	if !errors.Is(err, resp.ErrNil) {
+		t.Fatalf("GET missing = %v, want ErrNil", err)
+	}
+}
+
+func TestClientReturnsServerErrors(t *testing.T) {
+	srv := newServer(t)
+	c := newClient(t, resp.Options{Addr: srv.Addr()})
+
+	_, err := c.Do(context.Background(), "NOSUCHCOMMAND")
+	var e resp.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("Do() = %v, want a resp.Error", err)
+	}
+
+	// An error reply leaves the connection usable
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() after an error reply = %v", err)
+	}
+}
+
+func TestClientAuthenticates(t *testing.T) {
+	srv := newServer(t)
+	srv.RequireAuth("secret")
+
+	wrong := newClient(t, resp.Options{Addr: srv.Addr(), Password: "guess"})
+	if err := wrong.Ping(context.Background()); err == nil {
+		t.Fatal("Ping() with a wrong password succeeded")
+	}
+
+	right := newClient(t, resp.Options{Addr: srv.Addr(), Password: "secret"})
+	if err := right.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() = %v", err)
+	}
+}
+
+func TestClientFailsAfterClose(t *testing.T) {
+	srv := newServer(t)
+	c := newClient(t, resp.Options{Addr: srv.Addr()})
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	if err := c.Ping(context.Background()); !errors.Is(err, resp.ErrClosed) {
+		t.Fatalf("Ping() after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestClientReportsUnreachableServer(t *testing.T) {
+	srv := newServer(t)
+	c := newClient(t, resp.Options{Addr: srv.Addr(), DialTimeout: 100 * time.Millisecond})
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Close()
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatal("Ping() after the server stopped succeeded")
+	}
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatal("Ping() with no server to dial succeeded")
+	}
+}
@@ -0,0 +1,212 @@
+// Package resp implements the Redis serialization protocol (RESP2) and a small
+// pooled client for servers that speak it
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Reply values are decoded to these Go types:
+//
+//	simple string  Status
+//	error          Error
+//	integer        int64
+//	bulk string    string, or nil when null
+//	array          []any, or nil when null
+
+// Status is a simple string reply such as OK or PONG
+type Status string
+
+// Error is an error reply sent by the server. The connection remains usable
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// ErrNil is returned by the reply helpers when the server replied with a null
+var ErrNil = errors.New("resp: nil reply")
+
+// maxBulkLen guards against corrupt length prefixes
+const maxBulkLen = 512 << 20
+
+// WriteValue encodes v. Commands are arrays of bulk strings, so []string encodes as one
+func WriteValue(w *bufio.Writer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		_, err := w.WriteString("$-1\r\n")
+		return err
+	case Status:
+		return writeLine(w, '+', string(v))
+	case Error:
+		return writeLine(w, '-', string(v))
+	case int:
+		return writeLine(w, ':', strconv.Itoa(v))
+	case int64:
+		return writeLine(w, ':', strconv.FormatInt(v, 10))
+	case string:
+		return writeBulk(w, v)
+	case []byte:
+		return writeBulk(w, string(v))
+	case []string:
+		if err := writeLine(w, '*', strconv.Itoa(len(v))); err != nil {
+			return err
+		}
+		for _, s := range v {
+			if err := writeBulk(w, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if err := writeLine(w, '*', strconv.Itoa(len(v))); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := WriteValue(w, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("resp: cannot encode %T", v)
+	}
+}
+
+func writeLine(w *bufio.Writer, prefix byte, s string) error {
+	w.WriteByte(prefix)
+	w.WriteString(s)
+	_, err := w.WriteString("\r\n")
+	return err
+}
+
+func writeBulk(w *bufio.Writer, s string) error {
+	if err := writeLine(w, '$', strconv.Itoa(len(s))); err != nil {
+		return err
+	}
+	w.WriteString(s)
+	_, err := w.WriteString("\r\n")
+	return err
+}
+
+// ReadValue decodes one value. An error reply is returned as the value, not as err;
+// err is reserved for I/O and protocol failures that leave the connection unusable
+func ReadValue(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty line")
+	}
+
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return Status(body), nil
+	case '-':
+		return Error(body), nil
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("resp: bad integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := parseLen(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, errors.New("resp: bulk string not terminated by CRLF")
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := parseLen(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = ReadValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("resp: unexpected reply type %q", line[0])
+	}
+}
+
+// parseLen parses a bulk or array length; -1 means null
+func parseLen(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -1 || n > maxBulkLen {
+		return 0, fmt.Errorf("resp: bad length %q", s)
+	}
+	return n, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("resp: line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// String converts a bulk or simple string reply
+func String(v any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", ErrNil
+	case string:
+		return v, nil
+	case Status:
+		return string(v), nil
+	default:
+		return "", fmt.Errorf("resp: unexpected %T reply, want string", v)
+	}
+}
+
+// Int converts an integer reply
+func Int(v any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case nil:
+		return 0, ErrNil
+	case int64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("resp: unexpected %T reply, want integer", v)
+	}
+}
+
+// Values converts an array reply
+func Values(v any, err error) ([]any, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, ErrNil
+	case []any:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("resp: unexpected %T reply, want array", v)
+	}
+}
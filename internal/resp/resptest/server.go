@@ -0,0 +1,573 @@
+// Package resptest provides an in-process stand-in for a Redis server, so code that
+// speaks RESP can be exercised without an external dependency
+package resptest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hostaggr/internal/resp"
+)
+
+// HandlerFunc executes one command. args[0] is the upper-cased command name.
+// The returned value is encoded with resp.WriteValue
+type HandlerFunc func(s *Server, args []string) any
+
+// ScriptFunc stands in for a Lua script run with EVAL or EVALSHA. Like a HandlerFunc
+// it runs with the server lock held, so it executes atomically
+type ScriptFunc func(s *Server, keys, args []string) any
+
+// Server implements the subset of Redis commands hostaggr relies on, with key expiry
+// driven by a clock that tests can move forward
+type Server struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	data     map[string]item
+	password string
+	commands map[string]HandlerFunc
+	scripts  map[string]ScriptFunc // by SHA1 of the source
+	loaded   map[string]bool       // SHA1s known to EVALSHA
+	conns    map[net.Conn]struct{}
+	closed   bool
+
+	// Added to the wall clock by FastForward. Atomic so Now is usable inside handlers
+	offset atomic.Int64
+
+	wg sync.WaitGroup
+}
+
+type item struct {
+	value     string
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewServer starts a server on a random loopback port
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		ln:       ln,
+		data:     make(map[string]item),
+		commands: make(map[string]HandlerFunc),
+		scripts:  make(map[string]ScriptFunc),
+		loaded:   make(map[string]bool),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	for name, fn := range builtins {
+		s.commands[name] = fn
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the server and drops every client connection, as a crashed Redis would
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+// RequireAuth makes every connection authenticate with password before other commands
+func (s *Server) RequireAuth(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Handle registers or replaces a command. The handler runs with the server lock held,
+// so it executes atomically and may use the Lookup, Store and Delete helpers
+func (s *Server) Handle(name string, fn HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[strings.ToUpper(name)] = fn
+}
+
+// HandleScript registers fn as the implementation of the Lua script src, since the
+// stand-in cannot run Lua. Like a real server, EVALSHA only knows a script once it
+// has been sent with EVAL or SCRIPT LOAD
+func (s *Server) HandleScript(src string, fn ScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[scriptSHA(src)] = fn
+}
+
+// FastForward moves the server clock forward, expiring keys as a real server would
+func (s *Server) FastForward(d time.Duration) {
+	s.offset.Add(int64(d))
+}
+
+// Now returns the server clock
+func (s *Server) Now() time.Time {
+	return time.Now().Add(time.Duration(s.offset.Load()))
+}
+
+// Get returns the live value of key, for assertions
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Lookup(key)
+}
+
+// TTL returns the remaining lifetime of key, zero if it has none, for assertions
+func (s *Server) TTL(key string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.lookupItem(key)
+	if !ok || it.expiresAt.IsZero() {
+		return 0, ok
+	}
+	return it.expiresAt.Sub(s.Now()), true
+}
+
+// Keys returns every live key, for assertions
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		if _, ok := s.lookupItem(k); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Lookup returns the live value of key. Only call it from a HandlerFunc
+func (s *Server) Lookup(key string) (string, bool) {
+	it, ok := s.lookupItem(key)
+	return it.value, ok
+}
+
+// Store sets key, expiring after ttl when it is positive. Only call it from a HandlerFunc
+func (s *Server) Store(key, value string, ttl time.Duration) {
+	it := item{value: value}
+	if ttl > 0 {
+		it.expiresAt = s.Now().Add(ttl)
+	}
+	s.data[key] = it
+}
+
+// Delete removes key, reporting whether it existed. Only call it from a HandlerFunc
+func (s *Server) Delete(key string) bool {
+	_, ok := s.lookupItem(key)
+	delete(s.data, key)
+	return ok
+}
+
+// lookupItem returns a live item, dropping it if it has expired
+func (s *Server) lookupItem(key string) (item, bool) {
+	it, ok := s.data[key]
+	if !ok {
+		return item{}, false
+	}
+	if !it.expiresAt.IsZero() && !s.Now().Before(it.expiresAt) {
+		delete(s.data, key)
+		return item{}, false
+	}
+	return it, true
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(c)
+	}
+}
+
+func (s *Server) handleConn(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	authed := false
+
+	for {
+		v, err := resp.ReadValue(r)
+		if err != nil {
+			return
+		}
+
+		args, err := commandArgs(v)
+		var reply any
+		if err != nil {
+			reply = resp.Error("ERR " + err.Error())
+		} else {
+			reply = s.exec(args, &authed)
+		}
+
+		if err := resp.WriteValue(w, reply); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// exec runs one command under the server lock
+func (s *Server) exec(args []string, authed *bool) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	args[0] = strings.ToUpper(args[0])
+
+	if args[0] == "AUTH" {
+		if len(args) != 2 {
+			return wrongArgs(args[0])
+		}
+		if s.password == "" || args[1] != s.password {
+			return resp.Error("WRONGPASS invalid password")
+		}
+		*authed = true
+		return resp.Status("OK")
+	}
+	if s.password != "" && !*authed {
+		return resp.Error("NOAUTH Authentication required.")
+	}
+
+	fn, ok := s.commands[args[0]]
+	if !ok {
+		return resp.Error("ERR unknown command '" + args[0] + "'")
+	}
+	return fn(s, args)
+}
+
+// commandArgs checks that a request is an array of bulk strings
+func commandArgs(v any) ([]string, error) {
+	items, ok := v.([]any)
+	if !ok || len(items) == 0 {
+		return nil, errors.New("command must be a non-empty array")
+	}
+
+	args := make([]string, len(items))
+	for i, it := range items {
+		s, ok := it.(string)
+		if !ok {
+			return nil, errors.New("command arguments must be bulk strings")
+		}
+		args[i] = s
+	}
+	return args, nil
+}
+
+func wrongArgs(cmd string) resp.Error {
+	return resp.Error("ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command")
+}
+
+var builtins = map[string]HandlerFunc{
+	"PING": func(s *Server, args []string) any {
+		if len(args) > 1 {
+			return args[1]
+		}
+		return resp.Status("PONG")
+	},
+	"SELECT": func(s *Server, args []string) any {
+		if len(args) != 2 {
+			return wrongArgs(args[0])
+		}
+		return resp.Status("OK")
+	},
+	"GET": func(s *Server, args []string) any {
+		if len(args) != 2 {
+			return wrongArgs(args[0])
+		}
+		if v, ok := s.Lookup(args[1]); ok {
+			return v
+		}
+		return nil
+	},
+	"SET":    cmdSet,
+	"DEL":    cmdDel,
+	"EXISTS": cmdExists,
+	"PTTL":   cmdPTTL,
+	"SCAN":   cmdScan,
+	"EVAL": func(s *Server, args []string) any {
+		if len(args) < 3 {
+			return wrongArgs(args[0])
+		}
+		sha := scriptSHA(args[1])
+		s.loaded[sha] = true
+		return s.evalScript(sha, args[2:])
+	},
+	"EVALSHA": func(s *Server, args []string) any {
+		if len(args) < 3 {
+			return wrongArgs(args[0])
+		}
+		sha := strings.ToLower(args[1])
+		if !s.loaded[sha] {
+			return resp.Error("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		return s.evalScript(sha, args[2:])
+	},
+	"SCRIPT": func(s *Server, args []string) any {
+		if len(args) < 2 {
+			return wrongArgs(args[0])
+		}
+		switch strings.ToUpper(args[1]) {
+		case "LOAD":
+			if len(args) != 3 {
+				return wrongArgs(args[0])
+			}
+			sha := scriptSHA(args[2])
+			s.loaded[sha] = true
+			return sha
+		case "EXISTS":
+			found := make([]any, 0, len(args)-2)
+			for _, sha := range args[2:] {
+				if s.loaded[strings.ToLower(sha)] {
+					found = append(found, int64(1))
+				} else {
+					found = append(found, int64(0))
+				}
+			}
+			return found
+		case "FLUSH":
+			clear(s.loaded)
+			return resp.Status("OK")
+		}
+		return resp.Error("ERR only SCRIPT LOAD, EXISTS and FLUSH are supported")
+	},
+	"FLUSHDB": func(s *Server, args []string) any {
+		clear(s.data)
+		return resp.Status("OK")
+	},
+	"FLUSHALL": func(s *Server, args []string) any {
+		clear(s.data)
+		return resp.Status("OK")
+	},
+}
+
+// cmdSet implements SET key value [EX seconds | PX milliseconds] [NX | XX]
+func cmdSet(s *Server, args []string) any {
+	if len(args) < 3 {
+		return wrongArgs(args[0])
+	}
+
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return resp.Error("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return resp.Error("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			return resp.Error("ERR syntax error")
+		}
+	}
+
+	_, exists := s.lookupItem(args[1])
+	if (nx && exists) || (xx && !exists) {
+		return nil
+	}
+
+	s.Store(args[1], args[2], ttl)
+	return resp.Status("OK")
+}
+
+func cmdDel(s *Server, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args[0])
+	}
+	var n int64
+	for _, k := range args[1:] {
+		if s.Delete(k) {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(s *Server, args []string) any {
+	if len(args) < 2 {
+		return wrongArgs(args[0])
+	}
+	var n int64
+	for _, k := range args[1:] {
+		if _, ok := s.lookupItem(k); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdPTTL(s *Server, args []string) any {
+	if len(args) != 2 {
+		return wrongArgs(args[0])
+	}
+	it, ok := s.lookupItem(args[1])
+	switch {
+	case !ok:
+		return int64(-2)
+	case it.expiresAt.IsZero():
+		return int64(-1)
+	default:
+		return it.expiresAt.Sub(s.Now()).Milliseconds()
+	}
+}
+
+// evalScript runs a registered script. args is numkeys followed by the keys and arguments
+func (s *Server) evalScript(sha string, args []string) any {
+	fn, ok := s.scripts[sha]
+	if !ok {
+		return resp.Error("ERR script " + sha + " has no stand-in implementation")
+	}
+
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 0 || numKeys > len(args)-1 {
+		return resp.Error("ERR Number of keys can't be greater than number of args")
+	}
+	return fn(s, args[1:1+numKeys], args[1+numKeys:])
+}
+
+// scriptSHA returns the hex SHA1 Redis identifies a script by
+func scriptSHA(src string) string {
+	sum := sha1.Sum([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+// cmdScan implements SCAN cursor [MATCH pattern] [COUNT count]. The cursor is an
+// offset into the sorted live keys, so keys added or removed during an iteration
+// may shift others past it
+func cmdScan(s *Server, args []string) any {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return wrongArgs(args[0])
+	}
+	cursor, err := strconv.Atoi(args[1])
+	if err != nil || cursor < 0 {
+		return resp.Error("ERR invalid cursor")
+	}
+
+	pattern, count := "*", 10
+	for i := 2; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count <= 0 {
+				return resp.Error("ERR syntax error")
+			}
+		default:
+			return resp.Error("ERR syntax error")
+		}
+	}
+
+	keys := make([]string, 0, len(s.data))
+	for k := range s.data {
+		if _, ok := s.lookupItem(k); ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	end := min(cursor+count, len(keys))
+	matched := make([]any, 0, count)
+	for i := min(cursor, end); i < end; i++ {
+		if globMatch(pattern, keys[i]) {
+			matched = append(matched, keys[i])
+		}
+	}
+
+	next := strconv.Itoa(end)
+	if end >= len(keys) {
+		next = "0"
+	}
+	return []any{next, matched}
+}
+
+// globMatch reports whether s matches a Redis glob pattern. It supports *, ? and
+// backslash escapes; character classes are not needed by hostaggr
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
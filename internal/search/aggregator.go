@@ -3,181 +3,451 @@ package search
 import (
 	"context"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/sync/errgroup"
-
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/matching"
 	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
 	"hostaggr/internal/providers"
 )
 
 // Aggregator coordinates searches across multiple providers
 type Aggregator struct {
-	providers []providers.Provider
-	cache     *Cache
+	providers       []providers.Provider
+	upstreams       []*upstream
+	cache           ResultCache
+	normalizer      *normalizer
+	fx              *currency.Converter
+	matcher         *matching.Matcher
+	providerTimeout time.Duration
+	breakerEnabled  bool
+	metrics         *obs.Metrics
+
+	// Identical concurrent searches share one provider fan-out
+	flights flightGroup
+
+	// Counts searches for the cache warmer, nil unless one is attached
+	searches *searchTracker
+
+	// Tracks fan-outs, which may outlive the searches that started them
+	background sync.WaitGroup
+}
+
+// NewAggregator creates a new Aggregator instance. Prices are converted with fx and
+// offers are matched to properties with matcher
+func NewAggregator(provs []providers.Provider, cache ResultCache, fx *currency.Converter, matcher *matching.Matcher, cfg config.AggregatorConfig, metrics *obs.Metrics) *Aggregator {
+	a := &Aggregator{
+		providers:       provs,
+		cache:           cache,
+		normalizer:      newNormalizer(cfg.CityAliases, fx.Default()),
+		fx:              fx,
+		matcher:         matcher,
+		providerTimeout: cfg.ProviderTimeout,
+		breakerEnabled:  cfg.Breaker.Enabled,
+		metrics:         metrics,
+		flights:         flightGroup{flights: make(map[cacheKey]*flight)},
+	}
+
+	var hedges *hedgeLimiter
+	if cfg.Hedge.Enabled {
+		hedges = newHedgeLimiter(cfg.Hedge.MaxPerSecond)
+	}
+
+	for _, p := range provs {
+		a.upstreams = append(a.upstreams, newUpstream(p, cfg, hedges, metrics))
+	}
+
+	return a
 }
 
-// NewAggregator creates a new Aggregator instance
-func NewAggregator(provs []providers.Provider, cache *Cache) *Aggregator {
-	return &Aggregator{
-		providers: provs,
-		cache:     cache,
+// ProviderHealth returns the circuit breaker snapshot of every provider, keyed by name
+// Returns nil when circuit breaking is disabled
+func (a *Aggregator) ProviderHealth() map[string]BreakerSnapshot {
+	if !a.breakerEnabled {
+		return nil
+	}
+
+	health := make(map[string]BreakerSnapshot, len(a.upstreams))
+	for _, u := range a.upstreams {
+		health[u.name] = u.breaker.Snapshot()
 	}
+	return health
 }
 
-// Search performs an aggregated search across all providers
+// breakerStates returns the current breaker state name of every provider for Stats
+func (a *Aggregator) breakerStates() map[string]string {
+	if !a.breakerEnabled {
+		return nil
+	}
+
+	states := make(map[string]string, len(a.upstreams))
+	for _, u := range a.upstreams {
+		states[u.name] = u.breaker.State().String()
+	}
+	return states
+}
+
+// Normalize returns the canonical form of req that Search uses for cache keys and
+// provider calls. Errors wrap ErrInvalidSearch
+func (a *Aggregator) Normalize(req models.SearchRequest) (models.SearchRequest, error) {
+	return a.normalizer.normalize(req)
+}
+
+// NormalizeCity returns the canonical form of a city name that cache keys use,
+// resolving aliases, so that operators can select entries the way users search
+func (a *Aggregator) NormalizeCity(city string) string {
+	return a.normalizer.city(city)
+}
+
+// Search performs an aggregated search across all providers. req is normalized first,
+// so equivalent spellings of a search share its cache entries. An invalid request
+// returns an error wrapping ErrInvalidSearch
 func (a *Aggregator) Search(ctx context.Context, req models.SearchRequest) (models.SearchResponse, error) {
 	startTime := time.Now()
 
+	city := displayCity(req.City)
+	req, err := a.Normalize(req)
+	if err != nil {
+		return models.SearchResponse{}, err
+	}
+	a.recordSearch(req)
+
 	// Check cache first
-	if a.cache != nil {
-		if cachedHotels, hit := a.cache.Get(req); hit {
-			// Build response from cached hotels
-			response := models.SearchResponse{
-				Search: models.SearchInfo{
-					City:    req.City,
-					CheckIn: req.CheckIn,
-					Nights:  req.Nights,
-					Adults:  req.Adults,
-				},
-				Stats: models.Stats{
-					ProvidersTotal:     len(a.providers),
-					ProvidersSucceeded: 0,
-					ProvidersFailed:    0,
-					Cache:              "hit",
-					DurationMs:         time.Since(startTime).Milliseconds(),
-				},
-				Hotels: cachedHotels,
-			}
-			return response, nil
-		}
+	fallback, hit := a.lookup(req)
+	if hit != "" {
+		return a.cachedResponse(req, city, fallback, hit, startTime), nil
 	}
 
-	// Query all providers concurrently
-	providerHotels, succeeded, failed := a.queryProviders(ctx, req)
+	// Join the provider fan-out of an identical in-flight search, or start one
+	f, shared := a.joinFlight(req)
 
-	// Validate hotels
-	validHotels := make([]models.ProviderHotel, 0)
-	for _, hotel := range providerHotels {
-		if a.isValidHotel(hotel, req) {
-			validHotels = append(validHotels, hotel)
-		}
+	var budget <-chan time.Time
+	if req.MaxWait > 0 {
+		timer := time.NewTimer(req.MaxWait)
+		defer timer.Stop()
+		budget = timer.C
 	}
 
-	// Deduplicate and select best prices
-	deduplicatedHotels := a.deduplicateHotels(validHotels)
-
-	// Sort by price ascending
-	sort.Slice(deduplicatedHotels, func(i, j int) bool {
-		return deduplicatedHotels[i].Price < deduplicatedHotels[j].Price
+	result := newFanOutResult(a.upstreams)
+	a.follow(ctx, f, budget, func(o providerOutcome) error {
+		result.add(o)
+		return nil
 	})
 
+	// With a latency budget the stragglers keep running so they can fill the cache
+	a.leaveFlight(f, req.MaxWait > 0)
+
+	hotels := a.buildHotels(result.hotels, req)
+	cacheState := cacheMiss
+
+	// Every provider failed: a stale result beats an empty one
+	if fallback != nil && result.done() && result.succeeded == 0 {
+		hotels = fallback
+		cacheState = cacheStaleError
+		a.metrics.CacheStaleServed("error")
+	}
+
 	// Build response
 	response := models.SearchResponse{
-		Search: models.SearchInfo{
-			City:    req.City,
-			CheckIn: req.CheckIn,
-			Nights:  req.Nights,
-			Adults:  req.Adults,
-		},
+		Search: searchInfo(req, city),
 		Stats: models.Stats{
 			ProvidersTotal:     len(a.providers),
-			ProvidersSucceeded: succeeded,
-			ProvidersFailed:    failed,
-			Cache:              "miss",
+			ProvidersSucceeded: result.succeeded,
+			ProvidersFailed:    result.failed,
+			ProvidersSkipped:   result.skipped,
+			ProvidersCached:    result.cached,
+			ProvidersPending:   result.pendingNames(),
+			Retries:            result.retries,
+			Hedges:             result.hedges,
+			Breakers:           a.breakerStates(),
+			Cache:              cacheState,
+			Coalesced:          shared,
 			DurationMs:         time.Since(startTime).Milliseconds(),
 		},
-		Hotels: deduplicatedHotels,
+		Hotels: shapeHotels(hotels, req),
 	}
 
-	// Cache the result
-	if a.cache != nil {
-		a.cache.Set(req, deduplicatedHotels)
+	a.metrics.ObserveHotelsReturned(len(hotels))
+	return response, nil
+}
+
+// Warm fills the cache for req unless it already holds a fresh result, waiting for
+// the provider fan-out to finish. It reports whether providers were queried. Warm
+// searches are not counted as traffic
+func (a *Aggregator) Warm(ctx context.Context, req models.SearchRequest) (bool, error) {
+	if a.cache == nil {
+		return false, nil
 	}
 
-	return response, nil
+	req, err := a.Normalize(req)
+	if err != nil {
+		return false, err
+	}
+	if _, freshness, found := a.cache.Get(req); found && freshness == Fresh {
+		return false, nil
+	}
+
+	f, _ := a.joinFlight(req)
+	_, err = a.follow(ctx, f, nil, func(providerOutcome) error { return nil })
+	a.leaveFlight(f, false)
+
+	return true, err
 }
 
-// queryProviders queries all providers concurrently with timeout and error handling
-func (a *Aggregator) queryProviders(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, int, int) {
-	// Create context with 2-second timeout
-	queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
+// trackSearches counts every later search in t. It must be called before the
+// aggregator serves searches
+func (a *Aggregator) trackSearches(t *searchTracker) {
+	a.searches = t
+}
 
-	g, gCtx := errgroup.WithContext(queryCtx)
+// recordSearch counts a search for the cache warmer
+func (a *Aggregator) recordSearch(req models.SearchRequest) {
+	if a.searches != nil {
+		a.searches.record(req)
+	}
+}
 
-	var mu sync.Mutex
-	var allHotels []models.ProviderHotel
-	succeeded := 0
-	failed := 0
+// Values of models.Stats.Cache
+const (
+	cacheHit        = "hit"
+	cacheStale      = "stale"
+	cacheStaleError = "stale-error"
+	cacheMiss       = "miss"
+)
 
-	for _, provider := range a.providers {
-		p := provider
-		g.Go(func() error {
-			hotels, err := p.Search(gCtx, req)
+// lookup checks the cache. A fresh entry is a hit; a stale entry is a hit that also
+// starts a background refresh. Otherwise state is empty and hotels, if not nil, may
+// stand in for the result should every provider fail
+func (a *Aggregator) lookup(req models.SearchRequest) ([]models.Hotel, string) {
+	if a.cache == nil {
+		return nil, ""
+	}
 
-			mu.Lock()
-			defer mu.Unlock()
+	hotels, freshness, found := a.cache.Get(req)
+	if !found {
+		return nil, ""
+	}
 
-			if err != nil {
-				failed++
-				return nil
-			}
+	switch freshness {
+	case Fresh:
+		return hotels, cacheHit
+	case Stale:
+		a.revalidate(req)
+		return hotels, cacheStale
+	default:
+		return hotels, ""
+	}
+}
 
-			succeeded++
-			allHotels = append(allHotels, hotels...)
-			return nil
-		})
+// revalidate refreshes a cache entry in the background. It shares the flight of any
+// identical search in progress, so concurrent stale hits trigger a single refresh
+func (a *Aggregator) revalidate(req models.SearchRequest) {
+	f, _ := a.joinFlight(req)
+	a.leaveFlight(f, true)
+}
+
+// cachedResponse builds the response for a cache hit
+func (a *Aggregator) cachedResponse(req models.SearchRequest, city string, hotels []models.Hotel, cacheState string, startTime time.Time) models.SearchResponse {
+	a.metrics.ObserveHotelsReturned(len(hotels))
+
+	return models.SearchResponse{
+		Search: searchInfo(req, city),
+		Stats: models.Stats{
+			ProvidersTotal:     len(a.providers),
+			ProvidersSucceeded: 0,
+			ProvidersFailed:    0,
+			Breakers:           a.breakerStates(),
+			Cache:              cacheState,
+			DurationMs:         time.Since(startTime).Milliseconds(),
+		},
+		Hotels: shapeHotels(hotels, req),
 	}
+}
 
-	_ = g.Wait()
+// searchInfo echoes the search parameters in the response. city is the city as the
+// user wrote it, since req holds its folded cache form
+func searchInfo(req models.SearchRequest, city string) models.SearchInfo {
+	return models.SearchInfo{
+		City:     city,
+		CheckIn:  req.CheckIn,
+		Nights:   req.Nights,
+		Adults:   req.Adults,
+		Currency: req.Currency,
+	}
+}
 
-	return allHotels, succeeded, failed
+// Shutdown waits for in-flight provider fan-outs to finish, including those left
+// running by partial searches, or for ctx to expire
+func (a *Aggregator) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// buildHotels validates raw provider hotels, matches them to properties, prices them
+// for the whole stay in the requested currency, keeps the best offer per property
+// and sorts by price
+func (a *Aggregator) buildHotels(providerHotels []models.ProviderHotel, req models.SearchRequest) []models.Hotel {
+	// Validate hotels
+	validHotels := make([]models.ProviderHotel, 0)
+	for _, hotel := range providerHotels {
+		if a.isValidHotel(hotel, req) {
+			validHotels = append(validHotels, hotel)
+		}
+	}
+
+	// Providers number hotels in their own namespaces
+	matches := a.matcher.Match(validHotels, a.normalizer.city)
+
+	// Normalize before comparing, so nightly and stay prices, or prices in
+	// different currencies, are never compared as plain numbers
+	offers := a.priceOffers(validHotels, matches, req)
+
+	// Deduplicate and select best prices
+	deduplicatedHotels := a.deduplicateHotels(offers)
+
+	// Sort by price ascending, then by ID so equal prices keep a stable order
+	sort.Slice(deduplicatedHotels, func(i, j int) bool {
+		pi, pj := deduplicatedHotels[i].Price, deduplicatedHotels[j].Price
+		if pi != pj {
+			return pi.Less(pj)
+		}
+		return deduplicatedHotels[i].HotelID < deduplicatedHotels[j].HotelID
+	})
+
+	return deduplicatedHotels
 }
 
 // isValidHotel validates a hotel against the search request
 func (a *Aggregator) isValidHotel(h models.ProviderHotel, req models.SearchRequest) bool {
 
-	if h.HotelID == "" || h.Name == "" || h.City == "" || h.Currency == "" {
+	if h.HotelID == "" || h.Name == "" || h.City == "" {
 		return false
 	}
 
-	if h.Price <= 0 {
+	// A zero Money has no currency, so this also rejects unpriced hotels
+	if h.Price.IsZero() || h.Price.Sign() <= 0 {
 		return false
 	}
 
-	if !strings.EqualFold(h.City, req.City) {
+	// req is normalized, so compare the provider's city in the same form
+	if a.normalizer.city(h.City) != req.City {
 		return false
 	}
 
-	return true
-}
+	// A quote for another length of stay is not comparable
+	if h.Nights != 0 && h.Nights != req.Nights {
+		return false
+	}
 
-// deduplicateHotels removes duplicates by hotel_id, keeping the lowest price
-func (a *Aggregator) deduplicateHotels(hotels []models.ProviderHotel) []models.Hotel {
-	bestPrices := make(map[string]models.Hotel)
+	if !validPricing(h) {
+		return false
+	}
 
-	for _, ph := range hotels {
-		existing, exists := bestPrices[ph.HotelID]
+	return true
+}
 
-		hotel := models.Hotel{
-			HotelID:  ph.HotelID,
-			Name:     ph.Name,
-			Currency: ph.Currency,
-			Price:    ph.Price,
+// priceOffers prices valid hotels for the whole stay in the requested currency,
+// under the property each was matched to, keeping the quoted price alongside.
+// Offers that cannot be converted, for lack of a rate, are dropped rather than
+// compared unconverted
+func (a *Aggregator) priceOffers(hotels []models.ProviderHotel, matches []matching.Result, req models.SearchRequest) []models.Hotel {
+	// Without rates, offers already in the target currency still convert
+	rates, _ := a.fx.Rates()
+
+	offers := make([]models.Hotel, 0, len(hotels))
+	for i, h := range hotels {
+		stay, err := stayPrice(h, req.Nights, req.Adults)
+		if err != nil {
+			continue
+		}
+		offer, err := stay.convert(rates, req.Currency, req.Nights)
+		if err != nil {
+			a.metrics.FXConversionFailed(h.Price.Currency(), req.Currency)
+			continue
 		}
 
-		if !exists || hotel.Price < existing.Price {
-			bestPrices[ph.HotelID] = hotel
+		offer.HotelID = matches[i].PropertyID
+		offer.Name = h.Name
+		if matches[i].Name != "" {
+			offer.Name = matches[i].Name
 		}
+		offer.MatchConfidence = matches[i].Confidence
+		offer.OriginalPrice = h.Price
+		offer.OriginalBasis = h.Basis
+		offer.Offers = []models.Offer{{
+			Provider:        h.Provider,
+			HotelID:         h.HotelID,
+			Price:           offer.Price,
+			NightlyPrice:    offer.NightlyPrice,
+			OriginalPrice:   h.Price,
+			OriginalBasis:   h.Basis,
+			MatchConfidence: matches[i].Confidence,
+			FetchedAt:       h.FetchedAt,
+		}}
+		offers = append(offers, offer)
 	}
+	return offers
+}
 
-	result := make([]models.Hotel, 0, len(bestPrices))
-	for _, hotel := range bestPrices {
-		result = append(result, hotel)
+// deduplicateHotels merges the offers of every property. The hotel takes the prices
+// of its cheapest offer and the confidence of its weakest match, and lists every
+// offer cheapest first. Offers are all in the requested currency by now
+func (a *Aggregator) deduplicateHotels(hotels []models.Hotel) []models.Hotel {
+	properties := make(map[string][]models.Hotel)
+	for _, hotel := range hotels {
+		properties[hotel.HotelID] = append(properties[hotel.HotelID], hotel)
+	}
+
+	result := make([]models.Hotel, 0, len(properties))
+	for _, group := range properties {
+		// Equal prices keep a stable order by provider
+		sort.SliceStable(group, func(i, j int) bool {
+			pi, pj := group[i].Price, group[j].Price
+			if pi != pj {
+				return pi.Less(pj)
+			}
+			oi, oj := group[i].Offers[0], group[j].Offers[0]
+			if oi.Provider != oj.Provider {
+				return oi.Provider < oj.Provider
+			}
+			return oi.HotelID < oj.HotelID
+		})
+
+		best := group[0]
+		best.Offers = make([]models.Offer, 0, len(group))
+		for _, h := range group {
+			best.MatchConfidence = min(best.MatchConfidence, h.MatchConfidence)
+			best.Offers = append(best.Offers, h.Offers...)
+		}
+		best.Offers[0].Best = true
+
+		// Offers share a currency, so the subtraction cannot fail
+		best.PriceSpread, _ = group[len(group)-1].Price.Sub(best.Price)
+
+		result = append(result, best)
 	}
 
 	return result
 }
+
+// shapeHotels returns hotels as req asks for them: with every offer, or only the
+// best. Cached hotels are shared, so they are copied rather than modified
+func shapeHotels(hotels []models.Hotel, req models.SearchRequest) []models.Hotel {
+	if req.Offers == models.OffersBest {
+		return models.BestOffersOnly(hotels)
+	}
+	return hotels
+}
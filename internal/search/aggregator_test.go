@@ -0,0 +1,498 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/providers"
+)
+
+// stubProvider answers every search with one hotel of its own in the searched city.
+// While gate is set, calls wait for it to be closed or for their context to end
+type stubProvider struct {
+	name  string
+	gate  chan struct{}
+	calls atomic.Int32
+
+	// Calls in progress and the most seen at once
+	active atomic.Int32
+	peak   atomic.Int32
+
+	mu  sync.Mutex
+	err error // returned instead of the hotel while set
+}
+
+func newStubProvider(name string) *stubProvider {
+	return &stubProvider{name: name}
+}
+
+// gated makes later calls wait until the returned function opens the gate
+func (p *stubProvider) gated() (open func()) {
+	p.gate = make(chan struct{})
+	return sync.OnceFunc(func() { close(p.gate) })
+}
+
+func (p *stubProvider) fail(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+func (p *stubProvider) Name() string               { return p.name }
+func (p *stubProvider) Pricing() providers.Pricing { return providers.Pricing{Basis: models.PerNight} }
+
+func (p *stubProvider) Search(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, error) {
+	p.calls.Add(1)
+	n := p.active.Add(1)
+	defer p.active.Add(-1)
+	for peak := p.peak.Load(); n > peak && !p.peak.CompareAndSwap(peak, n); peak = p.peak.Load() {
+	}
+
+	if p.gate != nil {
+		select {
+		case <-p.gate:
+		case <-ctx.Done():
+			return nil, providers.NewError(p.name, providers.KindCanceled, ctx.Err())
+		}
+	}
+
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.ProviderHotel{{
+		HotelID: "H-" + p.name,
+		Name:    p.name + " Palace",
+		City:    req.City,
+		Price:   currency.MustParse("100.00", "EUR"),
+	}}, nil
+}
+
+// noRates is a rate source without rates, which still prices EUR offers in EUR
+type noRates struct{}
+
+func (noRates) Rates() (*currency.Rates, error) { return nil, nil }
+func (noRates) Stop()                           {}
+
+// newTestAggregator returns an aggregator over provs without breakers, retries,
+// hedging or matching, so every provider is called once per fan-out
+func newTestAggregator(t *testing.T, cache ResultCache, provs ...providers.Provider) *Aggregator {
+	t.Helper()
+	fx, err := currency.NewConverterWithSource(noRates{}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default().Aggregator
+	cfg.Breaker.Enabled = false
+	cfg.Retry.Enabled = false
+	cfg.Hedge.Enabled = false
+
+	a := NewAggregator(provs, cache, fx, nil, cfg, obs.NewMetrics())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := a.Shutdown(ctx); err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+	})
+	return a
+}
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	c, err := NewCache(config.Default().Cache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	return c
+}
+
+// testClock is a clock stopped at a fixed instant, safe to read from the fan-out
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2026, 11, 2, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newClockedCache returns a cache with the default windows that reads the time
+// from the returned clock: fresh for 30s, stale for 30s more and usable on
+// errors for 5m past the TTL
+func newClockedCache(t *testing.T) (*Cache, *testClock) {
+	t.Helper()
+	clock := newTestClock()
+	c, err := NewCacheWithClock(config.Default().Cache, nil, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	return c, clock
+}
+
+func testSearch() models.SearchRequest {
+	return models.SearchRequest{City: "Marrakech", CheckIn: "2026-11-20", Nights: 2, Adults: 2}
+}
+
+// cachedHotels returns the aggregated entry for req, if any
+func cachedHotels(t *testing.T, a *Aggregator, req models.SearchRequest) ([]models.Hotel, Freshness, bool) {
+	t.Helper()
+	req, err := a.Normalize(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.cache.Get(req)
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitCoalesced waits until n searches have joined another's flight
+func waitCoalesced(t *testing.T, a *Aggregator, n int) {
+	t.Helper()
+	waitFor(t, "searches to coalesce", func() bool {
+		return a.metrics.Registry().Sum("hostaggr_search_coalesced_total") == float64(n)
+	})
+}
+
+func waitShutdown(t *testing.T, a *Aggregator) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != nil {
+		t.Fatalf("fan-outs still running: %v", err)
+	}
+}
+
+func TestSearchMaxWaitReturnsPartialResults(t *testing.T) {
+	fast, slow := newStubProvider("Fast"), newStubProvider("Slow")
+	open := slow.gated()
+	defer open()
+	a := newTestAggregator(t, newTestCache(t), fast, slow)
+
+	req := testSearch()
+	req.MaxWait = 20 * time.Millisecond
+	resp, err := a.Search(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Stats.ProvidersSucceeded != 1 || resp.Stats.ProvidersFailed != 0 || !slices.Equal(resp.Stats.ProvidersPending, []string{"Slow"}) {
+		t.Fatalf("stats %+v, want Fast succeeded and Slow pending", resp.Stats)
+	}
+	if len(resp.Hotels) != 1 || resp.Hotels[0].Offers[0].Provider != "Fast" {
+		t.Fatalf("hotels %+v, want Fast's hotel only", resp.Hotels)
+	}
+	if _, _, found := cachedHotels(t, a, req); found {
+		t.Fatal("a partial result was cached")
+	}
+
+	// The fan-out outlives the search and caches the complete result
+	open()
+	waitShutdown(t, a)
+	hotels, freshness, found := cachedHotels(t, a, req)
+	if !found || freshness != Fresh || len(hotels) != 2 {
+		t.Fatalf("cached %d hotels, %v, %t, want both providers' hotels fresh", len(hotels), freshness, found)
+	}
+	if slow.calls.Load() != 1 {
+		t.Fatalf("Slow called %d times, want 1", slow.calls.Load())
+	}
+}
+
+type searchResult struct {
+	resp models.SearchResponse
+	err  error
+}
+
+func startSearch(ctx context.Context, a *Aggregator, req models.SearchRequest) <-chan searchResult {
+	done := make(chan searchResult, 1)
+	go func() {
+		resp, err := a.Search(ctx, req)
+		done <- searchResult{resp, err}
+	}()
+	return done
+}
+
+func TestSearchCoalescesIdenticalSearches(t *testing.T) {
+	p := newStubProvider("Mock1")
+	open := p.gated()
+	defer open()
+	a := newTestAggregator(t, newTestCache(t), p)
+
+	const n = 8
+	var results []<-chan searchResult
+	for range n {
+		results = append(results, startSearch(context.Background(), a, testSearch()))
+	}
+	waitCoalesced(t, a, n-1)
+	open()
+
+	coalesced := 0
+	for _, done := range results {
+		r := <-done
+		if r.err != nil || r.resp.Stats.ProvidersSucceeded != 1 || len(r.resp.Hotels) != 1 {
+			t.Fatalf("Search() = %+v, %v", r.resp.Stats, r.err)
+		}
+		if r.resp.Stats.Coalesced {
+			coalesced++
+		}
+	}
+	if got := p.calls.Load(); got != 1 {
+		t.Fatalf("provider called %d times for %d identical searches, want 1", got, n)
+	}
+	if coalesced != n-1 {
+		t.Fatalf("%d responses marked coalesced, want %d", coalesced, n-1)
+	}
+}
+
+func TestSearchFollowerOutlivesCancelledLeader(t *testing.T) {
+	p := newStubProvider("Mock1")
+	open := p.gated()
+	defer open()
+	a := newTestAggregator(t, newTestCache(t), p)
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := startSearch(leaderCtx, a, testSearch())
+	waitFor(t, "the fan-out to start", func() bool { return p.calls.Load() == 1 })
+	follower := startSearch(context.Background(), a, testSearch())
+	waitCoalesced(t, a, 1)
+
+	// The leader giving up must not cancel the fan-out the follower waits on
+	cancelLeader()
+	if r := <-leader; r.resp.Stats.ProvidersSucceeded != 0 {
+		t.Fatalf("cancelled leader got %+v", r.resp.Stats)
+	}
+	open()
+
+	r := <-follower
+	if r.err != nil || r.resp.Stats.ProvidersSucceeded != 1 || r.resp.Stats.ProvidersFailed != 0 || len(r.resp.Hotels) != 1 {
+		t.Fatalf("follower got %+v, %v, want the provider's result", r.resp.Stats, r.err)
+	}
+	if got := p.calls.Load(); got != 1 {
+		t.Fatalf("provider called %d times, want 1", got)
+	}
+	waitShutdown(t, a)
+	if _, _, found := cachedHotels(t, a, testSearch()); !found {
+		t.Fatal("the result the follower waited for was not cached")
+	}
+}
+
+func TestSearchLastWaiterLeavingCancelsFanOut(t *testing.T) {
+	p := newStubProvider("Mock1")
+	open := p.gated()
+	defer open()
+	a := newTestAggregator(t, newTestCache(t), p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := startSearch(ctx, a, testSearch())
+	waitFor(t, "the fan-out to start", func() bool { return p.calls.Load() == 1 })
+	second := startSearch(ctx, a, testSearch())
+	waitCoalesced(t, a, 1)
+
+	cancel()
+	<-first
+	<-second
+
+	// Nobody is left waiting, so the provider call is cancelled without the gate opening
+	waitShutdown(t, a)
+	if _, _, found := cachedHotels(t, a, testSearch()); found {
+		t.Fatal("a cancelled fan-out was cached")
+	}
+
+	// The next search starts a flight of its own
+	open()
+	if r := <-startSearch(context.Background(), a, testSearch()); r.resp.Stats.ProvidersSucceeded != 1 || r.resp.Stats.Coalesced {
+		t.Fatalf("Search() after the cancelled flight = %+v", r.resp.Stats)
+	}
+	if got := p.calls.Load(); got != 2 {
+		t.Fatalf("provider called %d times, want 2", got)
+	}
+}
+
+func TestSearchDoesNotCachePartialFailures(t *testing.T) {
+	good, bad := newStubProvider("Good"), newStubProvider("Bad")
+	bad.fail(providers.NewError("Bad", providers.KindPermanent, errProvider))
+	a := newTestAggregator(t, newTestCache(t), good, bad)
+
+	resp, err := a.Search(context.Background(), testSearch())
+	if err != nil || resp.Stats.ProvidersSucceeded != 1 || resp.Stats.ProvidersFailed != 1 {
+		t.Fatalf("Search() = %+v, %v", resp.Stats, err)
+	}
+	waitShutdown(t, a)
+	if _, _, found := cachedHotels(t, a, testSearch()); found {
+		t.Fatal("a partial result was cached as a whole")
+	}
+
+	// The provider that answered is reused; only the failed one is asked again
+	resp, _ = a.Search(context.Background(), testSearch())
+	if resp.Stats.ProvidersCached != 1 || good.calls.Load() != 1 || bad.calls.Load() != 2 {
+		t.Fatalf("second search stats %+v, Good called %d times, Bad %d, want Good reused", resp.Stats, good.calls.Load(), bad.calls.Load())
+	}
+}
+
+func TestSearchServesStaleAndRevalidatesOnce(t *testing.T) {
+	cache, clock := newClockedCache(t)
+	p := newStubProvider("Mock1")
+	a := newTestAggregator(t, cache, p)
+
+	if _, err := a.Search(context.Background(), testSearch()); err != nil {
+		t.Fatal(err)
+	}
+	waitShutdown(t, a)
+
+	// Past the TTL but within stale-while-revalidate. With the provider stalled,
+	// stale hits can only be served from the cache
+	clock.Advance(45 * time.Second)
+	open := p.gated()
+	defer open()
+	for range 3 {
+		resp, err := a.Search(context.Background(), testSearch())
+		if err != nil || resp.Stats.Cache != cacheStale || len(resp.Hotels) != 1 {
+			t.Fatalf("Search() = %+v, %v, want the stale hotel", resp.Stats, err)
+		}
+	}
+
+	open()
+	waitShutdown(t, a)
+	if got := p.calls.Load(); got != 2 {
+		t.Fatalf("provider called %d times, want the first search and one refresh", got)
+	}
+	if _, freshness, found := cachedHotels(t, a, testSearch()); !found || freshness != Fresh {
+		t.Fatalf("after the refresh the entry is %v, %t, want fresh", freshness, found)
+	}
+}
+
+func TestSearchServesStaleOnlyWhenEveryProviderFails(t *testing.T) {
+	cache, clock := newClockedCache(t)
+	first, second := newStubProvider("First"), newStubProvider("Second")
+	a := newTestAggregator(t, cache, first, second)
+
+	if _, err := a.Search(context.Background(), testSearch()); err != nil {
+		t.Fatal(err)
+	}
+	waitShutdown(t, a)
+
+	// Past stale-while-revalidate, within stale-if-error
+	clock.Advance(2 * time.Minute)
+	first.fail(providers.NewError("First", providers.KindUnavailable, errProvider))
+	resp, _ := a.Search(context.Background(), testSearch())
+	if resp.Stats.Cache != cacheMiss || resp.Stats.ProvidersSucceeded != 1 || len(resp.Hotels) != 1 {
+		t.Fatalf("with one provider up got %+v and %d hotels, want the fresh partial result", resp.Stats, len(resp.Hotels))
+	}
+	waitShutdown(t, a)
+
+	// Second's own answer has expired from the provider tier too
+	clock.Advance(time.Minute)
+	second.fail(providers.NewError("Second", providers.KindUnavailable, errProvider))
+	resp, _ = a.Search(context.Background(), testSearch())
+	if resp.Stats.Cache != cacheStaleError || resp.Stats.ProvidersFailed != 2 || len(resp.Hotels) != 2 {
+		t.Fatalf("with every provider down got %+v and %d hotels, want the stale result", resp.Stats, len(resp.Hotels))
+	}
+}
+
+func TestSearchMissesPastEveryWindow(t *testing.T) {
+	cache, clock := newClockedCache(t)
+	p := newStubProvider("Mock1")
+	a := newTestAggregator(t, cache, p)
+
+	if _, err := a.Search(context.Background(), testSearch()); err != nil {
+		t.Fatal(err)
+	}
+	waitShutdown(t, a)
+
+	// Past the TTL and stale-if-error
+	clock.Advance(30*time.Second + 5*time.Minute + time.Second)
+	if _, _, found := cachedHotels(t, a, testSearch()); found {
+		t.Fatal("an entry past every window is still usable")
+	}
+
+	p.fail(providers.NewError("Mock1", providers.KindUnavailable, errProvider))
+	resp, _ := a.Search(context.Background(), testSearch())
+	if resp.Stats.Cache != cacheMiss || len(resp.Hotels) != 0 {
+		t.Fatalf("got %+v and %d hotels, want a miss without hotels", resp.Stats, len(resp.Hotels))
+	}
+}
+
+// hintedProvider is a stubProvider that declares how long its results stay valid
+type hintedProvider struct {
+	*stubProvider
+	freshFor time.Duration
+}
+
+func (p hintedProvider) FreshFor(models.SearchRequest) time.Duration { return p.freshFor }
+
+func TestSearchHonoursProviderFreshness(t *testing.T) {
+	cache, clock := newClockedCache(t)
+	plain := newStubProvider("Plain")
+	hinted := hintedProvider{newStubProvider("Hinted"), 2 * time.Minute}
+	volatile := hintedProvider{newStubProvider("Volatile"), -1}
+
+	// A failing provider keeps every search out of the aggregated tier, so each one
+	// goes to the provider tier
+	down := newStubProvider("Down")
+	down.fail(providers.NewError("Down", providers.KindUnavailable, errProvider))
+	a := newTestAggregator(t, cache, plain, hinted, volatile, down)
+
+	search := func() {
+		t.Helper()
+		a.Search(context.Background(), testSearch())
+		waitShutdown(t, a)
+	}
+	calls := func() [3]int32 {
+		return [3]int32{plain.calls.Load(), hinted.calls.Load(), volatile.calls.Load()}
+	}
+
+	search()
+	search()
+	if got := calls(); got != [3]int32{1, 1, 2} {
+		t.Fatalf("calls %v after two searches, want the volatile provider asked again", got)
+	}
+
+	// Past the configured 30s provider TTL, within the hinted 2m
+	clock.Advance(45 * time.Second)
+	search()
+	if got := calls(); got != [3]int32{2, 1, 3} {
+		t.Fatalf("calls %v after 45s, want the hinted provider still cached", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	search()
+	if got := calls(); got != [3]int32{3, 2, 4} {
+		t.Fatalf("calls %v after the hint expired, want every provider asked again", got)
+	}
+}
@@ -0,0 +1,237 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"hostaggr/internal/config"
+)
+
+// ErrBreakerOpen is returned by CircuitBreaker.Allow when calls are being short-circuited
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerSnapshot is a point-in-time view of a circuit breaker
+type BreakerSnapshot struct {
+	State               BreakerState
+	ConsecutiveFailures int
+	FailureRatio        float64
+	Requests            int
+	OpenUntil           time.Time
+}
+
+// CircuitBreaker tracks the recent outcomes of one provider and short-circuits calls
+// while the provider is considered unhealthy
+type CircuitBreaker struct {
+	mu  sync.Mutex
+	cfg config.BreakerConfig
+	now func() time.Time
+
+	state       BreakerState
+	openedAt    time.Time
+	consecutive int
+
+	// gen counts trips and resets. Outcomes of calls admitted in an earlier
+	// generation say nothing about the current one and are dropped
+	gen uint64
+
+	// Ring buffer of recent outcomes, true meaning failure
+	outcomes []bool
+	next     int
+	filled   int
+	failures int
+
+	// Half-open bookkeeping
+	probesInFlight int
+	probeSuccesses int
+
+	onStateChange func(from, to BreakerState)
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(cfg config.BreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cfg:      cfg,
+		now:      time.Now,
+		outcomes: make([]bool, cfg.WindowSize),
+	}
+}
+
+// BreakerTicket is one admission by Allow, handed back to Record with the call's outcome
+type BreakerTicket struct {
+	gen   uint64
+	probe bool
+}
+
+// Allow reports whether a call may proceed. Every nil return must be followed by exactly
+// one Record with the returned ticket
+func (b *CircuitBreaker) Allow() (BreakerTicket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cfg.CoolDown {
+			return BreakerTicket{}, ErrBreakerOpen
+		}
+		// Cool-down elapsed, start probing
+		b.setState(BreakerHalfOpen)
+		fallthrough
+
+	case BreakerHalfOpen:
+		if b.probesInFlight+b.probeSuccesses >= b.cfg.HalfOpenProbes {
+			return BreakerTicket{}, ErrBreakerOpen
+		}
+		b.probesInFlight++
+		return BreakerTicket{gen: b.gen, probe: true}, nil
+	}
+
+	return BreakerTicket{gen: b.gen}, nil
+}
+
+// Record reports the outcome of a call admitted by Allow. Cancellations by the caller
+// say nothing about provider health and are not counted, and neither are calls
+// admitted before the breaker last tripped or closed: a call admitted while closed
+// that finishes once the breaker is half-open is not a probe
+func (b *CircuitBreaker) Record(t BreakerTicket, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t.gen != b.gen {
+		return
+	}
+
+	ignored := errors.Is(err, context.Canceled)
+	failed := err != nil && !ignored
+
+	if t.probe {
+		b.probesInFlight--
+		switch {
+		case ignored:
+		case failed:
+			b.trip()
+		default:
+			b.probeSuccesses++
+			if b.probeSuccesses >= b.cfg.HalfOpenProbes {
+				b.reset()
+				b.setState(BreakerClosed)
+			}
+		}
+		return
+	}
+
+	if ignored || b.state != BreakerClosed {
+		return
+	}
+
+	b.push(failed)
+
+	if !failed {
+		b.consecutive = 0
+		return
+	}
+
+	b.consecutive++
+	if b.consecutive >= b.cfg.ConsecutiveFailures {
+		b.trip()
+		return
+	}
+	if b.filled >= b.cfg.MinRequests && float64(b.failures)/float64(b.filled) >= b.cfg.FailureRatio {
+		b.trip()
+	}
+}
+
+// State returns the current state, accounting for an elapsed cool-down
+func (b *CircuitBreaker) State() BreakerState {
+	return b.Snapshot().State
+}
+
+// Snapshot returns the current state and counters
+func (b *CircuitBreaker) Snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snap := BreakerSnapshot{
+		State:               b.state,
+		ConsecutiveFailures: b.consecutive,
+		Requests:            b.filled,
+	}
+	if b.filled > 0 {
+		snap.FailureRatio = float64(b.failures) / float64(b.filled)
+	}
+	if b.state == BreakerOpen {
+		snap.OpenUntil = b.openedAt.Add(b.cfg.CoolDown)
+		// An open breaker past its cool-down will admit the next call as a probe
+		if !b.now().Before(snap.OpenUntil) {
+			snap.State = BreakerHalfOpen
+		}
+	}
+	return snap
+}
+
+// push appends an outcome to the ring buffer. Caller must hold b.mu
+func (b *CircuitBreaker) push(failed bool) {
+	if b.filled == len(b.outcomes) {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+	} else {
+		b.filled++
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+}
+
+// trip opens the breaker. Caller must hold b.mu
+func (b *CircuitBreaker) trip() {
+	b.gen++
+	b.openedAt = b.now()
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+	b.setState(BreakerOpen)
+}
+
+// reset clears the outcome window. Caller must hold b.mu
+func (b *CircuitBreaker) reset() {
+	b.gen++
+	for i := range b.outcomes {
+		b.outcomes[i] = false
+	}
+	b.next, b.filled, b.failures, b.consecutive = 0, 0, 0, 0
+	b.probesInFlight, b.probeSuccesses = 0, 0
+}
+
+// setState changes state and notifies the observer. Caller must hold b.mu
+func (b *CircuitBreaker) setState(to BreakerState) {
+	from := b.state
+	b.state = to
+	if from != to && b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
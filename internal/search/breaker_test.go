@@ -0,0 +1,231 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+)
+
+var errProvider = errors.New("provider failed")
+
+// newTestBreaker returns a breaker reading the time from the returned clock
+func newTestBreaker(cfg config.BreakerConfig) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2026, 11, 2, 12, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(cfg)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func testBreakerConfig() config.BreakerConfig {
+	return config.BreakerConfig{
+		Enabled:             true,
+		ConsecutiveFailures: 3,
+		FailureRatio:        0.5,
+		MinRequests:         6,
+		WindowSize:          10,
+		CoolDown:            10 * time.Second,
+		HalfOpenProbes:      2,
+	}
+}
+
+// call runs one admitted call with outcome err, failing if the breaker refuses it
+func call(t *testing.T, b *CircuitBreaker, err error) {
+	t.Helper()
+	ticket, allowErr := b.Allow()
+	if allowErr != nil {
+		t.Fatalf("Allow() = %v in state %s", allowErr, b.State())
+	}
+	b.Record(ticket, err)
+}
+
+// trip opens b with consecutive failures
+func tripBreaker(t *testing.T, b *CircuitBreaker) {
+	t.Helper()
+	for range b.cfg.ConsecutiveFailures {
+		call(t, b, errProvider)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %s after %d failures, want open", b.State(), b.cfg.ConsecutiveFailures)
+	}
+}
+
+func TestBreakerTripsOnConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(testBreakerConfig())
+
+	call(t, b, errProvider)
+	call(t, b, errProvider)
+	call(t, b, nil) // a success resets the run
+	call(t, b, errProvider)
+	call(t, b, errProvider)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state %s, want closed", b.State())
+	}
+
+	call(t, b, errProvider)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %s after three failures in a row, want open", b.State())
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Allow() = %v while open, want ErrBreakerOpen", err)
+	}
+}
+
+func TestBreakerTripsOnFailureRatioAfterMinRequests(t *testing.T) {
+	b, _ := newTestBreaker(testBreakerConfig())
+
+	// Alternating outcomes never make three failures in a row, and the ratio only
+	// counts once MinRequests outcomes are in the window
+	for i := range 5 {
+		if i%2 == 0 {
+			call(t, b, errProvider)
+		} else {
+			call(t, b, nil)
+		}
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("state %s after 5 requests, want closed below MinRequests", b.State())
+	}
+
+	call(t, b, nil) // 6 requests, 3 failed: 0.5 but the last was a success
+	if b.State() != BreakerClosed {
+		t.Fatalf("state %s, want closed: ratios are checked on failures", b.State())
+	}
+	call(t, b, errProvider) // 7 requests, 4 failed
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %s at a failure ratio of 4/7, want open", b.State())
+	}
+}
+
+func TestBreakerCoolsDownIntoHalfOpen(t *testing.T) {
+	b, now := newTestBreaker(testBreakerConfig())
+	tripBreaker(t, b)
+
+	*now = now.Add(9 * time.Second)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %s before the cool-down, want open", b.State())
+	}
+	if snap := b.Snapshot(); !snap.OpenUntil.Equal(now.Add(time.Second)) {
+		t.Fatalf("OpenUntil %s, want %s", snap.OpenUntil, now.Add(time.Second))
+	}
+
+	*now = now.Add(time.Second)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s after the cool-down, want half-open", b.State())
+	}
+}
+
+func TestBreakerCapsProbes(t *testing.T) {
+	b, now := newTestBreaker(testBreakerConfig())
+	tripBreaker(t, b)
+	*now = now.Add(10 * time.Second)
+
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("third probe: Allow() = %v, want ErrBreakerOpen", err)
+	}
+
+	// A cancelled probe frees its slot without counting
+	b.Record(first, context.Canceled)
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("Allow() after a cancelled probe = %v", err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s, want half-open", b.State())
+	}
+}
+
+func TestBreakerClosesAfterProbeSuccesses(t *testing.T) {
+	b, now := newTestBreaker(testBreakerConfig())
+	tripBreaker(t, b)
+	*now = now.Add(10 * time.Second)
+
+	call(t, b, nil)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s after one of two probe successes, want half-open", b.State())
+	}
+	call(t, b, nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state %s after two probe successes, want closed", b.State())
+	}
+	if snap := b.Snapshot(); snap.Requests != 0 || snap.ConsecutiveFailures != 0 {
+		t.Fatalf("closing kept old outcomes: %+v", snap)
+	}
+}
+
+func TestBreakerProbeFailureRetrips(t *testing.T) {
+	b, now := newTestBreaker(testBreakerConfig())
+	tripBreaker(t, b)
+	*now = now.Add(10 * time.Second)
+
+	call(t, b, errProvider)
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %s after a failed probe, want open", b.State())
+	}
+	if snap := b.Snapshot(); !snap.OpenUntil.Equal(now.Add(10 * time.Second)) {
+		t.Fatalf("OpenUntil %s, want a new cool-down from %s", snap.OpenUntil, *now)
+	}
+}
+
+func TestBreakerIgnoresCancellations(t *testing.T) {
+	b, _ := newTestBreaker(testBreakerConfig())
+
+	for range 10 {
+		call(t, b, context.Canceled)
+	}
+	if snap := b.Snapshot(); snap.State != BreakerClosed || snap.Requests != 0 {
+		t.Fatalf("cancellations were counted: %+v", snap)
+	}
+}
+
+func TestBreakerIgnoresCallsFromBeforeItTripped(t *testing.T) {
+	b, now := newTestBreaker(testBreakerConfig())
+
+	// Admitted while closed, still running when the breaker trips and cools down
+	lateSuccess, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lateFailure, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tripBreaker(t, b)
+	*now = now.Add(10 * time.Second)
+
+	probe, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The late success is not a probe: it neither takes the probe's slot nor counts
+	b.Record(lateSuccess, nil)
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("second probe refused after a late call finished: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Allow() = %v beyond the probe cap, want ErrBreakerOpen", err)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s, want half-open", b.State())
+	}
+
+	// A late failure does not re-trip it either
+	b.Record(lateFailure, errProvider)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s after a late failure, want half-open", b.State())
+	}
+
+	b.Record(probe, nil)
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s after one probe success, want half-open", b.State())
+	}
+}
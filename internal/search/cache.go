@@ -1,109 +1,465 @@
 package search
 
 import (
+	"fmt"
+	"net/url"
 	"sync"
 	"time"
 
+	"hostaggr/internal/config"
 	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
 )
 
-// cacheKey represents the unique identifier for a search request
+// cacheKey represents the unique identifier for a search request. Aggregated results
+// are priced in the requested currency and have no provider; raw results of one
+// provider are in the provider's own currencies, so they are keyed by its name instead
 type cacheKey struct {
-	city    string
-	checkin string
-	nights  int
-	adults  int
+	city     string
+	checkin  string
+	nights   int
+	adults   int
+	currency string
+	provider string
 }
 
-// cacheEntry stores cached hotels with an expiration timestamp
+// newCacheKey derives the cache key of a search request
+func newCacheKey(req models.SearchRequest) cacheKey {
+	return cacheKey{
+		city:     req.City,
+		checkin:  req.CheckIn,
+		nights:   req.Nights,
+		adults:   req.Adults,
+		currency: req.Currency,
+	}
+}
+
+// newProviderCacheKey derives the key of one provider's raw results for a search request
+func newProviderCacheKey(provider string, req models.SearchRequest) cacheKey {
+	key := newCacheKey(req)
+	key.currency = ""
+	key.provider = provider
+	return key
+}
+
+// String renders the key for backends that store entries under string keys.
+// Strings are escaped so the separator cannot appear inside a field
+func (k cacheKey) String() string {
+	s := fmt.Sprintf("%s|%s|%d|%d|%s", url.PathEscape(k.city), url.PathEscape(k.checkin), k.nights, k.adults, url.PathEscape(k.currency))
+	if k.provider != "" {
+		s += "|" + url.PathEscape(k.provider)
+	}
+	return s
+}
+
+// ResultCache stores search results in two tiers: the aggregated hotels of a search,
+// and the raw hotels of each provider that answered it. Cache keeps them in process
+// memory and RedisCache shares them across replicas
+type ResultCache interface {
+	// Get returns cached hotels and their freshness, or false if nothing usable is cached
+	Get(req models.SearchRequest) ([]models.Hotel, Freshness, bool)
+	// Set stores the hotels of a search every provider answered
+	Set(req models.SearchRequest, hotels []models.Hotel)
+	// GetProvider returns one provider's raw hotels while they are fresh
+	GetProvider(provider string, req models.SearchRequest) ([]models.ProviderHotel, bool)
+	// SetProvider stores one provider's raw hotels for ttl, or for the configured
+	// provider TTL when ttl is not positive
+	SetProvider(provider string, req models.SearchRequest, hotels []models.ProviderHotel, ttl time.Duration)
+	// Stop releases background goroutines and connections
+	Stop()
+
+	CacheAdmin
+}
+
+// Freshness describes how usable a cached entry is
+type Freshness int
+
+const (
+	// Fresh entries are within the TTL and served as-is
+	Fresh Freshness = iota
+	// Stale entries are past the TTL but within the stale-while-revalidate window.
+	// They are served immediately while a background refresh runs
+	Stale
+	// StaleIfError entries are too old to serve normally, but may stand in for a
+	// result when every provider fails
+	StaleIfError
+)
+
+// cacheEntry stores cached hotels with an expiration timestamp. Aggregated entries hold
+// hotels, provider entries hold raw
 type cacheEntry struct {
-	hotels    []models.Hotel
-	expiresAt time.Time
+	hotels     []models.Hotel
+	raw        []models.ProviderHotel
+	storedAt   time.Time
+	expiresAt  time.Time // end of the fresh window
+	staleUntil time.Time // end of the stale-while-revalidate window
+	errorUntil time.Time // end of the stale-if-error window
+	size       int64     // estimated bytes
+}
+
+// freshness classifies the entry at time now, reporting false once it is unusable
+func (e *cacheEntry) freshness(now time.Time) (Freshness, bool) {
+	switch {
+	case !now.After(e.expiresAt):
+		return Fresh, true
+	case !now.After(e.staleUntil):
+		return Stale, true
+	case !now.After(e.errorUntil):
+		return StaleIfError, true
+	default:
+		return 0, false
+	}
 }
 
 // Cache provides thread-safe in-memory caching for hotel search results
+// It is bounded by entry count and by an estimate of the bytes held, evicting
+// according to the configured policy when either budget is exceeded
 type Cache struct {
-	mu    sync.RWMutex
-	store map[cacheKey]*cacheEntry
-	ttl   time.Duration
+	mu              sync.Mutex
+	store           map[cacheKey]*cacheEntry
+	ttl             time.Duration
+	providerTTL     time.Duration
+	staleWhileReval time.Duration
+	staleIfError    time.Duration
+	cleanupInterval time.Duration
+	metrics         *obs.Metrics
+	now             func() time.Time
+
+	// Bounds and eviction
+	policyName string
+	policy     evictionPolicy
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+
+	// Counters for Stats, guarded by mu
+	hits           uint64
+	misses         uint64
+	providerHits   uint64
+	providerMisses uint64
+	evictions      map[string]uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
-// NewCache creates a new cache with the specified TTL and starts a background cleanup goroutine
-func NewCache(ttl time.Duration) *Cache {
+// Eviction reasons
+const (
+	evictExpired  = "expired"
+	evictCapacity = "capacity" // over the entry count budget
+	evictBytes    = "bytes"    // over the byte budget
+	evictOversize = "oversize" // a single result larger than the whole byte budget
+	evictAdmin    = "admin"    // removed through the admin API
+)
+
+// CacheStats is a point-in-time summary of the cache
+type CacheStats struct {
+	Policy     string  `json:"policy"`
+	Entries    int     `json:"entries"`
+	MaxEntries int     `json:"max_entries"`
+	Bytes      int64   `json:"bytes"`
+	MaxBytes   int64   `json:"max_bytes"`
+	Hits       uint64  `json:"hits"`
+	Misses     uint64  `json:"misses"`
+	HitRatio   float64 `json:"hit_ratio"` // of aggregated lookups
+
+	ProviderHits   uint64            `json:"provider_hits"`
+	ProviderMisses uint64            `json:"provider_misses"`
+	Evictions      map[string]uint64 `json:"evictions"`
+}
+
+// NewCache creates a new cache with the configured TTL and bounds and starts a background cleanup goroutine
+func NewCache(cfg config.CacheConfig, metrics *obs.Metrics) (*Cache, error) {
+	return NewCacheWithClock(cfg, metrics, time.Now)
+}
+
+// NewCacheWithClock creates a cache that reads the time from now, so tests can age
+// entries without sleeping. The cleanup goroutine still ticks in real time
+func NewCacheWithClock(cfg config.CacheConfig, metrics *obs.Metrics, now func() time.Time) (*Cache, error) {
+	policy, err := newEvictionPolicy(cfg.Policy, cfg.MaxEntries)
+	if err != nil {
+		return nil, err
+	}
+
 	c := &Cache{
-		store: make(map[cacheKey]*cacheEntry),
-		ttl:   ttl,
+		store:           make(map[cacheKey]*cacheEntry),
+		ttl:             cfg.TTL,
+		providerTTL:     cfg.ProviderTTL,
+		staleWhileReval: cfg.StaleWhileRevalidate,
+		staleIfError:    cfg.StaleIfError,
+		cleanupInterval: cfg.CleanupInterval,
+		metrics:         metrics,
+		now:             now,
+		policyName:      cfg.Policy,
+		policy:          policy,
+		maxEntries:      cfg.MaxEntries,
+		maxBytes:        cfg.MaxBytes,
+		evictions:       make(map[string]uint64),
+		stop:            make(chan struct{}),
 	}
 
 	// Start background cleanup goroutine
 	go c.cleanup()
 
-	return c
+	return c, nil
 }
 
-// Get retrieves cached hotels for a search request
-// Returns the hotels and true if found and not expired, otherwise nil and false
-func (c *Cache) Get(req models.SearchRequest) ([]models.Hotel, bool) {
-	key := cacheKey{
-		city:    req.City,
-		checkin: req.CheckIn,
-		nights:  req.Nights,
-		adults:  req.Adults,
-	}
+// Get retrieves cached hotels for a search request along with their freshness
+// Returns false if nothing usable is cached. Only Fresh and Stale entries count as hits;
+// a StaleIfError entry is a miss that the caller may fall back on
+func (c *Cache) Get(req models.SearchRequest) ([]models.Hotel, Freshness, bool) {
+	key := newCacheKey(req)
 
-	c.mu.RLock()
-	entry, exists := c.store[key]
-	c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	entry, exists := c.store[key]
 	if !exists {
-		return nil, false
+		c.recordMiss()
+		return nil, 0, false
 	}
 
-	// Check if entry has expired
-	if time.Now().After(entry.expiresAt) {
+	freshness, usable := entry.freshness(c.now())
+	if !usable {
 		// Entry expired, remove it
-		c.mu.Lock()
-		delete(c.store, key)
-		c.mu.Unlock()
-		return nil, false
+		c.removeLocked(key, evictExpired)
+		c.recordMiss()
+		return nil, 0, false
 	}
 
-	return entry.hotels, true
+	switch freshness {
+	case Fresh:
+		c.recordHit()
+	case Stale:
+		c.recordHit()
+		c.metrics.CacheStaleServed("revalidate")
+	default:
+		c.recordMiss()
+	}
+
+	c.policy.onAccess(key)
+	return entry.hotels, freshness, true
 }
 
-// Set stores hotels in the cache for a search request with a 30-second TTL
+// Set stores hotels in the cache for a search request with the configured TTL,
+// evicting other entries if the cache is over budget
 func (c *Cache) Set(req models.SearchRequest, hotels []models.Hotel) {
-	key := cacheKey{
-		city:    req.City,
-		checkin: req.CheckIn,
-		nights:  req.Nights,
-		adults:  req.Adults,
+	key := newCacheKey(req)
+
+	now := c.now()
+	expiresAt := now.Add(c.ttl)
+	c.put(key, &cacheEntry{
+		hotels:     hotels,
+		storedAt:   now,
+		expiresAt:  expiresAt,
+		staleUntil: expiresAt.Add(c.staleWhileReval),
+		errorUntil: expiresAt.Add(c.staleIfError),
+		size:       estimateEntrySize(key, hotels, nil),
+	})
+}
+
+// GetProvider retrieves one provider's raw hotels for a search request. Provider
+// entries have no stale windows: they are either fresh or gone
+func (c *Cache) GetProvider(provider string, req models.SearchRequest) ([]models.ProviderHotel, bool) {
+	key := newProviderCacheKey(provider, req)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.store[key]
+	if exists && !c.now().After(entry.expiresAt) {
+		c.providerHits++
+		c.metrics.ProviderCacheHit(provider)
+		c.policy.onAccess(key)
+		return entry.raw, true
 	}
 
-	entry := &cacheEntry{
-		hotels:    hotels,
-		expiresAt: time.Now().Add(30 * time.Second),
+	if exists {
+		c.removeLocked(key, evictExpired)
+	}
+	c.providerMisses++
+	c.metrics.ProviderCacheMiss(provider)
+	return nil, false
+}
+
+// SetProvider stores one provider's raw hotels for a search request for ttl, or for
+// the configured provider TTL when ttl is not positive
+func (c *Cache) SetProvider(provider string, req models.SearchRequest, hotels []models.ProviderHotel, ttl time.Duration) {
+	key := newProviderCacheKey(provider, req)
+	if ttl <= 0 {
+		ttl = c.providerTTL
 	}
 
+	now := c.now()
+	expiresAt := now.Add(ttl)
+	c.put(key, &cacheEntry{
+		raw:        hotels,
+		storedAt:   now,
+		expiresAt:  expiresAt,
+		staleUntil: expiresAt,
+		errorUntil: expiresAt,
+		size:       estimateEntrySize(key, nil, hotels),
+	})
+}
+
+// put inserts or replaces an entry, evicting other entries if the cache is over budget
+func (c *Cache) put(key cacheKey, entry *cacheEntry) {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.size > c.maxBytes {
+		if _, exists := c.store[key]; exists {
+			c.removeLocked(key, evictOversize)
+		} else {
+			c.recordEviction(evictOversize)
+		}
+		return
+	}
+
+	if old, exists := c.store[key]; exists {
+		c.bytes -= old.size
+		c.policy.onAccess(key)
+	} else {
+		c.policy.onInsert(key)
+	}
+
 	c.store[key] = entry
-	c.mu.Unlock()
+	c.bytes += entry.size
+
+	c.enforceBoundsLocked()
+	c.updateGaugesLocked()
+}
+
+// Stats returns a summary of the cache's size, hit ratio and evictions
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{
+		Policy:     c.policyName,
+		Entries:    len(c.store),
+		MaxEntries: c.maxEntries,
+		Bytes:      c.bytes,
+		MaxBytes:   c.maxBytes,
+		Hits:       c.hits,
+		Misses:     c.misses,
+
+		ProviderHits:   c.providerHits,
+		ProviderMisses: c.providerMisses,
+		Evictions:      make(map[string]uint64, len(c.evictions)),
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRatio = float64(c.hits) / float64(total)
+	}
+	for reason, n := range c.evictions {
+		stats.Evictions[reason] = n
+	}
+	return stats
+}
+
+// enforceBoundsLocked evicts policy victims until both budgets are met. Caller must hold c.mu
+func (c *Cache) enforceBoundsLocked() {
+	for len(c.store) > c.maxEntries || c.bytes > c.maxBytes {
+		reason := evictBytes
+		if len(c.store) > c.maxEntries {
+			reason = evictCapacity
+		}
+
+		victim, ok := c.policy.victim()
+		if !ok {
+			return
+		}
+		c.removeLocked(victim, reason)
+	}
 }
 
-// cleanup runs in the background and removes expired entries every 60 seconds
+// removeLocked deletes an entry and records why. Caller must hold c.mu
+func (c *Cache) removeLocked(key cacheKey, reason string) {
+	entry, exists := c.store[key]
+	if !exists {
+		return
+	}
+
+	delete(c.store, key)
+	c.bytes -= entry.size
+	c.policy.onRemove(key)
+	c.recordEviction(reason)
+	c.updateGaugesLocked()
+}
+
+// The record helpers keep Stats and metrics in step. Caller must hold c.mu
+func (c *Cache) recordHit() {
+	c.hits++
+	c.metrics.CacheHit()
+}
+
+func (c *Cache) recordMiss() {
+	c.misses++
+	c.metrics.CacheMiss()
+}
+
+func (c *Cache) recordEviction(reason string) {
+	c.evictions[reason]++
+	c.metrics.CacheEviction(reason)
+}
+
+func (c *Cache) updateGaugesLocked() {
+	c.metrics.SetCacheEntries(len(c.store))
+	c.metrics.SetCacheBytes(c.bytes)
+}
+
+// estimateEntrySize approximates the memory held by an entry: string contents plus
+// fixed per-struct overhead. It only needs to be proportional, not exact
+func estimateEntrySize(key cacheKey, hotels []models.Hotel, raw []models.ProviderHotel) int64 {
+	const (
+		entryOverhead  = 128 // map slot, entry struct, timestamps, policy bookkeeping
+		hotelOverhead  = 80  // Hotel struct headers and prices
+		chargeOverhead = 48  // Charge struct and its kind and basis
+		offerOverhead  = 96  // Offer struct, prices and fetch time
+	)
+
+	size := int64(entryOverhead + len(key.city) + len(key.checkin) + len(key.currency) + len(key.provider))
+	for _, h := range hotels {
+		size += int64(hotelOverhead + len(h.HotelID) + len(h.Name) + len(h.Price.Currency()) + len(h.OriginalPrice.Currency()))
+		size += int64(chargeOverhead * len(h.Charges))
+		for _, o := range h.Offers {
+			size += int64(offerOverhead + len(o.Provider) + len(o.HotelID))
+		}
+	}
+	for _, h := range raw {
+		size += int64(hotelOverhead + len(h.HotelID) + len(h.Name) + len(h.City) + len(h.Price.Currency()))
+		size += int64(chargeOverhead * len(h.Charges))
+	}
+	return size
+}
+
+// cleanup runs in the background and removes entries past every window each cleanup interval
 func (c *Cache) cleanup() {
-	ticker := time.NewTicker(60 * time.Second)
+	ticker := time.NewTicker(c.cleanupInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		now := time.Now()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := c.now()
 
 		c.mu.Lock()
 		for key, entry := range c.store {
-			if now.After(entry.expiresAt) {
-				delete(c.store, key)
+			if _, usable := entry.freshness(now); !usable {
+				c.removeLocked(key, evictExpired)
 			}
 		}
 		c.mu.Unlock()
 	}
 }
+
+// Stop terminates the background cleanup goroutine. It is safe to call more than once
+func (c *Cache) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+)
+
+func testCacheConfig() config.CacheConfig {
+	cfg := config.Default().Cache
+	cfg.Policy = PolicyLRU
+	return cfg
+}
+
+// cacheSearch returns the i-th of a set of searches whose entries are the same size
+func cacheSearch(i int) models.SearchRequest {
+	return models.SearchRequest{City: fmt.Sprintf("city%03d", i), CheckIn: "2026-11-20", Nights: 2, Adults: 2, Currency: "EUR"}
+}
+
+var cacheHotels = []models.Hotel{{HotelID: "P1", Name: "Riad Palace", Price: currency.MustParse("180.00", "EUR")}}
+
+func newBoundedCache(t *testing.T, cfg config.CacheConfig) (*Cache, *testClock) {
+	t.Helper()
+	clock := newTestClock()
+	c, err := NewCacheWithClock(cfg, nil, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	return c, clock
+}
+
+func TestCacheEvictsOverEntryBudget(t *testing.T) {
+	cfg := testCacheConfig()
+	cfg.MaxEntries = 2
+	c, _ := newBoundedCache(t, cfg)
+
+	for i := range 3 {
+		c.Set(cacheSearch(i), cacheHotels)
+	}
+
+	if _, _, found := c.Get(cacheSearch(0)); found {
+		t.Fatal("the least recently used entry survived")
+	}
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Evictions[evictCapacity] != 1 {
+		t.Fatalf("stats %+v, want 2 entries after 1 capacity eviction", stats)
+	}
+}
+
+func TestCacheEvictsUntilUnderByteBudget(t *testing.T) {
+	size := estimateEntrySize(newCacheKey(cacheSearch(0)), cacheHotels, nil)
+	cfg := testCacheConfig()
+	cfg.MaxBytes = 3*size + size/2
+	c, _ := newBoundedCache(t, cfg)
+
+	for i := range 3 {
+		c.Set(cacheSearch(i), cacheHotels)
+	}
+	c.Get(cacheSearch(0)) // most recently used from here on
+
+	// An entry about twice the size pushes out as many older entries as it takes
+	big := make([]models.Hotel, 0, 4)
+	for range 4 {
+		big = append(big, cacheHotels...)
+	}
+	bigSize := estimateEntrySize(newCacheKey(cacheSearch(3)), big, nil)
+	c.Set(cacheSearch(3), big)
+
+	stats := c.Stats()
+	if stats.Bytes > cfg.MaxBytes || stats.Bytes != bigSize+size {
+		t.Fatalf("bytes %d, want %d: the new entry and the most recently used one", stats.Bytes, bigSize+size)
+	}
+	if stats.Entries != 2 || stats.Evictions[evictBytes] != 2 {
+		t.Fatalf("stats %+v, want 2 entries after 2 byte evictions", stats)
+	}
+	if _, _, found := c.Get(cacheSearch(0)); !found {
+		t.Fatal("the most recently used entry was evicted")
+	}
+}
+
+func TestCacheRejectsOversizeEntries(t *testing.T) {
+	cfg := testCacheConfig()
+	cfg.MaxBytes = estimateEntrySize(newCacheKey(cacheSearch(0)), cacheHotels, nil) - 1
+	c, _ := newBoundedCache(t, cfg)
+
+	c.Set(cacheSearch(0), cacheHotels)
+	if stats := c.Stats(); stats.Entries != 0 || stats.Bytes != 0 || stats.Evictions[evictOversize] != 1 {
+		t.Fatalf("stats %+v, want the entry turned away as oversize", stats)
+	}
+}
+
+func TestCacheStatsCountExpiryAndHits(t *testing.T) {
+	c, clock := newBoundedCache(t, testCacheConfig())
+
+	c.Set(cacheSearch(0), cacheHotels)
+	c.Get(cacheSearch(0))
+	c.Get(cacheSearch(1))
+
+	// Past the TTL and every stale window
+	clock.Advance(time.Hour)
+	c.Get(cacheSearch(0))
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.HitRatio != 1.0/3 {
+		t.Fatalf("stats %+v, want 1 hit and 2 misses", stats)
+	}
+	if stats.Entries != 0 || stats.Evictions[evictExpired] != 1 {
+		t.Fatalf("stats %+v, want the expired entry evicted", stats)
+	}
+}
@@ -0,0 +1,273 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hostaggr/internal/models"
+)
+
+// CacheAdmin is implemented by caches that operators can inspect and purge
+type CacheAdmin interface {
+	// Entries lists the usable entries matching filter, ordered by key
+	Entries(filter CacheFilter) ([]CacheEntryInfo, error)
+	// Entry returns one entry by the key reported in CacheEntryInfo
+	Entry(key string) (CacheEntry, bool, error)
+	// DeleteKey removes one entry by key, reporting whether it existed
+	DeleteKey(key string) (bool, error)
+	// Delete removes every entry matching filter and returns how many were removed
+	Delete(filter CacheFilter) (int, error)
+	// Flush removes every entry and returns how many were removed
+	Flush() (int, error)
+}
+
+// CacheFilter selects cache entries of both tiers. Empty fields match everything
+type CacheFilter struct {
+	City        string // in the normalized form of Aggregator.NormalizeCity
+	CheckInFrom string // inclusive YYYY-MM-DD lower bound
+	CheckInTo   string // inclusive YYYY-MM-DD upper bound
+}
+
+// IsZero reports whether the filter matches every entry
+func (f CacheFilter) IsZero() bool {
+	return f == CacheFilter{}
+}
+
+// LogValue renders the set fields for audit logs
+func (f CacheFilter) LogValue() slog.Value {
+	var attrs []slog.Attr
+	if f.City != "" {
+		attrs = append(attrs, slog.String("city", f.City))
+	}
+	if f.CheckInFrom != "" {
+		attrs = append(attrs, slog.String("checkin_from", f.CheckInFrom))
+	}
+	if f.CheckInTo != "" {
+		attrs = append(attrs, slog.String("checkin_to", f.CheckInTo))
+	}
+	if len(attrs) == 0 {
+		return slog.StringValue("all")
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// match reports whether key is selected. YYYY-MM-DD dates order lexically
+func (f CacheFilter) match(key cacheKey) bool {
+	if f.City != "" && f.City != key.city {
+		return false
+	}
+	if f.CheckInFrom != "" && key.checkin < f.CheckInFrom {
+		return false
+	}
+	if f.CheckInTo != "" && key.checkin > f.CheckInTo {
+		return false
+	}
+	return true
+}
+
+// CacheEntryInfo describes one cache entry without its hotels
+type CacheEntryInfo struct {
+	Key       string    `json:"key"`
+	Tier      string    `json:"tier"` // "aggregate" or "provider"
+	City      string    `json:"city"`
+	CheckIn   string    `json:"checkin"`
+	Nights    int       `json:"nights"`
+	Adults    int       `json:"adults"`
+	Currency  string    `json:"currency,omitempty"`
+	Provider  string    `json:"provider,omitempty"`
+	Freshness string    `json:"freshness"`
+	StoredAt  time.Time `json:"stored_at"`
+	AgeMs     int64     `json:"age_ms"`
+	TTLMs     int64     `json:"ttl_ms"` // until the entry stops being usable at all
+	Hotels    int       `json:"hotels"`
+	Bytes     int64     `json:"bytes"`
+}
+
+// CacheEntry is one cache entry with its hotels. Aggregated entries carry Hotels,
+// provider entries carry ProviderHotels
+type CacheEntry struct {
+	CacheEntryInfo
+	Hotels         []models.Hotel         `json:"hotels,omitempty"`
+	ProviderHotels []models.ProviderHotel `json:"provider_hotels,omitempty"`
+}
+
+// Cache tiers
+const (
+	tierAggregate = "aggregate"
+	tierProvider  = "provider"
+)
+
+// String returns the name used in admin listings
+func (f Freshness) String() string {
+	switch f {
+	case Fresh:
+		return "fresh"
+	case Stale:
+		return "stale"
+	case StaleIfError:
+		return "stale-if-error"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrMalformedCacheKey is returned when a key passed to CacheAdmin is not one it reported
+var ErrMalformedCacheKey = errors.New("malformed cache key")
+
+// parseCacheKey reverses cacheKey.String
+func parseCacheKey(s string) (cacheKey, error) {
+	malformed := fmt.Errorf("%w %q", ErrMalformedCacheKey, s)
+
+	parts := strings.Split(s, "|")
+	if len(parts) != 5 && len(parts) != 6 {
+		return cacheKey{}, malformed
+	}
+
+	var key cacheKey
+	var err error
+	if key.city, err = url.PathUnescape(parts[0]); err != nil {
+		return cacheKey{}, malformed
+	}
+	if key.checkin, err = url.PathUnescape(parts[1]); err != nil {
+		return cacheKey{}, malformed
+	}
+	if key.nights, err = strconv.Atoi(parts[2]); err != nil {
+		return cacheKey{}, malformed
+	}
+	if key.adults, err = strconv.Atoi(parts[3]); err != nil {
+		return cacheKey{}, malformed
+	}
+	if key.currency, err = url.PathUnescape(parts[4]); err != nil {
+		return cacheKey{}, malformed
+	}
+	// Aggregated results have a currency, provider results a provider instead
+	if len(parts) == 6 {
+		if key.provider, err = url.PathUnescape(parts[5]); err != nil || key.provider == "" || key.currency != "" {
+			return cacheKey{}, malformed
+		}
+	} else if key.currency == "" {
+		return cacheKey{}, malformed
+	}
+	return key, nil
+}
+
+// newEntryInfo describes an entry as of now
+func newEntryInfo(key cacheKey, e *cacheEntry, size int64, now time.Time) CacheEntryInfo {
+	freshness, _ := e.freshness(now)
+
+	info := CacheEntryInfo{
+		Key:       key.String(),
+		Tier:      tierAggregate,
+		City:      key.city,
+		CheckIn:   key.checkin,
+		Nights:    key.nights,
+		Adults:    key.adults,
+		Currency:  key.currency,
+		Provider:  key.provider,
+		Freshness: freshness.String(),
+		StoredAt:  e.storedAt,
+		AgeMs:     now.Sub(e.storedAt).Milliseconds(),
+		TTLMs:     e.errorUntil.Sub(now).Milliseconds(),
+		Hotels:    len(e.hotels),
+		Bytes:     size,
+	}
+	if key.provider != "" {
+		info.Tier = tierProvider
+		info.Hotels = len(e.raw)
+	}
+	return info
+}
+
+// sortEntryInfos orders a listing by key
+func sortEntryInfos(infos []CacheEntryInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+}
+
+// Entries lists the usable entries matching filter
+func (c *Cache) Entries(filter CacheFilter) ([]CacheEntryInfo, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	infos := make([]CacheEntryInfo, 0)
+	for key, entry := range c.store {
+		if _, usable := entry.freshness(now); usable && filter.match(key) {
+			infos = append(infos, newEntryInfo(key, entry, entry.size, now))
+		}
+	}
+	c.mu.Unlock()
+
+	sortEntryInfos(infos)
+	return infos, nil
+}
+
+// Entry returns one usable entry by key
+func (c *Cache) Entry(keyStr string) (CacheEntry, bool, error) {
+	key, err := parseCacheKey(keyStr)
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.store[key]
+	if !exists {
+		return CacheEntry{}, false, nil
+	}
+	if _, usable := entry.freshness(now); !usable {
+		return CacheEntry{}, false, nil
+	}
+
+	return CacheEntry{
+		CacheEntryInfo: newEntryInfo(key, entry, entry.size, now),
+		Hotels:         entry.hotels,
+		ProviderHotels: entry.raw,
+	}, true, nil
+}
+
+// DeleteKey removes one entry by key
+func (c *Cache) DeleteKey(keyStr string) (bool, error) {
+	key, err := parseCacheKey(keyStr)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.store[key]; !exists {
+		return false, nil
+	}
+	c.removeLocked(key, evictAdmin)
+	return true, nil
+}
+
+// Delete removes every entry matching filter
+func (c *Cache) Delete(filter CacheFilter) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.store {
+		if filter.match(key) {
+			c.removeLocked(key, evictAdmin)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Flush removes every entry
+func (c *Cache) Flush() (int, error) {
+	return c.Delete(CacheFilter{})
+}
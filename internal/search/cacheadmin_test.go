@@ -0,0 +1,39 @@
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCacheKeyRoundTrips(t *testing.T) {
+	for _, key := range []cacheKey{
+		{city: "marrakech", checkin: "2026-11-20", nights: 2, adults: 2, currency: "EUR"},
+		{city: "são paulo", checkin: "2026-11-20", nights: 1, adults: 1, currency: "BRL"},
+		{city: "a|b%c/d", checkin: "2026-11-20", nights: 3, adults: 4, currency: "EUR"},
+		// Provider keys have no currency
+		{city: "marrakech", checkin: "2026-11-20", nights: 2, adults: 2, provider: "Mock1"},
+		{city: "marrakech", checkin: "2026-11-20", nights: 2, adults: 2, provider: "Odd|Name"},
+	} {
+		got, err := parseCacheKey(key.String())
+		if err != nil || got != key {
+			t.Errorf("parseCacheKey(%q) = %+v, %v, want %+v", key.String(), got, err, key)
+		}
+	}
+}
+
+func TestParseCacheKeyRejectsMalformedKeys(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"marrakech|2026-11-20|2|2", // too few fields
+		"marrakech|2026-11-20|2|2|EUR|Mock1|extra", // too many
+		"marrakech|2026-11-20|two|2|EUR",           // nights not a number
+		"marrakech|2026-11-20|2|2|",                // aggregated key without a currency
+		"marrakech|2026-11-20|2|2|EUR|Mock1",       // provider key with a currency
+		"marrakech|2026-11-20|2|2||",               // provider key without a provider
+		"marra%zzkech|2026-11-20|2|2|EUR",          // bad escape
+	} {
+		if _, err := parseCacheKey(s); !errors.Is(err, ErrMalformedCacheKey) {
+			t.Errorf("parseCacheKey(%q) = %v, want ErrMalformedCacheKey", s, err)
+		}
+	}
+}
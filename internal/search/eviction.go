@@ -0,0 +1,162 @@
+package search
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+// Eviction policy names accepted by config
+const (
+	PolicyLRU     = "lru"
+	PolicyLFU     = "lfu"
+	PolicyTinyLFU = "tinylfu"
+)
+
+// evictionPolicy decides which entry leaves a bounded cache. Calls are serialized by
+// the cache lock, so implementations need no locking of their own
+type evictionPolicy interface {
+	// onInsert records a key that was just added
+	onInsert(key cacheKey)
+	// onAccess records a hit on, or an overwrite of, an existing key
+	onAccess(key cacheKey)
+	// onRemove forgets a key removed for any reason
+	onRemove(key cacheKey)
+	// victim picks the entry to evict next; it is only called while the cache is over budget
+	victim() (cacheKey, bool)
+}
+
+// newEvictionPolicy builds the named policy for a cache holding up to capacity entries
+func newEvictionPolicy(name string, capacity int) (evictionPolicy, error) {
+	switch name {
+	case PolicyLRU:
+		return newLRUPolicy(), nil
+	case PolicyLFU:
+		return newLFUPolicy(), nil
+	case PolicyTinyLFU:
+		return newTinyLFUPolicy(capacity), nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q", name)
+	}
+}
+
+// lruPolicy evicts the least recently used entry
+type lruPolicy struct {
+	order *list.List // front is most recent
+	elems map[cacheKey]*list.Element
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[cacheKey]*list.Element),
+	}
+}
+
+func (p *lruPolicy) onInsert(key cacheKey) {
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) onAccess(key cacheKey) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) onRemove(key cacheKey) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy) victim() (cacheKey, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return cacheKey{}, false
+	}
+	return e.Value.(cacheKey), true
+}
+
+// lfuPolicy evicts the least frequently used entry, breaking ties by recency
+type lfuPolicy struct {
+	items lfuHeap
+	index map[cacheKey]*lfuItem
+	clock uint64
+}
+
+type lfuItem struct {
+	key      cacheKey
+	freq     uint64
+	lastUsed uint64
+	pos      int
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{
+		index: make(map[cacheKey]*lfuItem),
+	}
+}
+
+func (p *lfuPolicy) onInsert(key cacheKey) {
+	p.clock++
+	it := &lfuItem{key: key, freq: 1, lastUsed: p.clock}
+	p.index[key] = it
+	heap.Push(&p.items, it)
+}
+
+func (p *lfuPolicy) onAccess(key cacheKey) {
+	if it, ok := p.index[key]; ok {
+		p.clock++
+		it.freq++
+		it.lastUsed = p.clock
+		heap.Fix(&p.items, it.pos)
+	}
+}
+
+func (p *lfuPolicy) onRemove(key cacheKey) {
+	if it, ok := p.index[key]; ok {
+		heap.Remove(&p.items, it.pos)
+		delete(p.index, key)
+	}
+}
+
+func (p *lfuPolicy) victim() (cacheKey, bool) {
+	if len(p.items) == 0 {
+		return cacheKey{}, false
+	}
+	return p.items[0].key, true
+}
+
+// lfuHeap is a min-heap on (freq, lastUsed)
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].lastUsed < h[j].lastUsed
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	it := x.(*lfuItem)
+	it.pos = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
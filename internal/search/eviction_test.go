@@ -0,0 +1,77 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testKey(i int) cacheKey {
+	return cacheKey{city: fmt.Sprintf("city%03d", i), checkin: "2026-11-20", nights: 2, adults: 2, currency: "EUR"}
+}
+
+// evict removes the policy's victim, as the cache does, and returns it
+func evict(t *testing.T, p evictionPolicy) cacheKey {
+	t.Helper()
+	key, ok := p.victim()
+	if !ok {
+		t.Fatal("no victim")
+	}
+	p.onRemove(key)
+	return key
+}
+
+func TestNewEvictionPolicy(t *testing.T) {
+	for _, name := range []string{PolicyLRU, PolicyLFU, PolicyTinyLFU} {
+		if _, err := newEvictionPolicy(name, 100); err != nil {
+			t.Errorf("newEvictionPolicy(%q) = %v", name, err)
+		}
+	}
+	if _, err := newEvictionPolicy("fifo", 100); err == nil {
+		t.Error("newEvictionPolicy accepted an unknown policy")
+	}
+}
+
+func TestLRUPolicyEvictsLeastRecentlyUsed(t *testing.T) {
+	p := newLRUPolicy()
+	for i := range 4 {
+		p.onInsert(testKey(i))
+	}
+	p.onAccess(testKey(0))
+	p.onAccess(testKey(2))
+
+	for _, want := range []int{1, 3, 0, 2} {
+		if got := evict(t, p); got != testKey(want) {
+			t.Fatalf("evicted %s, want %s", got.city, testKey(want).city)
+		}
+	}
+	if _, ok := p.victim(); ok {
+		t.Fatal("victim from an empty policy")
+	}
+}
+
+func TestLFUPolicyEvictsLeastFrequentlyUsed(t *testing.T) {
+	p := newLFUPolicy()
+	for i := range 4 {
+		p.onInsert(testKey(i))
+	}
+	// Frequencies 3, 1, 2, 1; ties go to the least recently used
+	p.onAccess(testKey(0))
+	p.onAccess(testKey(2))
+	p.onAccess(testKey(0))
+
+	for _, want := range []int{1, 3, 2, 0} {
+		if got := evict(t, p); got != testKey(want) {
+			t.Fatalf("evicted %s, want %s", got.city, testKey(want).city)
+		}
+	}
+
+	// Removing from the middle of the heap keeps it ordered
+	for i := range 3 {
+		p.onInsert(testKey(i))
+	}
+	p.onAccess(testKey(0))
+	p.onRemove(testKey(1))
+	if got := evict(t, p); got != testKey(2) {
+		t.Fatalf("evicted %s after a removal, want %s", got.city, testKey(2).city)
+	}
+}
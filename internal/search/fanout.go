@@ -0,0 +1,148 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"hostaggr/internal/models"
+)
+
+// providerOutcome is the result of querying one provider
+type providerOutcome struct {
+	provider string
+	hotels   []models.ProviderHotel
+	stats    callStats
+	err      error
+	skipped  bool // short-circuited by an open breaker
+	cached   bool // answered from the per-provider cache tier
+}
+
+// fanOut queries every provider concurrently under the provider timeout and delivers
+// each outcome as soon as it is known. Providers with fresh raw results in the cache
+// are not called, and successful answers are cached for the next search. The channel receives exactly one outcome per
+// provider and is buffered, so callers may stop reading at any time without leaking
+func (a *Aggregator) fanOut(ctx context.Context, req models.SearchRequest) <-chan providerOutcome {
+	// Create context with the configured provider timeout
+	queryCtx, cancel := context.WithTimeout(ctx, a.providerTimeout)
+
+	out := make(chan providerOutcome, len(a.upstreams))
+	var wg sync.WaitGroup
+
+	for _, u := range a.upstreams {
+		if hotels, ok := a.cachedProviderHotels(u, req); ok {
+			// Entries cached before offers were annotated still get annotated, on a
+			// copy since the cached slice is shared with concurrent searches
+			hotels = u.annotate(hotels, time.Time{})
+			out <- providerOutcome{provider: u.name, hotels: hotels, cached: true}
+			continue
+		}
+
+		// Skip providers whose breaker is open without spending a goroutine on them
+		ticket, ok := u.admit()
+		if !ok {
+			out <- providerOutcome{provider: u.name, err: ErrBreakerOpen, skipped: true}
+			continue
+		}
+
+		wg.Add(1)
+		go func(u *upstream, ticket BreakerTicket) {
+			defer wg.Done()
+			hotels, stats, err := u.search(queryCtx, req, ticket)
+			if err == nil {
+				a.cacheProviderHotels(u, req, hotels)
+			}
+			out <- providerOutcome{provider: u.name, hotels: hotels, stats: stats, err: err}
+		}(u, ticket)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	return out
+}
+
+// cachedProviderHotels looks up the provider's raw results for req in the cache
+func (a *Aggregator) cachedProviderHotels(u *upstream, req models.SearchRequest) ([]models.ProviderHotel, bool) {
+	if a.cache == nil || u.cacheTTL(req) < 0 {
+		return nil, false
+	}
+	return a.cache.GetProvider(u.name, req)
+}
+
+// cacheProviderHotels stores a successful answer under the provider's own freshness.
+// Failures are never cached, so the next search asks the provider again
+func (a *Aggregator) cacheProviderHotels(u *upstream, req models.SearchRequest, hotels []models.ProviderHotel) {
+	ttl := u.cacheTTL(req)
+	if a.cache == nil || ttl < 0 {
+		return
+	}
+	a.cache.SetProvider(u.name, req, hotels, ttl)
+}
+
+// fanOutResult collects the outcome of querying every provider
+type fanOutResult struct {
+	hotels    []models.ProviderHotel
+	succeeded int
+	failed    int
+	skipped   int // providers short-circuited by an open breaker, also counted in failed
+	cached    int // providers answered from the cache, also counted in succeeded
+	retries   int
+	hedges    int
+	pending   map[string]bool
+	order     []string // provider order, for stable pending lists
+}
+
+func newFanOutResult(upstreams []*upstream) *fanOutResult {
+	r := &fanOutResult{
+		pending: make(map[string]bool, len(upstreams)),
+	}
+	for _, u := range upstreams {
+		r.pending[u.name] = true
+		r.order = append(r.order, u.name)
+	}
+	return r
+}
+
+// add merges one provider outcome
+func (r *fanOutResult) add(o providerOutcome) {
+	delete(r.pending, o.provider)
+
+	r.retries += o.stats.retries
+	r.hedges += o.stats.hedges
+
+	if o.err != nil {
+		r.failed++
+		if o.skipped {
+			r.skipped++
+		}
+		return
+	}
+
+	r.succeeded++
+	if o.cached {
+		r.cached++
+	}
+	r.hotels = append(r.hotels, o.hotels...)
+}
+
+// done reports whether every provider has answered
+func (r *fanOutResult) done() bool {
+	return len(r.pending) == 0
+}
+
+// pendingNames lists providers that have not answered yet, in provider order
+func (r *fanOutResult) pendingNames() []string {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r.pending))
+	for _, name := range r.order {
+		if r.pending[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
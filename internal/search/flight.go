@@ -0,0 +1,164 @@
+package search
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"hostaggr/internal/models"
+)
+
+// flight is one provider fan-out shared by every identical concurrent search.
+// Outcomes are recorded in arrival order so each waiter can follow at its own pace
+type flight struct {
+	key    cacheKey
+	cancel context.CancelFunc
+	total  int
+
+	mu       sync.Mutex
+	outcomes []providerOutcome
+	changed  chan struct{} // closed and replaced whenever an outcome arrives
+
+	// Guarded by flightGroup.mu
+	waiters  int
+	detached bool // a waiter asked for the flight to finish even if everyone leaves
+}
+
+// push records an outcome and wakes the waiters
+func (f *flight) push(o providerOutcome) {
+	f.mu.Lock()
+	f.outcomes = append(f.outcomes, o)
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.mu.Unlock()
+}
+
+// since returns the outcomes from index from onwards, whether the flight is complete,
+// and a channel that is closed on the next change
+func (f *flight) since(from int) ([]providerOutcome, bool, <-chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.outcomes[from:], len(f.outcomes) == f.total, f.changed
+}
+
+// flightGroup deduplicates concurrent fan-outs by cache key
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[cacheKey]*flight
+}
+
+// followReason says why a waiter stopped following a flight
+type followReason int
+
+const (
+	followComplete followReason = iota
+	followBudget
+	followCancelled
+)
+
+// joinFlight returns the in-progress fan-out for req, starting one if there is none.
+// shared reports whether the caller joined an existing flight. Every join must be
+// matched by one leaveFlight
+func (a *Aggregator) joinFlight(req models.SearchRequest) (*flight, bool) {
+	key := newCacheKey(req)
+
+	a.flights.mu.Lock()
+	if f, exists := a.flights.flights[key]; exists {
+		f.waiters++
+		a.flights.mu.Unlock()
+		a.metrics.SearchCoalesced()
+		return f, true
+	}
+
+	// The fan-out belongs to the flight, not to any one caller, so it is detached from
+	// their contexts and only cancelled once every waiter has given up
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &flight{
+		key:     key,
+		cancel:  cancel,
+		total:   len(a.upstreams),
+		changed: make(chan struct{}),
+		waiters: 1,
+	}
+	a.flights.flights[key] = f
+	a.flights.mu.Unlock()
+
+	outcomes := a.fanOut(ctx, req)
+
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		defer cancel()
+
+		all := newFanOutResult(a.upstreams)
+		for !all.done() {
+			o := <-outcomes
+			all.add(o)
+			f.push(o)
+		}
+
+		// Cache before leaving the group so a new identical search finds the result.
+		// Only complete results are cached as a whole: after a partial failure the next
+		// search reuses the providers that answered and re-queries only the rest, and a
+		// total failure cannot displace a usable stale entry
+		if a.cache != nil && ctx.Err() == nil && all.failed == 0 {
+			a.cache.Set(req, a.buildHotels(all.hotels, req))
+		}
+
+		a.flights.mu.Lock()
+		if a.flights.flights[key] == f {
+			delete(a.flights.flights, key)
+		}
+		a.flights.mu.Unlock()
+	}()
+
+	return f, false
+}
+
+// leaveFlight releases the caller's interest in a flight. With keepRunning the flight
+// is left to complete in the background; otherwise it is cancelled once no waiter remains
+func (a *Aggregator) leaveFlight(f *flight, keepRunning bool) {
+	a.flights.mu.Lock()
+	defer a.flights.mu.Unlock()
+
+	f.waiters--
+	if keepRunning {
+		f.detached = true
+	}
+
+	if f.waiters == 0 && !f.detached {
+		f.cancel()
+		// Nobody may join a cancelled flight
+		if a.flights.flights[f.key] == f {
+			delete(a.flights.flights, f.key)
+		}
+	}
+}
+
+// follow delivers the flight's outcomes to onOutcome until the flight completes, the
+// budget fires, ctx ends or onOutcome returns an error
+func (a *Aggregator) follow(ctx context.Context, f *flight, budget <-chan time.Time, onOutcome func(providerOutcome) error) (followReason, error) {
+	seen := 0
+	for {
+		outcomes, complete, changed := f.since(seen)
+		for _, o := range outcomes {
+			if err := onOutcome(o); err != nil {
+				return followCancelled, err
+			}
+		}
+		seen += len(outcomes)
+
+		if complete {
+			return followComplete, nil
+		}
+
+		select {
+		case <-changed:
+		case <-budget:
+			return followBudget, nil
+		case <-ctx.Done():
+			return followCancelled, ctx.Err()
+		}
+	}
+}
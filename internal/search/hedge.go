@@ -0,0 +1,88 @@
+package search
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyTracker keeps a window of recent successful call latencies
+type latencyTracker struct {
+	mu         sync.Mutex
+	samples    []time.Duration
+	next       int
+	filled     int
+	minSamples int
+}
+
+func newLatencyTracker(windowSize, minSamples int) *latencyTracker {
+	return &latencyTracker{
+		samples:    make([]time.Duration, windowSize),
+		minSamples: minSamples,
+	}
+}
+
+// observe records one latency sample
+func (t *latencyTracker) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % len(t.samples)
+	if t.filled < len(t.samples) {
+		t.filled++
+	}
+}
+
+// quantile returns the q-quantile of the window, or false while there are too few samples
+func (t *latencyTracker) quantile(q float64) (time.Duration, bool) {
+	t.mu.Lock()
+	if t.filled < t.minSamples {
+		t.mu.Unlock()
+		return 0, false
+	}
+	sorted := slices.Clone(t.samples[:t.filled])
+	t.mu.Unlock()
+
+	slices.Sort(sorted)
+	idx := int(q * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx], true
+}
+
+// hedgeLimiter is a token bucket capping hedged calls per second across all providers
+type hedgeLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+func newHedgeLimiter(perSecond float64) *hedgeLimiter {
+	return &hedgeLimiter{
+		perSecond: perSecond,
+		tokens:    perSecond,
+		last:      time.Now(),
+		now:       time.Now,
+	}
+}
+
+// allow spends one hedge token, reporting false when the cap is reached
+func (l *hedgeLimiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	// Allow a burst of at least one hedge even for sub-1/s caps
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.perSecond, max(l.perSecond, 1))
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,287 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/providers"
+)
+
+func TestLatencyTrackerQuantile(t *testing.T) {
+	tr := newLatencyTracker(10, 5)
+	for i := range 4 {
+		tr.observe(time.Duration(i+1) * time.Millisecond)
+	}
+	if _, ok := tr.quantile(0.5); ok {
+		t.Fatal("quantile reported with fewer samples than the minimum")
+	}
+
+	for i := 4; i < 10; i++ {
+		tr.observe(time.Duration(i+1) * time.Millisecond)
+	}
+	for q, want := range map[float64]time.Duration{
+		0:    1 * time.Millisecond,
+		0.5:  6 * time.Millisecond,
+		0.95: 10 * time.Millisecond,
+		1:    10 * time.Millisecond, // clamped to the slowest sample
+	} {
+		if got, ok := tr.quantile(q); !ok || got != want {
+			t.Errorf("quantile(%g) = %s, %t, want %s", q, got, ok, want)
+		}
+	}
+
+	// Samples older than the window are overwritten
+	for range 10 {
+		tr.observe(100 * time.Millisecond)
+	}
+	if got, _ := tr.quantile(0); got != 100*time.Millisecond {
+		t.Fatalf("quantile(0) = %s after the window turned over, want 100ms", got)
+	}
+}
+
+// newTestHedgeLimiter returns a limiter reading the time from the returned clock
+func newTestHedgeLimiter(perSecond float64) (*hedgeLimiter, *time.Time) {
+	now := time.Date(2026, 11, 2, 12, 0, 0, 0, time.UTC)
+	l := newHedgeLimiter(perSecond)
+	l.now = func() time.Time { return now }
+	l.last = now
+	return l, &now
+}
+
+func TestHedgeLimiterTokenBucket(t *testing.T) {
+	l, now := newTestHedgeLimiter(2)
+
+	// It starts with a second's worth of hedges
+	if !l.allow() || !l.allow() {
+		t.Fatal("hedges refused from a full bucket")
+	}
+	if l.allow() {
+		t.Fatal("hedge allowed from an empty bucket")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if !l.allow() {
+		t.Fatal("hedge refused after refilling one token")
+	}
+	if l.allow() {
+		t.Fatal("hedge allowed beyond the refill")
+	}
+
+	// Idle time refills up to the burst only
+	*now = now.Add(time.Hour)
+	for i := range 2 {
+		if !l.allow() {
+			t.Fatalf("hedge %d refused after an idle hour", i+1)
+		}
+	}
+	if l.allow() {
+		t.Fatal("hedge allowed beyond the burst")
+	}
+}
+
+func TestHedgeLimiterBurstsAtLeastOne(t *testing.T) {
+	l, now := newTestHedgeLimiter(0.5)
+
+	if l.allow() {
+		t.Fatal("hedge allowed with half a token")
+	}
+	*now = now.Add(time.Second)
+	if !l.allow() {
+		t.Fatal("hedge refused after refilling to one token")
+	}
+	*now = now.Add(time.Hour)
+	if !l.allow() || l.allow() {
+		t.Fatal("a sub-1/s limiter should burst exactly one hedge")
+	}
+}
+
+// gatedProvider hands every call to the test, which answers it on the call's reply
+// channel. Unanswered calls end when their context is cancelled
+type gatedProvider struct {
+	calls chan gatedCall
+}
+
+type gatedCall struct {
+	ctx   context.Context
+	reply chan error
+}
+
+func newGatedProvider() *gatedProvider {
+	return &gatedProvider{calls: make(chan gatedCall, 4)}
+}
+
+func (p *gatedProvider) Name() string               { return "Mock1" }
+func (p *gatedProvider) Pricing() providers.Pricing { return providers.Pricing{} }
+
+func (p *gatedProvider) Search(ctx context.Context, req models.SearchRequest) ([]models.ProviderHotel, error) {
+	c := gatedCall{ctx: ctx, reply: make(chan error, 1)}
+	p.calls <- c
+	select {
+	case err := <-c.reply:
+		if err != nil {
+			return nil, err
+		}
+		return []models.ProviderHotel{{HotelID: "H123"}}, nil
+	case <-ctx.Done():
+		return nil, providers.NewError(p.Name(), providers.KindCanceled, ctx.Err())
+	}
+}
+
+type hedgeResult struct {
+	hotels []models.ProviderHotel
+	hedged bool
+	err    error
+}
+
+// newHedgingUpstream returns an upstream that hedges after about a millisecond
+func newHedgingUpstream(p providers.Provider) *upstream {
+	hedges, _ := newTestHedgeLimiter(1)
+	u := &upstream{
+		name:          p.Name(),
+		provider:      p,
+		metrics:       obs.NewMetrics(),
+		latency:       newLatencyTracker(1, 1),
+		hedges:        hedges,
+		hedgeQuantile: 0.5,
+	}
+	u.latency.observe(time.Millisecond)
+	return u
+}
+
+func startHedgedAttempt(u *upstream, ticket BreakerTicket) <-chan hedgeResult {
+	done := make(chan hedgeResult, 1)
+	go func() {
+		hotels, hedged, err := u.hedgedAttempt(context.Background(), models.SearchRequest{}, ticket)
+		done <- hedgeResult{hotels, hedged, err}
+	}()
+	return done
+}
+
+func TestHedgedAttemptHedgeWins(t *testing.T) {
+	p := newGatedProvider()
+	u := newHedgingUpstream(p)
+	done := startHedgedAttempt(u, BreakerTicket{})
+
+	original, hedge := <-p.calls, <-p.calls
+	hedge.reply <- nil
+
+	r := <-done
+	if r.err != nil || !r.hedged || len(r.hotels) != 1 {
+		t.Fatalf("hedgedAttempt() = %+v, want the hedge's result", r)
+	}
+	if original.ctx.Err() == nil {
+		t.Fatal("the losing original call was not cancelled")
+	}
+	if got := u.metrics.Registry().Sum("hostaggr_provider_hedge_wins_total"); got != 1 {
+		t.Fatalf("hedge wins = %g, want 1", got)
+	}
+}
+
+func TestHedgedAttemptOriginalWins(t *testing.T) {
+	p := newGatedProvider()
+	u := newHedgingUpstream(p)
+	done := startHedgedAttempt(u, BreakerTicket{})
+
+	original, hedge := <-p.calls, <-p.calls
+	original.reply <- nil
+
+	r := <-done
+	if r.err != nil || !r.hedged || len(r.hotels) != 1 {
+		t.Fatalf("hedgedAttempt() = %+v, want the original's result", r)
+	}
+	if hedge.ctx.Err() == nil {
+		t.Fatal("the losing hedge was not cancelled")
+	}
+	if got := u.metrics.Registry().Sum("hostaggr_provider_hedge_wins_total"); got != 0 {
+		t.Fatalf("hedge wins = %g, want 0", got)
+	}
+}
+
+func TestHedgedAttemptBothFail(t *testing.T) {
+	p := newGatedProvider()
+	u := newHedgingUpstream(p)
+	done := startHedgedAttempt(u, BreakerTicket{})
+
+	original, hedge := <-p.calls, <-p.calls
+	hedge.reply <- providers.NewError("Mock1", providers.KindTransient, errProvider)
+	original.reply <- providers.NewError("Mock1", providers.KindTimeout, errProvider)
+
+	r := <-done
+	if !errors.Is(r.err, errProvider) || !r.hedged || r.hotels != nil {
+		t.Fatalf("hedgedAttempt() = %+v, want a failure", r)
+	}
+}
+
+// newProbingUpstream returns a hedging upstream whose breaker is half-open and the
+// ticket of the probe it admitted
+func newProbingUpstream(t *testing.T, p providers.Provider, probes int) (*upstream, BreakerTicket) {
+	t.Helper()
+	cfg := testBreakerConfig()
+	cfg.ConsecutiveFailures = 1
+	cfg.HalfOpenProbes = probes
+	b, now := newTestBreaker(cfg)
+	call(t, b, errProvider)
+	*now = now.Add(cfg.CoolDown)
+
+	u := newHedgingUpstream(p)
+	u.breaker = b
+	ticket, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, ticket
+}
+
+func TestHedgedAttemptAsksTheBreakerBeforeSpendingAToken(t *testing.T) {
+	p := newGatedProvider()
+	u, ticket := newProbingUpstream(t, p, 1)
+	done := startHedgedAttempt(u, ticket)
+
+	original := <-p.calls
+	// The only probe slot is taken by the original call, so the hedge is rejected
+	deadline := time.Now().Add(time.Second)
+	for u.metrics.Registry().Sum("hostaggr_provider_breaker_rejections_total") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the hedge was never put to the breaker")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	original.reply <- nil
+
+	if r := <-done; r.err != nil || r.hedged {
+		t.Fatalf("hedgedAttempt() = %+v, want the original's result unhedged", r)
+	}
+	if !u.hedges.allow() {
+		t.Fatal("a hedge token was spent on a hedge the breaker rejected")
+	}
+}
+
+func TestHedgedAttemptReleasesTheAdmissionWhenCapped(t *testing.T) {
+	p := newGatedProvider()
+	u, ticket := newProbingUpstream(t, p, 2)
+
+	// An empty bucket that signals when the hedge asks it for a token
+	asked := make(chan struct{}, 1)
+	now := time.Date(2026, 11, 2, 12, 0, 0, 0, time.UTC)
+	u.hedges = &hedgeLimiter{perSecond: 1, last: now, now: func() time.Time {
+		asked <- struct{}{}
+		return now
+	}}
+	done := startHedgedAttempt(u, ticket)
+
+	original := <-p.calls
+	<-asked
+	original.reply <- nil
+
+	if r := <-done; r.err != nil || r.hedged {
+		t.Fatalf("hedgedAttempt() = %+v, want the original's result unhedged", r)
+	}
+	// The hedge's admission was handed back, so the second probe slot is free
+	if _, err := u.breaker.Allow(); err != nil {
+		t.Fatalf("Allow() = %v, want the slot of the capped hedge released", err)
+	}
+}
@@ -0,0 +1,212 @@
+// Package limitertest is a conformance suite for search.Limiter implementations.
+// Every algorithm runs the same checks, driven by a fake clock so that nothing sleeps
+package limitertest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/search"
+)
+
+// Clock is a manually advanced clock. Its zero time is aligned to the minute so
+// window-based algorithms start at a window boundary
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock stopped at a fixed instant
+func NewClock() *Clock {
+	return &Clock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Now returns the clock's time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Factory builds the limiter under test from cfg, reading the time from now
+type Factory func(cfg config.RateLimitConfig, now func() time.Time) (search.Limiter, error)
+
+// Suite tiers. Burst equals Requests so that every algorithm reports the same limit
+const (
+	limit  = 5
+	window = 10 * time.Second
+
+	partnerLimit = 20
+	partnerKey   = "partner-key"
+)
+
+// Config returns the configuration the suite runs algorithm with
+func Config(algorithm string) config.RateLimitConfig {
+	return config.RateLimitConfig{
+		Algorithm:   algorithm,
+		KeyBy:       search.KeyByAPIKey,
+		DefaultTier: "free",
+		Tiers: map[string]config.RateLimitTier{
+			"free":    {Burst: limit, Requests: limit, Window: window},
+			"partner": {Burst: partnerLimit, Requests: partnerLimit, Window: window},
+		},
+		APIKeys:         map[string]string{partnerKey: "partner"},
+		CleanupInterval: time.Minute,
+		IdleTimeout:     time.Hour,
+	}
+}
+
+// Run runs the suite against the limiter built by newLimiter for algorithm
+func Run(t *testing.T, algorithm string, newLimiter Factory) {
+	start := func(t *testing.T) (search.Limiter, *Clock) {
+		t.Helper()
+		clock := NewClock()
+		l, err := newLimiter(Config(algorithm), clock.Now)
+		if err != nil {
+			t.Fatalf("new limiter: %v", err)
+		}
+		t.Cleanup(l.Stop)
+		return l, clock
+	}
+
+	anon := search.Client{IP: "192.0.2.1"}
+
+	t.Run("AllowsLimitThenRejects", func(t *testing.T) {
+		l, _ := start(t)
+		for i := range limit {
+			d := mustAllow(t, l, anon)
+			if !d.Allowed {
+				t.Fatalf("request %d rejected within the limit", i+1)
+			}
+			if d.Limit != limit || d.Remaining != limit-i-1 {
+				t.Fatalf("request %d: limit %d remaining %d, want %d and %d", i+1, d.Limit, d.Remaining, limit, limit-i-1)
+			}
+			if d.Tier != "free" {
+				t.Fatalf("tier %q, want free", d.Tier)
+			}
+		}
+
+		d := mustAllow(t, l, anon)
+		if d.Allowed {
+			t.Fatal("request over the limit allowed")
+		}
+		if d.Remaining != 0 || d.RetryAfter <= 0 || d.Reset < d.RetryAfter {
+			t.Fatalf("rejection: remaining %d retry after %s reset %s", d.Remaining, d.RetryAfter, d.Reset)
+		}
+	})
+
+	t.Run("HonoursRetryAfter", func(t *testing.T) {
+		l, clock := start(t)
+		clock.Advance(window / 3) // away from a window boundary
+		exhaust(t, l, anon)
+
+		d := mustAllow(t, l, anon)
+		if d.Allowed {
+			t.Fatal("request over the limit allowed")
+		}
+
+		clock.Advance(d.RetryAfter / 2)
+		if mustAllow(t, l, anon).Allowed {
+			t.Fatalf("allowed halfway through retry after %s", d.RetryAfter)
+		}
+
+		clock.Advance(d.RetryAfter - d.RetryAfter/2 + time.Millisecond)
+		if !mustAllow(t, l, anon).Allowed {
+			t.Fatalf("rejected after retry after %s", d.RetryAfter)
+		}
+	})
+
+	t.Run("RecoversFullLimit", func(t *testing.T) {
+		l, clock := start(t)
+		exhaust(t, l, anon)
+
+		clock.Advance(2 * window)
+		exhaust(t, l, anon)
+	})
+
+	t.Run("SustainsRate", func(t *testing.T) {
+		l, clock := start(t)
+		// Flood for ten windows with a request every 100ms
+		allowed := 0
+		for range 10 * int(window/(100*time.Millisecond)) {
+			if mustAllow(t, l, anon).Allowed {
+				allowed++
+			}
+			clock.Advance(100 * time.Millisecond)
+		}
+
+		// One window of slack either way covers bursts and window alignment
+		if lo, hi := 9*limit, 11*limit+limit; allowed < lo || allowed > hi {
+			t.Fatalf("allowed %d requests over ten windows, want %d to %d", allowed, lo, hi)
+		}
+	})
+
+	t.Run("KeepsClientsApart", func(t *testing.T) {
+		l, _ := start(t)
+		exhaust(t, l, anon)
+
+		if !mustAllow(t, l, search.Client{IP: "192.0.2.2"}).Allowed {
+			t.Fatal("another IP shares the exhausted client's limit")
+		}
+	})
+
+	t.Run("AppliesAPIKeyTier", func(t *testing.T) {
+		l, _ := start(t)
+		exhaust(t, l, anon)
+
+		// Keyed by API key: the partner shares one allowance across IPs
+		for i := range partnerLimit {
+			c := search.Client{IP: anon.IP, APIKey: partnerKey}
+			if i%2 == 1 {
+				c.IP = "198.51.100.7"
+			}
+			d := mustAllow(t, l, c)
+			if !d.Allowed || d.Tier != "partner" || d.Limit != partnerLimit {
+				t.Fatalf("partner request %d: %+v", i+1, d)
+			}
+		}
+		if mustAllow(t, l, search.Client{IP: "203.0.113.9", APIKey: partnerKey}).Allowed {
+			t.Fatal("partner allowance not shared across IPs")
+		}
+
+		if _, err := l.Allow(search.Client{IP: anon.IP, APIKey: "nope"}); !errors.Is(err, search.ErrUnknownAPIKey) {
+			t.Fatalf("unknown key: got %v, want ErrUnknownAPIKey", err)
+		}
+	})
+
+	t.Run("StopsMoreThanOnce", func(t *testing.T) {
+		l, _ := start(t)
+		l.Stop()
+		l.Stop()
+	})
+}
+
+// mustAllow calls Allow and fails the test on error
+func mustAllow(t *testing.T, l search.Limiter, c search.Client) search.Decision {
+	t.Helper()
+	d, err := l.Allow(c)
+	if err != nil {
+		t.Fatalf("allow %+v: %v", c, err)
+	}
+	return d
+}
+
+// exhaust spends the client's whole free-tier limit, failing if any request is rejected
+func exhaust(t *testing.T, l search.Limiter, c search.Client) {
+	t.Helper()
+	for i := range limit {
+		if !mustAllow(t, l, c).Allowed {
+			t.Fatalf("request %d rejected within the limit", i+1)
+		}
+	}
+}
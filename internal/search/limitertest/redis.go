@@ -0,0 +1,80 @@
+package limitertest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/resp"
+	"hostaggr/internal/resp/resptest"
+	"hostaggr/internal/search"
+)
+
+// ServeGCRA registers a Go twin of search.RedisGCRAScript on the stand-in server,
+// which cannot run Lua. Like the script it stores the arrival time as a decimal
+// integer, and it reads back nothing else, so a stored value in any other form
+// resets the client's allowance and fails the suite
+func ServeGCRA(s *resptest.Server) {
+	s.HandleScript(search.RedisGCRAScript, func(s *resptest.Server, keys, args []string) any {
+		if len(keys) != 1 || len(args) != 3 {
+			return resp.Error("ERR gcra script takes one key and three arguments")
+		}
+
+		var n [3]int64
+		for i, a := range args {
+			v, err := strconv.ParseInt(a, 10, 64)
+			if err != nil {
+				return resp.Error("ERR gcra script arguments must be integers")
+			}
+			n[i] = v
+		}
+		now, interval, tolerance := n[0], n[1], n[2]
+
+		tat := now
+		if v, ok := s.Lookup(keys[0]); ok {
+			if stored, err := strconv.ParseInt(v, 10, 64); err == nil {
+				tat = max(stored, now)
+			}
+		}
+		next := tat + interval
+		allowAt := next - tolerance
+		if now < allowAt {
+			return []any{int64(0), tat - now, allowAt - now}
+		}
+
+		ttl := int64(math.Ceil(float64(next-now) / 1000))
+		s.Store(keys[0], fmt.Sprintf("%d", next), time.Duration(ttl)*time.Millisecond)
+		return []any{int64(1), next - now, int64(0)}
+	})
+}
+
+// RedisFactory returns a Factory building Redis-backed limiters against the stand-in
+// server, with the GCRA script registered. Every limiter gets its own key prefix, so
+// limiters built for different subtests do not share state
+func RedisFactory(s *resptest.Server) Factory {
+	ServeGCRA(s)
+	return RedisAddrFactory(s.Addr(), nil)
+}
+
+// RedisAddrFactory returns a Factory building Redis-backed limiters against the server
+// at addr, recording to metrics. The key prefixes carry the time the factory was made,
+// so reruns against a long-lived server start from fresh keys
+func RedisAddrFactory(addr string, metrics *obs.Metrics) Factory {
+	run := time.Now().UnixNano()
+
+	var n atomic.Int64
+	return func(cfg config.RateLimitConfig, now func() time.Time) (search.Limiter, error) {
+		cfg.KeyPrefix = fmt.Sprintf("limitertest:%d:%d:", run, n.Add(1))
+		return search.NewRedisLimiterWithClock(cfg, config.RedisConfig{
+			Addr:          addr,
+			DialTimeout:   time.Second,
+			IOTimeout:     time.Second,
+			PoolSize:      4,
+			RetryInterval: time.Second,
+		}, metrics, now)
+	}
+}
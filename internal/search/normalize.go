@@ -0,0 +1,104 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+	"hostaggr/internal/textfold"
+)
+
+// ErrInvalidSearch is returned for a search request that cannot be normalized
+var ErrInvalidSearch = errors.New("invalid search")
+
+// normalizer canonicalises search requests so that equivalent searches share cache
+// entries and provider calls. Cities are trimmed, whitespace-collapsed, stripped of
+// diacritics, case-folded with Unicode rules and mapped through the alias table
+type normalizer struct {
+	aliases         map[string]string // normalized alias -> normalized canonical city
+	defaultCurrency string
+}
+
+// newNormalizer builds a normalizer. Both sides of the alias table are normalized,
+// so entries may be written in any case or accentuation
+func newNormalizer(aliases map[string]string, defaultCurrency string) *normalizer {
+	n := &normalizer{
+		aliases:         make(map[string]string, len(aliases)),
+		defaultCurrency: defaultCurrency,
+	}
+	for alias, city := range aliases {
+		n.aliases[foldCity(alias)] = foldCity(city)
+	}
+	return n
+}
+
+// normalize returns the canonical form of req, or an error wrapping ErrInvalidSearch
+func (n *normalizer) normalize(req models.SearchRequest) (models.SearchRequest, error) {
+	city := n.city(req.City)
+	if city == "" {
+		return models.SearchRequest{}, fmt.Errorf("%w: city is required", ErrInvalidSearch)
+	}
+
+	checkin, err := canonicalDate(req.CheckIn)
+	if err != nil {
+		return models.SearchRequest{}, fmt.Errorf("%w: %w", ErrInvalidSearch, err)
+	}
+
+	if req.Nights <= 0 || req.Adults <= 0 {
+		return models.SearchRequest{}, fmt.Errorf("%w: nights and adults must be positive", ErrInvalidSearch)
+	}
+
+	code := n.defaultCurrency
+	if strings.TrimSpace(req.Currency) != "" {
+		if code, err = currency.Normalize(req.Currency); err != nil {
+			return models.SearchRequest{}, fmt.Errorf("%w: currency must be a supported ISO 4217 code", ErrInvalidSearch)
+		}
+	}
+
+	switch req.Offers {
+	case "":
+		req.Offers = models.OffersAll
+	case models.OffersAll, models.OffersBest:
+	default:
+		return models.SearchRequest{}, fmt.Errorf("%w: offers must be %s or %s", ErrInvalidSearch, models.OffersAll, models.OffersBest)
+	}
+
+	req.City = city
+	req.CheckIn = checkin
+	req.Currency = code
+	return req, nil
+}
+
+// city returns the canonical form of a city name, resolving aliases
+func (n *normalizer) city(s string) string {
+	city := foldCity(s)
+	if canonical, ok := n.aliases[city]; ok {
+		return canonical
+	}
+	return city
+}
+
+// displayCity returns a city as the user wrote it, trimmed and whitespace-collapsed,
+// for echoing in responses
+func displayCity(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// foldCity trims and collapses whitespace, strips combining marks after canonical
+// decomposition ("Fès" -> "fes") and case-folds the result
+func foldCity(s string) string {
+	return textfold.Fold(strings.Join(strings.Fields(s), " "))
+}
+
+// canonicalDate checks that s is a real calendar date in YYYY-MM-DD form
+func canonicalDate(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return "", errors.New("checkin must be a valid date in YYYY-MM-DD format")
+	}
+	return t.Format(time.DateOnly), nil
+}
@@ -0,0 +1,39 @@
+package search
+
+import "testing"
+
+func TestNormalizerCity(t *testing.T) {
+	n := newNormalizer(map[string]string{"Marrakesh": "Marrakech"}, "EUR")
+
+	for in, want := range map[string]string{
+		"Fès":           "fes",
+		"  FES ":        "fes",
+		"Marrakesh":     "marrakech",
+		"marrakech":     "marrakech",
+		"New   York":    "new york",
+		"São Paulo":     "sao paulo",
+		"Marrakesh Sud": "marrakesh sud",
+	} {
+		if got := n.city(in); got != want {
+			t.Errorf("city(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCacheFilterMatchesNormalizedCity(t *testing.T) {
+	n := newNormalizer(map[string]string{"Marrakesh": "Marrakech"}, "EUR")
+	key := cacheKey{city: "marrakech", checkin: "2026-11-02"}
+
+	if !(CacheFilter{City: n.city("Marrakesh")}).match(key) {
+		t.Error("filter on an alias does not match its canonical city")
+	}
+	if (CacheFilter{City: n.city("Fès")}).match(key) {
+		t.Error("filter matches another city")
+	}
+}
+
+func TestDisplayCityKeepsTheUsersSpelling(t *testing.T) {
+	if got := displayCity("  Fès   el Bali "); got != "Fès el Bali" {
+		t.Fatalf("displayCity() = %q", got)
+	}
+}
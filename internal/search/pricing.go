@@ -0,0 +1,81 @@
+package search
+
+import (
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+)
+
+// validPricing checks that an offer's price basis and charges can be normalized:
+// a price per night or per stay, and known charges in the price's currency
+func validPricing(h models.ProviderHotel) bool {
+	if h.Basis != models.PerNight && h.Basis != models.PerStay {
+		return false
+	}
+	for _, c := range h.Charges {
+		if !c.Kind.Valid() || c.Amount.Currency() != h.Price.Currency() || c.Amount.Sign() < 0 {
+			return false
+		}
+		if _, ok := c.Basis.Units(1, 1); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// stayOffer is an offer normalized to the whole stay, in the provider's currency
+type stayOffer struct {
+	total   currency.Money  // everything due for the stay
+	charges []models.Charge // each charge over the stay
+}
+
+// stayPrice normalizes a valid offer to the whole stay: the quoted price scaled to
+// the nights, plus every charge it does not include. This is what offers are
+// compared on, whatever basis their provider quotes
+func stayPrice(h models.ProviderHotel, nights, adults int) (stayOffer, error) {
+	units, _ := h.Basis.Units(nights, adults)
+	total, err := h.Price.Mul(units)
+	if err != nil {
+		return stayOffer{}, err
+	}
+
+	var charges []models.Charge
+	for _, c := range h.Charges {
+		units, _ := c.Basis.Units(nights, adults)
+		amount, err := c.Amount.Mul(units)
+		if err != nil {
+			return stayOffer{}, err
+		}
+		if !c.Included {
+			if total, err = total.Add(amount); err != nil {
+				return stayOffer{}, err
+			}
+		}
+		charges = append(charges, models.Charge{Kind: c.Kind, Amount: amount, Basis: models.PerStay, Included: c.Included})
+	}
+
+	return stayOffer{total: total, charges: charges}, nil
+}
+
+// convert prices a stay offer in the target currency and spreads its total over
+// the nights. The total is converted on its own rather than summed from converted
+// parts, so it is rounded once
+func (o stayOffer) convert(rates *currency.Rates, target string, nights int) (models.Hotel, error) {
+	price, err := rates.Convert(o.total, target, currency.RoundHalfUp)
+	if err != nil {
+		return models.Hotel{}, err
+	}
+	nightly, err := price.Div(int64(nights), currency.RoundHalfUp)
+	if err != nil {
+		return models.Hotel{}, err
+	}
+
+	var charges []models.Charge
+	for _, c := range o.charges {
+		if c.Amount, err = rates.Convert(c.Amount, target, currency.RoundHalfUp); err != nil {
+			return models.Hotel{}, err
+		}
+		charges = append(charges, c)
+	}
+
+	return models.Hotel{Price: price, NightlyPrice: nightly, Charges: charges}, nil
+}
@@ -0,0 +1,190 @@
+package search
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket holds up to burst tokens, refilled continuously at the tier's rate.
+// Fractions are kept so that slow refill rates are not rounded away
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newTokenBucket(t tier, now time.Time) limitState {
+	return &tokenBucket{tokens: float64(t.burst), lastRefill: now}
+}
+
+func (b *tokenBucket) take(t tier, now time.Time) Decision {
+	burst := float64(t.burst)
+	if elapsed := now.Sub(b.lastRefill).Seconds(); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed*t.rate(), burst)
+		b.lastRefill = now
+	}
+
+	d := Decision{Limit: t.burst}
+
+	// Check if we have a whole token available
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = tokensTime(t, 1-b.tokens)
+	}
+
+	d.Remaining = int(math.Floor(b.tokens))
+	d.Reset = tokensTime(t, burst-b.tokens)
+	return d
+}
+
+// tokensTime returns how long the tier takes to earn n requests
+func tokensTime(t tier, n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / t.rate() * float64(time.Second))
+}
+
+// slidingWindow is a sliding-window counter. Requests are counted in fixed windows
+// and the previous window's count is weighted by how much of it still overlaps the
+// sliding window ending now. It needs constant memory per client at the cost of
+// assuming requests were spread evenly over the previous window
+type slidingWindow struct {
+	start    time.Time // of the current fixed window
+	previous int
+	current  int
+}
+
+func newSlidingWindow(t tier, now time.Time) limitState {
+	return &slidingWindow{start: now.Truncate(t.window)}
+}
+
+func (w *slidingWindow) take(t tier, now time.Time) Decision {
+	w.advance(t, now)
+
+	d := Decision{Limit: t.requests}
+	if w.estimate(t, now) < float64(t.requests) {
+		w.current++
+		d.Allowed = true
+	} else {
+		d.RetryAfter = w.retryAfter(t, now)
+	}
+
+	// Further requests fit while the estimate stays under the limit
+	d.Remaining = max(int(math.Ceil(float64(t.requests)-w.estimate(t, now))), 0)
+	switch {
+	case w.current > 0:
+		d.Reset = w.start.Add(2 * t.window).Sub(now)
+	case w.previous > 0:
+		d.Reset = w.start.Add(t.window).Sub(now)
+	}
+	return d
+}
+
+// advance moves the fixed windows forward to the one containing now
+func (w *slidingWindow) advance(t tier, now time.Time) {
+	start := now.Truncate(t.window)
+	switch {
+	case !start.After(w.start):
+	case start.Equal(w.start.Add(t.window)):
+		w.previous, w.current = w.current, 0
+		w.start = start
+	default:
+		w.previous, w.current = 0, 0
+		w.start = start
+	}
+}
+
+// estimate returns the weighted count of the sliding window ending now
+func (w *slidingWindow) estimate(t tier, now time.Time) float64 {
+	overlap := 1 - float64(now.Sub(w.start))/float64(t.window)
+	return float64(w.previous)*overlap + float64(w.current)
+}
+
+// retryAfter returns how long until the estimate drops under the limit again
+func (w *slidingWindow) retryAfter(t tier, now time.Time) time.Duration {
+	limit := float64(t.requests)
+	elapsed := float64(now.Sub(w.start)) / float64(t.window)
+
+	// Within the current window the previous count keeps fading
+	if float64(w.current) < limit {
+		need := 1 - (limit-float64(w.current))/float64(w.previous)
+		return time.Duration((need - elapsed) * float64(t.window))
+	}
+
+	// Otherwise wait for the current count to become the fading previous one
+	need := 1 - limit/float64(w.current)
+	return time.Duration((1 - elapsed + need) * float64(t.window))
+}
+
+// slidingLog keeps the time of every allowed request in the window. It is exact,
+// at the cost of memory proportional to the limit
+type slidingLog struct {
+	times []time.Time // oldest first
+}
+
+func newSlidingLog(t tier, now time.Time) limitState {
+	return &slidingLog{times: make([]time.Time, 0, t.requests)}
+}
+
+func (l *slidingLog) take(t tier, now time.Time) Decision {
+	// Forget requests that have left the window
+	cutoff := now.Add(-t.window)
+	drop := 0
+	for drop < len(l.times) && !l.times[drop].After(cutoff) {
+		drop++
+	}
+	l.times = append(l.times[:0], l.times[drop:]...)
+
+	d := Decision{Limit: t.requests}
+	if len(l.times) < t.requests {
+		l.times = append(l.times, now)
+		d.Allowed = true
+	} else {
+		// The request that frees a slot is the one that many from the newest
+		d.RetryAfter = l.times[len(l.times)-t.requests].Add(t.window).Sub(now)
+	}
+
+	d.Remaining = t.requests - len(l.times)
+	if len(l.times) > 0 {
+		d.Reset = l.times[len(l.times)-1].Add(t.window).Sub(now)
+	}
+	return d
+}
+
+// gcra is the generic cell rate algorithm. It stores only the theoretical arrival
+// time of the next request at the sustained rate; a request is allowed while that
+// time is less than a burst's worth of intervals ahead of now
+type gcra struct {
+	tat time.Time
+}
+
+func newGCRA(t tier, now time.Time) limitState {
+	return &gcra{tat: now}
+}
+
+func (g *gcra) take(t tier, now time.Time) Decision {
+	interval := t.interval()
+	tolerance := time.Duration(t.burst) * interval
+
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+
+	d := Decision{Limit: t.burst}
+	if allowAt := next.Add(-tolerance); now.Before(allowAt) {
+		d.RetryAfter = allowAt.Sub(now)
+	} else {
+		g.tat = next
+		tat = next
+		d.Allowed = true
+	}
+
+	// Each interval tat is ahead of now is one request of the burst spent
+	d.Remaining = max(t.burst-int(math.Ceil(float64(tat.Sub(now))/float64(interval))), 0)
+	d.Reset = tat.Sub(now)
+	return d
+}
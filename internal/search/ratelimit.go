@@ -1,100 +1,291 @@
 package search
 
 import (
+	"errors"
+	"fmt"
+	"net/netip"
 	"sync"
 	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/obs"
+)
+
+// ErrUnknownAPIKey is returned by Limiter.Allow for an API key that maps to no tier
+var ErrUnknownAPIKey = errors.New("unknown API key")
+
+// Rate limit key modes
+const (
+	KeyByIP          = "ip"
+	KeyByAPIKey      = "api_key"    // anonymous requests fall back to their IP
+	KeyByAPIKeyAndIP = "api_key_ip" // one bucket per key and IP pair
 )
 
-// bucket represents a token bucket for a single IP address
-type bucket struct {
-	tokens     int
-	lastRefill time.Time
+// Rate limiting algorithm names accepted by config
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window" // sliding-window counter
+	AlgorithmSlidingLog    = "sliding_log"
+	AlgorithmGCRA          = "gcra"
+)
+
+// Limiter decides whether a client may make one more request
+type Limiter interface {
+	// Allow spends one request of the client's allowance if it has any left.
+	// It returns ErrUnknownAPIKey for an API key that is not configured
+	Allow(c Client) (Decision, error)
+	// Stop releases background goroutines and connections
+	Stop()
+}
+
+// Client identifies the caller of one request
+type Client struct {
+	IP     string
+	APIKey string // empty for anonymous requests
+}
+
+// Decision is the outcome of one rate limit check, with what the standard
+// RateLimit-* and Retry-After headers report
+type Decision struct {
+	Allowed    bool
+	Tier       string
+	Limit      int           // requests the client may make at once
+	Remaining  int           // requests left after this one
+	Reset      time.Duration // until the full limit is available again
+	RetryAfter time.Duration // until the next request is allowed, zero when allowed
+}
+
+// tier is one named set of limits: a burst, and requests per window
+type tier struct {
+	name     string
+	burst    int
+	requests int
+	window   time.Duration
+}
+
+// rate returns the sustained rate in requests per second
+func (t tier) rate() float64 {
+	return float64(t.requests) / t.window.Seconds()
+}
+
+// interval returns the time between requests at the sustained rate
+func (t tier) interval() time.Duration {
+	return t.window / time.Duration(t.requests)
 }
 
-// RateLimiter implements a token bucket rate limiter
+// limitState is the state of one client under some algorithm. Calls are serialized
+// by the limiter lock, so implementations need no locking of their own
+type limitState interface {
+	// take decides one request at time now, filling every Decision field but Tier
+	take(t tier, now time.Time) Decision
+}
+
+// newLimitState returns the constructor of the named algorithm's per-client state
+func newLimitState(algorithm string) (func(t tier, now time.Time) limitState, error) {
+	switch algorithm {
+	case AlgorithmTokenBucket:
+		return newTokenBucket, nil
+	case AlgorithmSlidingWindow:
+		return newSlidingWindow, nil
+	case AlgorithmSlidingLog:
+		return newSlidingLog, nil
+	case AlgorithmGCRA:
+		return newGCRA, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", algorithm)
+	}
+}
+
+// clientLimit is the state of one client and when it was last used
+type clientLimit struct {
+	state    limitState
+	lastSeen time.Time
+}
+
+// RateLimiter limits each client in process memory with the configured algorithm.
+// Clients are keyed by IP, API key or both, and each API key's tier sets its limits
 type RateLimiter struct {
-	mu         sync.Mutex
-	buckets    map[string]*bucket
-	maxTokens  int
-	refillRate time.Duration
+	mu              sync.Mutex
+	clients         map[string]*clientLimit
+	newState        func(t tier, now time.Time) limitState
+	resolver        *clientResolver
+	cleanupInterval time.Duration
+	idleTimeout     time.Duration
+	now             func() time.Time
+	metrics         *obs.Metrics
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
-// NewRateLimiter creates a new rate limiter that allows 10 requests per minute per IP
-func NewRateLimiter() *RateLimiter {
-	rl := &RateLimiter{
-		buckets:    make(map[string]*bucket),
-		maxTokens:  10,
-		refillRate: 1 * time.Minute,
+// NewRateLimiter creates a rate limiter with the configured algorithm, tiers and API keys
+func NewRateLimiter(cfg config.RateLimitConfig, metrics *obs.Metrics) (*RateLimiter, error) {
+	return NewRateLimiterWithClock(cfg, metrics, time.Now)
+}
+
+// NewRateLimiterWithClock creates a rate limiter that reads the time from now, so
+// tests can drive it without sleeping. The cleanup goroutine still ticks in real time
+func NewRateLimiterWithClock(cfg config.RateLimitConfig, metrics *obs.Metrics, now func() time.Time) (*RateLimiter, error) {
+	newState, err := newLimitState(cfg.Algorithm)
+	if err != nil {
+		return nil, err
 	}
 
+	rl := &RateLimiter{
+		clients:         make(map[string]*clientLimit),
+		newState:        newState,
+		resolver:        newClientResolver(cfg),
+		cleanupInterval: cfg.CleanupInterval,
+		idleTimeout:     cfg.IdleTimeout,
+		now:             now,
+		metrics:         metrics,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
 	// Start background cleanup goroutine
 	go rl.cleanup()
 
-	return rl
+	return rl, nil
+}
+
+// newTiers converts the configured tiers
+func newTiers(cfg config.RateLimitConfig) map[string]tier {
+	tiers := make(map[string]tier, len(cfg.Tiers))
+	for name, t := range cfg.Tiers {
+		tiers[name] = tier{
+			name:     name,
+			burst:    t.Burst,
+			requests: t.Requests,
+			window:   t.Window,
+		}
+	}
+	return tiers
 }
 
-// Allow checks if a request from the given IP address should be allowed
-// Returns true if the request is allowed, false if rate limit exceeded
-func (rl *RateLimiter) Allow(ip string) bool {
+// Allow checks if a request from the given client should be allowed
+func (rl *RateLimiter) Allow(c Client) (Decision, error) {
+	t, key, err := rl.resolver.resolve(c)
+	if err != nil {
+		return Decision{}, err
+	}
+
 	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	now := rl.now()
 
-	// Get or create bucket for this IP
-	b, exists := rl.buckets[ip]
+	// Get or create the state of this client
+	cl, exists := rl.clients[key]
 	if !exists {
-		b = &bucket{
-			tokens:     rl.maxTokens,
-			lastRefill: time.Now(),
-		}
-		rl.buckets[ip] = b
+		cl = &clientLimit{state: rl.newState(t, now)}
+		rl.clients[key] = cl
 	}
+	cl.lastSeen = now
 
-	// Refill tokens based on time elapsed
-	rl.refillBucket(b)
+	d := cl.state.take(t, now)
+	rl.mu.Unlock()
 
-	// Check if we have tokens available
-	if b.tokens > 0 {
-		b.tokens--
-		return true
+	d.Tier = t.name
+	if !d.Allowed {
+		rl.metrics.RateLimitRejected(t.name)
 	}
-
-	return false
+	return d, nil
 }
 
-// refillBucket calculates and adds tokens based on time elapsed since last refill
-func (rl *RateLimiter) refillBucket(b *bucket) {
-	now := time.Now()
-	elapsed := now.Sub(b.lastRefill)
+// clientResolver maps a client to its tier and the key its state is stored under
+type clientResolver struct {
+	keyBy       string
+	tiers       map[string]tier
+	defaultTier tier
+	apiKeys     map[string]string // API key -> tier name
+	ipv6Bits    int               // prefix IPv6 addresses are grouped by, zero for none
+}
 
-	// Calculate how many tokens to add based on elapsed time
-	// refillRate is the time to fully refill the bucket
-	tokensToAdd := int(elapsed.Seconds() / rl.refillRate.Seconds() * float64(rl.maxTokens))
+func newClientResolver(cfg config.RateLimitConfig) *clientResolver {
+	tiers := newTiers(cfg)
+	return &clientResolver{
+		keyBy:       cfg.KeyBy,
+		tiers:       tiers,
+		defaultTier: tiers[cfg.DefaultTier],
+		apiKeys:     cfg.APIKeys,
+		ipv6Bits:    cfg.IPv6Prefix,
+	}
+}
 
-	if tokensToAdd > 0 {
-		b.tokens += tokensToAdd
-		if b.tokens > rl.maxTokens {
-			b.tokens = rl.maxTokens
+// resolve returns the tier of a client, its API key's or the default for anonymous
+// requests, and the key its state is stored under. The tier is part of the key so
+// that an API key whose tier changes does not inherit state sized for another
+func (r *clientResolver) resolve(c Client) (tier, string, error) {
+	t := r.defaultTier
+	if c.APIKey != "" {
+		name, ok := r.apiKeys[c.APIKey]
+		if !ok {
+			return tier{}, "", ErrUnknownAPIKey
 		}
-		b.lastRefill = now
+		t = r.tiers[name]
+	}
+
+	switch {
+	case c.APIKey == "" || r.keyBy == KeyByIP:
+		return t, t.name + "|ip|" + r.ip(c.IP), nil
+	case r.keyBy == KeyByAPIKey:
+		return t, t.name + "|key|" + c.APIKey, nil
+	default:
+		return t, t.name + "|key|" + c.APIKey + "|ip|" + r.ip(c.IP), nil
 	}
 }
 
-// cleanup removes old buckets every 5 minutes to prevent memory leaks
+// ip returns the form of an address used in keys: IPv6 addresses are reduced to
+// their network when grouping is on, anything unparsable is kept as is
+func (r *clientResolver) ip(s string) string {
+	if r.ipv6Bits == 0 {
+		return s
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil || !addr.Is6() || addr.Is4In6() {
+		return s
+	}
+	prefix, err := addr.WithZone("").Prefix(r.ipv6Bits)
+	if err != nil {
+		return s
+	}
+	return prefix.String()
+}
+
+// cleanup removes the state of idle clients every cleanup interval to prevent
+// memory leaks. Config guarantees the idle timeout outlasts every tier's window, so
+// a dropped client would have been back at its full limit anyway
 func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
+	defer close(rl.done)
+
+	ticker := time.NewTicker(rl.cleanupInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-rl.stop:
+			return
+		case <-ticker.C:
+		}
+
 		rl.mu.Lock()
 
-		// Remove buckets that haven't been used in the last 10 minutes
-		cutoff := time.Now().Add(-10 * time.Minute)
-		for ip, b := range rl.buckets {
-			if b.lastRefill.Before(cutoff) {
-				delete(rl.buckets, ip)
+		// Remove clients that haven't been seen within the idle timeout
+		cutoff := rl.now().Add(-rl.idleTimeout)
+		for key, cl := range rl.clients {
+			if cl.lastSeen.Before(cutoff) {
+				delete(rl.clients, key)
 			}
 		}
 
 		rl.mu.Unlock()
 	}
 }
+
+// Stop terminates the background cleanup goroutine and waits for it to exit.
+// It is safe to call more than once
+func (rl *RateLimiter) Stop() {
+	rl.stopOnce.Do(func() {
+		close(rl.stop)
+	})
+	<-rl.done
+}
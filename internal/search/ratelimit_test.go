@@ -0,0 +1,69 @@
+package search_test
+
+import (
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/search"
+	"hostaggr/internal/search/limitertest"
+)
+
+func localFactory(cfg config.RateLimitConfig, now func() time.Time) (search.Limiter, error) {
+	return search.NewRateLimiterWithClock(cfg, nil, now)
+}
+
+func TestRateLimiter(t *testing.T) {
+	for _, algorithm := range []string{
+		search.AlgorithmTokenBucket,
+		search.AlgorithmSlidingWindow,
+		search.AlgorithmSlidingLog,
+		search.AlgorithmGCRA,
+	} {
+		t.Run(algorithm, func(t *testing.T) {
+			limitertest.Run(t, algorithm, localFactory)
+		})
+	}
+}
+
+func TestRateLimiterGroupsIPv6Networks(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      int
+		first, next string
+		shared      bool
+	}{
+		{"same /64", 64, "2001:db8:1:2::1", "2001:db8:1:2:ffff::9", true},
+		{"neighbouring /64", 64, "2001:db8:1:2::1", "2001:db8:1:3::1", false},
+		{"zone dropped", 64, "fe80::1%eth0", "fe80::2", true},
+		{"same /48", 48, "2001:db8:1:2::1", "2001:db8:1:ff::1", true},
+		{"grouping off", 0, "2001:db8:1:2::1", "2001:db8:1:2::2", false},
+		{"IPv4 never grouped", 64, "192.0.2.1", "192.0.2.2", false},
+		{"4-in-6 never grouped", 64, "::ffff:192.0.2.1", "::ffff:192.0.2.2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := limitertest.Config(search.AlgorithmTokenBucket)
+			cfg.IPv6Prefix = tt.prefix
+			l, err := search.NewRateLimiterWithClock(cfg, nil, limitertest.NewClock().Now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Stop()
+
+			for range cfg.Tiers["free"].Burst {
+				if d, err := l.Allow(search.Client{IP: tt.first}); err != nil || !d.Allowed {
+					t.Fatalf("Allow(%s) within its burst = %+v, %v", tt.first, d, err)
+				}
+			}
+
+			d, err := l.Allow(search.Client{IP: tt.next})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Allowed == tt.shared {
+				t.Fatalf("%s after %s exhausted its limit: allowed = %v, want %v", tt.next, tt.first, d.Allowed, !tt.shared)
+			}
+		})
+	}
+}
@@ -0,0 +1,470 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/resp"
+)
+
+// redisEntryVersion is bumped whenever the stored layout changes. Entries written with
+// another version are treated as misses rather than misread
+const redisEntryVersion = 6
+
+// redisEntry is the JSON document stored for each search
+type redisEntry struct {
+	Version    int            `json:"v"`
+	StoredAt   time.Time      `json:"stored_at"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	StaleUntil time.Time      `json:"stale_until"`
+	ErrorUntil time.Time      `json:"error_until"`
+	Hotels     []models.Hotel `json:"hotels"`
+}
+
+// redisProviderEntry is the JSON document stored for one provider's raw results
+type redisProviderEntry struct {
+	Version   int                    `json:"v"`
+	StoredAt  time.Time              `json:"stored_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Hotels    []models.ProviderHotel `json:"hotels"`
+}
+
+// RedisCache stores search results in a server speaking the Redis protocol so that
+// replicas share them. Redis expires each entry once its last window has passed.
+// While Redis is unreachable, reads and writes go to a local memory cache instead
+type RedisCache struct {
+	client          *resp.Client
+	prefix          string
+	ttl             time.Duration
+	providerTTL     time.Duration
+	staleWhileReval time.Duration
+	staleIfError    time.Duration
+	metrics         *obs.Metrics
+
+	// Fallback while Redis is down
+	local         *Cache
+	retryInterval time.Duration
+	downUntil     atomic.Int64 // unix nanoseconds; Redis is skipped until then
+}
+
+// NewRedisCache creates a Redis-backed cache with a local memory fallback built from the same settings.
+// An unreachable server is not an error: the cache starts on the fallback and retries later
+func NewRedisCache(cfg config.CacheConfig, redisCfg config.RedisConfig, metrics *obs.Metrics) (*RedisCache, error) {
+	local, err := NewCache(cfg, metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &RedisCache{
+		client: resp.NewClient(resp.Options{
+			Addr:        redisCfg.Addr,
+			Password:    redisCfg.Password,
+			DB:          redisCfg.DB,
+			DialTimeout: redisCfg.DialTimeout,
+			IOTimeout:   redisCfg.IOTimeout,
+			PoolSize:    redisCfg.PoolSize,
+		}),
+		prefix:          cfg.KeyPrefix,
+		ttl:             cfg.TTL,
+		providerTTL:     cfg.ProviderTTL,
+		staleWhileReval: cfg.StaleWhileRevalidate,
+		staleIfError:    cfg.StaleIfError,
+		metrics:         metrics,
+		local:           local,
+		retryInterval:   redisCfg.RetryInterval,
+	}
+
+	metrics.SetCacheBackendUp(true)
+	if err := c.client.Ping(context.Background()); err != nil {
+		c.markDown("ping", err)
+	}
+
+	return c, nil
+}
+
+// Get retrieves cached hotels from Redis, or from the local fallback while Redis is down
+func (c *RedisCache) Get(req models.SearchRequest) ([]models.Hotel, Freshness, bool) {
+	if !c.available() {
+		return c.local.Get(req)
+	}
+
+	data, err := resp.String(c.client.Do(context.Background(), "GET", c.key(req)))
+	if errors.Is(err, resp.ErrNil) {
+		c.metrics.CacheMiss()
+		return nil, 0, false
+	}
+	if err != nil {
+		c.markDown("get", err)
+		return c.local.Get(req)
+	}
+	c.markUp()
+
+	var stored redisEntry
+	if err := json.Unmarshal([]byte(data), &stored); err != nil || stored.Version != redisEntryVersion {
+		// Written by an incompatible release; the next Set overwrites it
+		c.metrics.CacheMiss()
+		return nil, 0, false
+	}
+
+	entry := cacheEntry{
+		hotels:     stored.Hotels,
+		expiresAt:  stored.ExpiresAt,
+		staleUntil: stored.StaleUntil,
+		errorUntil: stored.ErrorUntil,
+	}
+	freshness, usable := entry.freshness(time.Now())
+	switch {
+	case !usable:
+		c.metrics.CacheMiss()
+		return nil, 0, false
+	case freshness == Fresh:
+		c.metrics.CacheHit()
+	case freshness == Stale:
+		c.metrics.CacheHit()
+		c.metrics.CacheStaleServed("revalidate")
+	default:
+		c.metrics.CacheMiss()
+	}
+
+	return entry.hotels, freshness, true
+}
+
+// Set stores hotels in Redis with a server-side expiry at the end of the last window,
+// or in the local fallback while Redis is down
+func (c *RedisCache) Set(req models.SearchRequest, hotels []models.Hotel) {
+	if !c.available() {
+		c.local.Set(req, hotels)
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.ttl)
+	stored := redisEntry{
+		Version:    redisEntryVersion,
+		StoredAt:   now,
+		ExpiresAt:  expiresAt,
+		StaleUntil: expiresAt.Add(c.staleWhileReval),
+		ErrorUntil: expiresAt.Add(c.staleIfError),
+		Hotels:     hotels,
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		c.metrics.CacheBackendError("encode")
+		return
+	}
+
+	lifetime := c.ttl + max(c.staleWhileReval, c.staleIfError)
+	px := max(lifetime.Milliseconds(), 1)
+
+	_, err = c.client.Do(context.Background(), "SET", c.key(req), string(data), "PX", strconv.FormatInt(px, 10))
+	if err != nil {
+		c.markDown("set", err)
+		c.local.Set(req, hotels)
+		return
+	}
+	c.markUp()
+}
+
+// GetProvider retrieves one provider's raw hotels from Redis, or from the local fallback while Redis is down
+func (c *RedisCache) GetProvider(provider string, req models.SearchRequest) ([]models.ProviderHotel, bool) {
+	if !c.available() {
+		return c.local.GetProvider(provider, req)
+	}
+
+	data, err := resp.String(c.client.Do(context.Background(), "GET", c.providerKey(provider, req)))
+	if errors.Is(err, resp.ErrNil) {
+		c.metrics.ProviderCacheMiss(provider)
+		return nil, false
+	}
+	if err != nil {
+		c.markDown("get", err)
+		return c.local.GetProvider(provider, req)
+	}
+	c.markUp()
+
+	var stored redisProviderEntry
+	err = json.Unmarshal([]byte(data), &stored)
+	if err != nil || stored.Version != redisEntryVersion || time.Now().After(stored.ExpiresAt) {
+		c.metrics.ProviderCacheMiss(provider)
+		return nil, false
+	}
+
+	c.metrics.ProviderCacheHit(provider)
+	return stored.Hotels, true
+}
+
+// SetProvider stores one provider's raw hotels in Redis for ttl, or for the configured
+// provider TTL when ttl is not positive
+func (c *RedisCache) SetProvider(provider string, req models.SearchRequest, hotels []models.ProviderHotel, ttl time.Duration) {
+	if !c.available() {
+		c.local.SetProvider(provider, req, hotels, ttl)
+		return
+	}
+	if ttl <= 0 {
+		ttl = c.providerTTL
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(redisProviderEntry{
+		Version:   redisEntryVersion,
+		StoredAt:  now,
+		ExpiresAt: now.Add(ttl),
+		Hotels:    hotels,
+	})
+	if err != nil {
+		c.metrics.CacheBackendError("encode")
+		return
+	}
+
+	px := max(ttl.Milliseconds(), 1)
+	_, err = c.client.Do(context.Background(), "SET", c.providerKey(provider, req), string(data), "PX", strconv.FormatInt(px, 10))
+	if err != nil {
+		c.markDown("set", err)
+		c.local.SetProvider(provider, req, hotels, ttl)
+		return
+	}
+	c.markUp()
+}
+
+// Stop stops the local fallback and closes the Redis connections
+func (c *RedisCache) Stop() {
+	c.local.Stop()
+	c.client.Close()
+}
+
+// key returns the Redis key of a search request
+func (c *RedisCache) key(req models.SearchRequest) string {
+	return c.prefix + newCacheKey(req).String()
+}
+
+// providerKey returns the Redis key of one provider's raw results
+func (c *RedisCache) providerKey(provider string, req models.SearchRequest) string {
+	return c.prefix + newProviderCacheKey(provider, req).String()
+}
+
+// available reports whether Redis should be tried, i.e. the retry interval since the last failure has passed
+func (c *RedisCache) available() bool {
+	return time.Now().UnixNano() >= c.downUntil.Load()
+}
+
+// markDown switches to the local fallback for the retry interval
+func (c *RedisCache) markDown(op string, err error) {
+	c.metrics.CacheBackendError(op)
+	c.metrics.SetCacheBackendUp(false)
+
+	if c.downUntil.Swap(time.Now().Add(c.retryInterval).UnixNano()) == 0 {
+		slog.Warn("redis cache unreachable, using local memory", "op", op, "error", err, "retry_in", c.retryInterval)
+	}
+}
+
+// markUp records a successful call, ending a fallback period
+func (c *RedisCache) markUp() {
+	if c.downUntil.Swap(0) != 0 {
+		c.metrics.SetCacheBackendUp(true)
+		slog.Info("redis cache reachable again")
+	}
+}
+
+// scanBatch is the COUNT hint of each SCAN call made by the admin methods
+const scanBatch = 256
+
+// Entries lists the usable entries matching filter. Entries held by the local
+// fallback while Redis was down are not included
+func (c *RedisCache) Entries(filter CacheFilter) ([]CacheEntryInfo, error) {
+	now := time.Now()
+
+	infos := make([]CacheEntryInfo, 0)
+	err := c.scan(filter, func(redisKey string, key cacheKey) error {
+		entry, ok, err := c.fetch(redisKey, key, now)
+		if err != nil || !ok {
+			return err
+		}
+		infos = append(infos, entry.CacheEntryInfo)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortEntryInfos(infos)
+	return infos, nil
+}
+
+// Entry returns one usable entry by key from Redis
+func (c *RedisCache) Entry(keyStr string) (CacheEntry, bool, error) {
+	key, err := parseCacheKey(keyStr)
+	if err != nil {
+		return CacheEntry{}, false, err
+	}
+	return c.fetch(c.prefix+keyStr, key, time.Now())
+}
+
+// DeleteKey removes one entry by key from both Redis and the local fallback
+func (c *RedisCache) DeleteKey(keyStr string) (bool, error) {
+	// Also validates the key before it reaches Redis
+	localFound, err := c.local.DeleteKey(keyStr)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := resp.Int(c.client.Do(context.Background(), "DEL", c.prefix+keyStr))
+	if err != nil {
+		c.metrics.CacheBackendError("del")
+		return localFound, err
+	}
+	if n > 0 {
+		c.metrics.CacheEviction(evictAdmin)
+	}
+	return localFound || n > 0, nil
+}
+
+// Delete removes every entry matching filter from both Redis and the local fallback.
+// The count is of Redis keys plus local entries, so an entry held by both counts twice
+func (c *RedisCache) Delete(filter CacheFilter) (int, error) {
+	removed, _ := c.local.Delete(filter)
+
+	// Collect first so that deleting cannot disturb the SCAN iteration
+	var keys []string
+	err := c.scan(filter, func(redisKey string, _ cacheKey) error {
+		keys = append(keys, redisKey)
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	for batch := range slices.Chunk(keys, scanBatch) {
+		n, err := resp.Int(c.client.Do(context.Background(), append([]string{"DEL"}, batch...)...))
+		if err != nil {
+			c.metrics.CacheBackendError("del")
+			return removed, err
+		}
+		for range n {
+			c.metrics.CacheEviction(evictAdmin)
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+// Flush removes every entry under the key prefix. Other keys in the database are left alone
+func (c *RedisCache) Flush() (int, error) {
+	return c.Delete(CacheFilter{})
+}
+
+// scan calls fn for every key under the prefix that parses as a cache key and matches
+// filter. SCAN may report a key more than once; fn must tolerate that
+func (c *RedisCache) scan(filter CacheFilter, fn func(redisKey string, key cacheKey) error) error {
+	pattern := globEscape(c.prefix) + "*"
+	cursor := "0"
+
+	for {
+		reply, err := resp.Values(c.client.Do(context.Background(), "SCAN", cursor, "MATCH", pattern, "COUNT", strconv.Itoa(scanBatch)))
+		if err != nil {
+			c.metrics.CacheBackendError("scan")
+			return err
+		}
+		if len(reply) != 2 {
+			return fmt.Errorf("unexpected SCAN reply of %d elements", len(reply))
+		}
+		if cursor, err = resp.String(reply[0], nil); err != nil {
+			return err
+		}
+		keys, err := resp.Values(reply[1], nil)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			redisKey, err := resp.String(k, nil)
+			if err != nil {
+				return err
+			}
+			key, err := parseCacheKey(strings.TrimPrefix(redisKey, c.prefix))
+			if err != nil || !filter.match(key) {
+				continue
+			}
+			if err := fn(redisKey, key); err != nil {
+				return err
+			}
+		}
+
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// fetch reads and decodes one entry, reporting false when it is missing, unreadable or unusable
+func (c *RedisCache) fetch(redisKey string, key cacheKey, now time.Time) (CacheEntry, bool, error) {
+	data, err := resp.String(c.client.Do(context.Background(), "GET", redisKey))
+	if errors.Is(err, resp.ErrNil) {
+		return CacheEntry{}, false, nil
+	}
+	if err != nil {
+		c.metrics.CacheBackendError("get")
+		return CacheEntry{}, false, err
+	}
+
+	var entry cacheEntry
+	if key.provider == "" {
+		var stored redisEntry
+		if err := json.Unmarshal([]byte(data), &stored); err != nil || stored.Version != redisEntryVersion {
+			return CacheEntry{}, false, nil
+		}
+		entry = cacheEntry{
+			hotels:     stored.Hotels,
+			storedAt:   stored.StoredAt,
+			expiresAt:  stored.ExpiresAt,
+			staleUntil: stored.StaleUntil,
+			errorUntil: stored.ErrorUntil,
+		}
+	} else {
+		var stored redisProviderEntry
+		if err := json.Unmarshal([]byte(data), &stored); err != nil || stored.Version != redisEntryVersion {
+			return CacheEntry{}, false, nil
+		}
+		entry = cacheEntry{
+			raw:        stored.Hotels,
+			storedAt:   stored.StoredAt,
+			expiresAt:  stored.ExpiresAt,
+			staleUntil: stored.ExpiresAt,
+			errorUntil: stored.ExpiresAt,
+		}
+	}
+
+	if _, usable := entry.freshness(now); !usable {
+		return CacheEntry{}, false, nil
+	}
+
+	return CacheEntry{
+		CacheEntryInfo: newEntryInfo(key, &entry, int64(len(data)), now),
+		Hotels:         entry.hotels,
+		ProviderHotels: entry.raw,
+	}, true, nil
+}
+
+// globEscape escapes the characters SCAN MATCH treats as wildcards
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/currency"
+	"hostaggr/internal/models"
+	"hostaggr/internal/resp/resptest"
+)
+
+var testRequest = models.SearchRequest{
+	City:     "marrakech",
+	CheckIn:  "2026-11-02",
+	Nights:   2,
+	Adults:   2,
+	Currency: "EUR",
+}
+
+func testHotels() []models.Hotel {
+	price := currency.MustParse("240.00", "EUR")
+	return []models.Hotel{{
+		HotelID:       "H123",
+		Name:          "Hotel Atlas",
+		Price:         price,
+		OriginalPrice: price,
+		OriginalBasis: models.PerStay,
+	}}
+}
+
+func newTestRedisCache(t *testing.T) (*RedisCache, *resptest.Server) {
+	t.Helper()
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	cfg := config.Default()
+	cfg.Cache.Backend = "redis"
+	cfg.Redis.Addr = srv.Addr()
+	cfg.Redis.DialTimeout = 100 * time.Millisecond
+
+	c, err := NewRedisCache(cfg.Cache, cfg.Redis, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Stop)
+	return c, srv
+}
+
+func TestRedisCacheRoundTrip(t *testing.T) {
+	c, srv := newTestRedisCache(t)
+
+	c.Set(testRequest, testHotels())
+	if _, ok := srv.Get(c.key(testRequest)); !ok {
+		t.Fatal("Set did not write to Redis")
+	}
+
+	hotels, freshness, ok := c.Get(testRequest)
+	if !ok || freshness != Fresh {
+		t.Fatalf("Get() = %v, %v, want a fresh hit", freshness, ok)
+	}
+	if len(hotels) != 1 || hotels[0].HotelID != "H123" || hotels[0].Price.String() != "240.00 EUR" {
+		t.Fatalf("Get() hotels = %+v", hotels)
+	}
+}
+
+func TestRedisCacheExpiresAfterLastWindow(t *testing.T) {
+	c, srv := newTestRedisCache(t)
+	c.Set(testRequest, testHotels())
+
+	// Redis keeps the entry for the TTL plus the longer of the stale windows
+	want := c.ttl + max(c.staleWhileReval, c.staleIfError)
+	ttl, ok := srv.TTL(c.key(testRequest))
+	if !ok || ttl > want || ttl < want-time.Second {
+		t.Fatalf("TTL = %v, want about %v", ttl, want)
+	}
+
+	srv.FastForward(want)
+	if _, _, ok := c.Get(testRequest); ok {
+		t.Fatal("Get() hit after Redis expired the entry")
+	}
+}
+
+func TestRedisCacheProviderEntries(t *testing.T) {
+	c, srv := newTestRedisCache(t)
+	hotels := []models.ProviderHotel{{
+		Provider: "Mock1",
+		HotelID:  "H123",
+		Name:     "Hotel Atlas",
+		City:     "marrakech",
+		Price:    currency.MustParse("120.00", "EUR"),
+		Basis:    models.PerNight,
+		Nights:   2,
+	}}
+
+	c.SetProvider("Mock1", testRequest, hotels, 10*time.Second)
+	got, ok := c.GetProvider("Mock1", testRequest)
+	if !ok || len(got) != 1 || got[0].HotelID != "H123" {
+		t.Fatalf("GetProvider() = %+v, %v", got, ok)
+	}
+	if _, ok := c.GetProvider("Mock2", testRequest); ok {
+		t.Fatal("GetProvider() hit for another provider")
+	}
+
+	srv.FastForward(10 * time.Second)
+	if _, ok := c.GetProvider("Mock1", testRequest); ok {
+		t.Fatal("GetProvider() hit after the provider TTL")
+	}
+}
+
+func TestRedisCacheIgnoresOtherVersions(t *testing.T) {
+	c, _ := newTestRedisCache(t)
+	c.Set(testRequest, testHotels())
+
+	// An entry written by another release reads as a miss rather than being misread
+	old := `{"v":0,"hotels":[{"hotel_id":"H123","price":240}]}`
+	if _, err := c.client.Do(context.Background(), "SET", c.key(testRequest), old); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := c.Get(testRequest); ok {
+		t.Fatal("Get() hit on an entry of another version")
+	}
+}
+
+func TestRedisCacheFallsBackWhileRedisIsDown(t *testing.T) {
+	c, srv := newTestRedisCache(t)
+	srv.Close()
+
+	c.Set(testRequest, testHotels())
+	if c.available() {
+		t.Fatal("cache still uses Redis after a failed write")
+	}
+
+	hotels, _, ok := c.Get(testRequest)
+	if !ok || len(hotels) != 1 {
+		t.Fatalf("Get() = %+v, %v, want the hotels from the local fallback", hotels, ok)
+	}
+}
+
+func TestRedisCacheStartsOnFallbackWhenUnreachable(t *testing.T) {
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := srv.Addr()
+	srv.Close()
+
+	cfg := config.Default()
+	cfg.Redis.Addr = addr
+	cfg.Redis.DialTimeout = 100 * time.Millisecond
+	c, err := NewRedisCache(cfg.Cache, cfg.Redis, nil)
+	if err != nil {
+		t.Fatalf("NewRedisCache() = %v, want the local fallback", err)
+	}
+	defer c.Stop()
+
+	if c.available() {
+		t.Fatal("cache tries Redis right after the startup ping failed")
+	}
+	c.Set(testRequest, testHotels())
+	if _, _, ok := c.Get(testRequest); !ok {
+		t.Fatal("Get() missed on the local fallback")
+	}
+}
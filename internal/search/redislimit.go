@@ -0,0 +1,204 @@
+package search
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/resp"
+)
+
+// RedisGCRAScript runs one GCRA decision atomically. KEYS[1] holds the client's
+// theoretical arrival time in microseconds. ARGV is now, the emission interval and
+// the burst tolerance, all in microseconds. It returns {allowed, reset, retry after},
+// the durations in microseconds. The arrival time is stored formatted as an integer:
+// Redis would format a bare Lua number with %.14g, dropping the last digits of a
+// microsecond timestamp
+const RedisGCRAScript = `local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tolerance = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then
+  tat = now
+end
+local next_tat = tat + interval
+local allow_at = next_tat - tolerance
+if now < allow_at then
+  return {0, tat - now, allow_at - now}
+end
+redis.call('SET', KEYS[1], string.format('%d', next_tat), 'PX', math.ceil((next_tat - now) / 1000))
+return {1, next_tat - now, 0}
+`
+
+// redisGCRASHA is the SHA1 EVALSHA refers to RedisGCRAScript by
+var redisGCRASHA = func() string {
+	sum := sha1.Sum([]byte(RedisGCRAScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+// RedisLimiter applies GCRA with its state in a server speaking the Redis protocol,
+// so that replicas share one allowance per client. Each decision is a single script
+// call. The time comes from the replica, so replica clocks must be synchronized; skew
+// shifts a client's allowance by the skew at most. While Redis is unreachable,
+// decisions fall back to a local limiter with the same tiers
+type RedisLimiter struct {
+	client   *resp.Client
+	prefix   string
+	resolver *clientResolver
+	now      func() time.Time
+	metrics  *obs.Metrics
+
+	// Fallback while Redis is down
+	local         *RateLimiter
+	retryInterval time.Duration
+	downUntil     atomic.Int64 // unix nanoseconds; Redis is skipped until then
+}
+
+// NewRedisLimiter creates a Redis-backed limiter with a local fallback built from the
+// same settings. An unreachable server is not an error: the limiter starts on the
+// fallback and retries later
+func NewRedisLimiter(cfg config.RateLimitConfig, redisCfg config.RedisConfig, metrics *obs.Metrics) (*RedisLimiter, error) {
+	return NewRedisLimiterWithClock(cfg, redisCfg, metrics, time.Now)
+}
+
+// NewRedisLimiterWithClock creates a Redis-backed limiter that reads the time from now
+func NewRedisLimiterWithClock(cfg config.RateLimitConfig, redisCfg config.RedisConfig, metrics *obs.Metrics, now func() time.Time) (*RedisLimiter, error) {
+	if cfg.Algorithm != AlgorithmGCRA {
+		return nil, fmt.Errorf("redis rate limiting supports the %s algorithm only, got %q", AlgorithmGCRA, cfg.Algorithm)
+	}
+
+	local, err := NewRateLimiterWithClock(cfg, metrics, now)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &RedisLimiter{
+		client: resp.NewClient(resp.Options{
+			Addr:        redisCfg.Addr,
+			Password:    redisCfg.Password,
+			DB:          redisCfg.DB,
+			DialTimeout: redisCfg.DialTimeout,
+			IOTimeout:   redisCfg.IOTimeout,
+			PoolSize:    redisCfg.PoolSize,
+		}),
+		prefix:        cfg.KeyPrefix,
+		resolver:      local.resolver,
+		now:           now,
+		metrics:       metrics,
+		local:         local,
+		retryInterval: redisCfg.RetryInterval,
+	}
+
+	metrics.SetRateLimitBackendUp(true)
+	if err := l.client.Ping(context.Background()); err != nil {
+		l.markDown("ping", err)
+	}
+
+	return l, nil
+}
+
+// Allow checks if a request from the given client should be allowed, against Redis
+// or, while it is down, against the local fallback
+func (l *RedisLimiter) Allow(c Client) (Decision, error) {
+	t, key, err := l.resolver.resolve(c)
+	if err != nil {
+		return Decision{}, err
+	}
+	if !l.available() {
+		return l.local.Allow(c)
+	}
+
+	interval := t.interval()
+	reply, err := l.eval(l.prefix+key,
+		strconv.FormatInt(l.now().UnixMicro(), 10),
+		strconv.FormatInt(interval.Microseconds(), 10),
+		strconv.FormatInt((time.Duration(t.burst)*interval).Microseconds(), 10),
+	)
+	if err != nil {
+		l.markDown("eval", err)
+		return l.local.Allow(c)
+	}
+	l.markUp()
+
+	d := Decision{
+		Allowed:    reply[0] == 1,
+		Tier:       t.name,
+		Limit:      t.burst,
+		Reset:      time.Duration(reply[1]) * time.Microsecond,
+		RetryAfter: time.Duration(reply[2]) * time.Microsecond,
+	}
+	// Each interval the arrival time is ahead of now is one request of the burst spent
+	d.Remaining = max(t.burst-int((d.Reset+interval-1)/interval), 0)
+
+	if !d.Allowed {
+		l.metrics.RateLimitRejected(t.name)
+	}
+	return d, nil
+}
+
+// eval runs the GCRA script by SHA, sending its source when the server does not know it yet
+func (l *RedisLimiter) eval(key string, args ...string) ([3]int64, error) {
+	ctx := context.Background()
+	cmd := append([]string{"EVALSHA", redisGCRASHA, "1", key}, args...)
+
+	v, err := l.client.Do(ctx, cmd...)
+	var e resp.Error
+	if errors.As(err, &e) && strings.HasPrefix(string(e), "NOSCRIPT") {
+		cmd[0], cmd[1] = "EVAL", RedisGCRAScript
+		v, err = l.client.Do(ctx, cmd...)
+	}
+
+	values, err := resp.Values(v, err)
+	if err != nil {
+		return [3]int64{}, err
+	}
+	if len(values) != 3 {
+		return [3]int64{}, fmt.Errorf("unexpected GCRA reply of %d elements", len(values))
+	}
+
+	var reply [3]int64
+	for i := range reply {
+		if reply[i], err = resp.Int(values[i], nil); err != nil {
+			return [3]int64{}, err
+		}
+	}
+	return reply, nil
+}
+
+// Stop stops the local fallback and closes the Redis connections
+func (l *RedisLimiter) Stop() {
+	l.local.Stop()
+	l.client.Close()
+}
+
+// available reports whether Redis should be tried, i.e. the retry interval since the last failure has passed
+func (l *RedisLimiter) available() bool {
+	return time.Now().UnixNano() >= l.downUntil.Load()
+}
+
+// markDown switches to the local fallback for the retry interval
+func (l *RedisLimiter) markDown(op string, err error) {
+	l.metrics.RateLimitBackendError(op)
+	l.metrics.SetRateLimitBackendUp(false)
+
+	if l.downUntil.Swap(time.Now().Add(l.retryInterval).UnixNano()) == 0 {
+		slog.Warn("redis rate limiter unreachable, limiting locally", "op", op, "error", err, "retry_in", l.retryInterval)
+	}
+}
+
+// markUp records a successful call, ending a fallback period
+func (l *RedisLimiter) markUp() {
+	if l.downUntil.Swap(0) != 0 {
+		l.metrics.SetRateLimitBackendUp(true)
+		slog.Info("redis rate limiter reachable again")
+	}
+}
@@ -0,0 +1,158 @@
+package search_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/resp"
+	"hostaggr/internal/resp/resptest"
+	"hostaggr/internal/search"
+	"hostaggr/internal/search/limitertest"
+)
+
+func newServer(t *testing.T) *resptest.Server {
+	t.Helper()
+	srv, err := resptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func TestRedisLimiter(t *testing.T) {
+	limitertest.Run(t, search.AlgorithmGCRA, limitertest.RedisFactory(newServer(t)))
+}
+
+// TestRedisLimiterAgainstRealRedis runs the suite against the Redis at
+// HOSTAGGR_TEST_REDIS, so the script runs under Lua rather than its Go twin. The
+// script cache is flushed first, so the first EVALSHA fails with NOSCRIPT and the
+// limiter has to load the script itself
+func TestRedisLimiterAgainstRealRedis(t *testing.T) {
+	addr := os.Getenv("HOSTAGGR_TEST_REDIS")
+	if addr == "" {
+		t.Skip("HOSTAGGR_TEST_REDIS is not set")
+	}
+
+	client := resp.NewClient(resp.Options{Addr: addr, DialTimeout: time.Second, IOTimeout: time.Second})
+	defer client.Close()
+	ctx := context.Background()
+	if _, err := client.Do(ctx, "SCRIPT", "FLUSH"); err != nil {
+		t.Fatalf("SCRIPT FLUSH on %s: %v", addr, err)
+	}
+
+	metrics := obs.NewMetrics()
+	limitertest.Run(t, search.AlgorithmGCRA, limitertest.RedisAddrFactory(addr, metrics))
+
+	// Any backend error means some decisions came from the local fallback instead
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for line := range strings.Lines(buf.String()) {
+		if strings.HasPrefix(line, "hostaggr_rate_limit_backend_errors_total{") {
+			t.Errorf("limiter fell back to local state: %s", strings.TrimSpace(line))
+		}
+	}
+
+	sum := sha1.Sum([]byte(search.RedisGCRAScript))
+	loaded, err := resp.Values(client.Do(ctx, "SCRIPT", "EXISTS", hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := resp.Int(loaded[0], nil); err != nil || n != 1 {
+		t.Fatalf("script not loaded after NOSCRIPT: SCRIPT EXISTS = %v, %v", loaded[0], err)
+	}
+}
+
+func TestRedisLimiterStoresExactArrivalTime(t *testing.T) {
+	srv := newServer(t)
+	clock := limitertest.NewClock()
+	clock.Advance(123456789 * time.Microsecond) // digits a %.14g float would drop
+	cfg := limitertest.Config(search.AlgorithmGCRA)
+
+	l, err := limitertest.RedisFactory(srv)(cfg, clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Stop()
+
+	if d, err := l.Allow(search.Client{IP: "192.0.2.1"}); err != nil || !d.Allowed {
+		t.Fatalf("Allow() = %+v, %v", d, err)
+	}
+
+	keys := srv.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("keys = %v, want one", keys)
+	}
+	v, _ := srv.Get(keys[0])
+	tier := cfg.Tiers[cfg.DefaultTier]
+	want := clock.Now().Add(tier.Window / time.Duration(tier.Requests)).UnixMicro()
+	if v != strconv.FormatInt(want, 10) {
+		t.Fatalf("stored arrival time %q, want %d", v, want)
+	}
+}
+
+func TestRedisLimiterFallsBackWhileRedisIsDown(t *testing.T) {
+	srv := newServer(t)
+	clock := limitertest.NewClock()
+	l, err := limitertest.RedisFactory(srv)(limitertest.Config(search.AlgorithmGCRA), clock.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Stop()
+
+	client := search.Client{IP: "192.0.2.1"}
+	if _, err := l.Allow(client); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	// The local limiter takes over with the same tier, so the client is still limited
+	var allowed int
+	for range 2 * limitertest.Config(search.AlgorithmGCRA).Tiers["free"].Burst {
+		d, err := l.Allow(client)
+		if err != nil {
+			t.Fatalf("Allow() while Redis is down = %v", err)
+		}
+		if d.Tier != "free" {
+			t.Fatalf("tier %q, want free", d.Tier)
+		}
+		if d.Allowed {
+			allowed++
+		}
+	}
+	if want := limitertest.Config(search.AlgorithmGCRA).Tiers["free"].Burst; allowed != want {
+		t.Fatalf("allowed %d requests on the fallback, want %d", allowed, want)
+	}
+}
+
+func TestRedisLimiterStartsOnFallbackWhenUnreachable(t *testing.T) {
+	srv := newServer(t)
+	addr := srv.Addr()
+	srv.Close()
+
+	l, err := search.NewRedisLimiter(limitertest.Config(search.AlgorithmGCRA), config.RedisConfig{
+		Addr:          addr,
+		DialTimeout:   100 * time.Millisecond,
+		IOTimeout:     100 * time.Millisecond,
+		RetryInterval: time.Minute,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewRedisLimiter() = %v, want the local fallback", err)
+	}
+	defer l.Stop()
+
+	if d, err := l.Allow(search.Client{IP: "192.0.2.1"}); err != nil || !d.Allowed {
+		t.Fatalf("Allow() = %+v, %v", d, err)
+	}
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/providers"
+)
+
+// retryBudgetCapacity caps the retry tokens a provider can bank while healthy
+const retryBudgetCapacity = 10
+
+// RetryPolicy retries retryable provider errors with exponential backoff and full jitter
+// Each policy owns a retry budget, so it should be used for a single provider
+type RetryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	budget      *retryBudget
+
+	// Jitter and waiting, replaced in tests
+	jitter func(n int64) int64                              // uniform in [0, n)
+	sleep  func(ctx context.Context, d time.Duration) error // ctx.Err() if cancelled first
+
+	onRetry           func()
+	onBudgetExhausted func()
+}
+
+// NewRetryPolicy creates a retry policy with its own retry budget
+func NewRetryPolicy(cfg config.RetryConfig) *RetryPolicy {
+	return &RetryPolicy{
+		maxAttempts: cfg.MaxAttempts,
+		baseDelay:   cfg.BaseDelay,
+		maxDelay:    cfg.MaxDelay,
+		budget:      newRetryBudget(cfg.BudgetRatio, cfg.BudgetMinPerSecond),
+		jitter:      rand.Int63n,
+		sleep:       sleep,
+	}
+}
+
+// Do calls fn until it succeeds or returns an error that is not retryable, or until
+// attempts, retry budget or the context deadline run out. It returns the number of
+// retries made and the last error
+func (p *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) (int, error) {
+	p.budget.deposit()
+
+	retries := 0
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.maxAttempts || ctx.Err() != nil || !providers.IsRetryable(err) {
+			return retries, err
+		}
+
+		delay := p.backoff(attempt)
+		if hint := providers.RetryAfter(err); hint > delay {
+			delay = hint
+		}
+
+		// Don't sleep past the deadline: the retry could never complete in time
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return retries, err
+		}
+
+		if !p.budget.withdraw() {
+			if p.onBudgetExhausted != nil {
+				p.onBudgetExhausted()
+			}
+			return retries, err
+		}
+
+		if p.sleep(ctx, delay) != nil {
+			return retries, err
+		}
+
+		retries++
+		if p.onRetry != nil {
+			p.onRetry()
+		}
+	}
+}
+
+// backoff returns a random delay in [0, min(maxDelay, baseDelay*2^(attempt-1))]
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.maxDelay
+	if shift := attempt - 1; shift < 32 {
+		if d := p.baseDelay << shift; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	return time.Duration(p.jitter(int64(ceiling) + 1))
+}
+
+// sleep waits for d or until ctx is done, whichever is first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryBudget limits retries to a fraction of first attempts plus a small per-second floor
+type retryBudget struct {
+	mu           sync.Mutex
+	ratio        float64
+	minPerSecond float64
+	tokens       float64
+	last         time.Time
+	now          func() time.Time
+}
+
+func newRetryBudget(ratio, minPerSecond float64) *retryBudget {
+	return &retryBudget{
+		ratio:        ratio,
+		minPerSecond: minPerSecond,
+		tokens:       retryBudgetCapacity,
+		last:         time.Now(),
+		now:          time.Now,
+	}
+}
+
+// deposit earns retry credit for one first attempt
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens = min(b.tokens+b.ratio, retryBudgetCapacity)
+}
+
+// withdraw spends one retry, reporting false when the budget is exhausted
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the per-second floor for the time elapsed. Caller must hold b.mu
+func (b *retryBudget) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed*b.minPerSecond, retryBudgetCapacity)
+	}
+}
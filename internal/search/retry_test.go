@@ -0,0 +1,200 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/providers"
+)
+
+// newTestRetryPolicy returns a policy whose jitter always picks the ceiling and
+// whose sleeps are recorded instead of waited
+func newTestRetryPolicy(cfg config.RetryConfig) (*RetryPolicy, *[]time.Duration) {
+	p := NewRetryPolicy(cfg)
+	p.jitter = func(n int64) int64 { return n - 1 }
+	var slept []time.Duration
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return p, &slept
+}
+
+func testRetryConfig() config.RetryConfig {
+	return config.RetryConfig{
+		Enabled:            true,
+		MaxAttempts:        3,
+		BaseDelay:          100 * time.Millisecond,
+		MaxDelay:           time.Second,
+		BudgetRatio:        0.1,
+		BudgetMinPerSecond: 0,
+	}
+}
+
+func transient() error {
+	return providers.NewError("Mock1", providers.KindTransient, errors.New("try again"))
+}
+
+func TestBackoffFullJitterBounds(t *testing.T) {
+	p, _ := newTestRetryPolicy(testRetryConfig())
+
+	// The ceiling doubles from the base delay and is capped at the maximum
+	for attempt, want := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		3:  400 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		40: time.Second,
+	} {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) at the top of the jitter = %s, want %s", attempt, got, want)
+		}
+	}
+
+	// The bottom of the jitter is no wait at all
+	p.jitter = func(int64) int64 { return 0 }
+	if got := p.backoff(3); got != 0 {
+		t.Errorf("backoff(3) at the bottom of the jitter = %s, want 0", got)
+	}
+}
+
+func TestRetryDoRetriesUntilMaxAttempts(t *testing.T) {
+	p, slept := newTestRetryPolicy(testRetryConfig())
+	var retried int
+	p.onRetry = func() { retried++ }
+
+	calls := 0
+	retries, err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		return transient()
+	})
+	if calls != 3 || retries != 2 || retried != 2 || !providers.IsRetryable(err) {
+		t.Fatalf("calls %d retries %d onRetry %d err %v, want 3 calls and 2 retries", calls, retries, retried, err)
+	}
+	if len(*slept) != 2 || (*slept)[0] != 100*time.Millisecond || (*slept)[1] != 200*time.Millisecond {
+		t.Fatalf("slept %v, want [100ms 200ms]", *slept)
+	}
+}
+
+func TestRetryDoStopsOnSuccess(t *testing.T) {
+	p, _ := newTestRetryPolicy(testRetryConfig())
+
+	calls := 0
+	retries, err := p.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls == 1 {
+			return transient()
+		}
+		return nil
+	})
+	if err != nil || calls != 2 || retries != 1 {
+		t.Fatalf("calls %d retries %d err %v, want success on the second call", calls, retries, err)
+	}
+}
+
+func TestRetryDoHonoursRetryAfter(t *testing.T) {
+	p, slept := newTestRetryPolicy(testRetryConfig())
+
+	calls := 0
+	p.Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls > 1 {
+			return nil
+		}
+		err := providers.NewError("Mock1", providers.KindRateLimited, errors.New("slow down"))
+		err.RetryAfter = 3 * time.Second
+		return err
+	})
+	if len(*slept) != 1 || (*slept)[0] != 3*time.Second {
+		t.Fatalf("slept %v, want the provider's 3s hint over the backoff", *slept)
+	}
+}
+
+func TestRetryDoSkipsNonRetryableErrors(t *testing.T) {
+	for name, failure := range map[string]error{
+		"invalid request": providers.NewError("Mock1", providers.KindInvalidRequest, errors.New("bad city")),
+		"permanent":       providers.NewError("Mock1", providers.KindPermanent, errors.New("gone")),
+		"unclassified":    errors.New("boom"),
+	} {
+		p, _ := newTestRetryPolicy(testRetryConfig())
+		calls := 0
+		retries, err := p.Do(context.Background(), func(context.Context) error {
+			calls++
+			return failure
+		})
+		if calls != 1 || retries != 0 || err != failure {
+			t.Errorf("%s: calls %d retries %d err %v, want a single call", name, calls, retries, err)
+		}
+	}
+}
+
+func TestRetryDoStopsWhenCancelled(t *testing.T) {
+	p, _ := newTestRetryPolicy(testRetryConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	retries, _ := p.Do(ctx, func(context.Context) error {
+		calls++
+		return transient()
+	})
+	if calls != 1 || retries != 0 {
+		t.Fatalf("calls %d retries %d, want no retry after cancellation", calls, retries)
+	}
+}
+
+func TestRetryDoStopsWhenBudgetIsExhausted(t *testing.T) {
+	cfg := testRetryConfig()
+	cfg.MaxAttempts = 100
+	p, _ := newTestRetryPolicy(cfg)
+	p.budget.now = func() time.Time { return p.budget.last } // no per-second refill
+	exhausted := 0
+	p.onBudgetExhausted = func() { exhausted++ }
+
+	// The full budget of 10 plus the 0.1 earned by this first attempt allows 10 retries
+	retries, _ := p.Do(context.Background(), func(context.Context) error { return transient() })
+	if retries != retryBudgetCapacity || exhausted != 1 {
+		t.Fatalf("retries %d exhausted %d, want %d retries then exhaustion", retries, exhausted, retryBudgetCapacity)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Date(2026, 11, 2, 12, 0, 0, 0, time.UTC)
+	b := newRetryBudget(0.5, 1)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	// It starts full, at capacity 10
+	for i := range retryBudgetCapacity {
+		if !b.withdraw() {
+			t.Fatalf("withdraw %d refused from a full budget", i+1)
+		}
+	}
+	if b.withdraw() {
+		t.Fatal("withdraw allowed from an empty budget")
+	}
+
+	// Each first attempt earns half a retry
+	b.deposit()
+	if b.withdraw() {
+		t.Fatal("withdraw allowed with half a token")
+	}
+	b.deposit()
+	if !b.withdraw() {
+		t.Fatal("withdraw refused after two deposits of 0.5")
+	}
+
+	// The per-second floor refills, up to the capacity
+	now = now.Add(time.Hour)
+	b.deposit()
+	if b.tokens != retryBudgetCapacity {
+		t.Fatalf("tokens %g after an hour, want the capacity %d", b.tokens, retryBudgetCapacity)
+	}
+}
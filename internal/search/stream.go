@@ -0,0 +1,96 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"hostaggr/internal/models"
+)
+
+// StreamEvent is one incremental update of a streaming search
+type StreamEvent struct {
+	Provider string
+	Hotels   []models.Hotel // running deduplicated, price-sorted set, nil when Err is set
+	Err      error
+}
+
+// SearchStream performs an aggregated search and calls emit each time a provider
+// answers, so results can be rendered before the slowest provider finishes.
+// A cache hit, fresh or stale, produces a single event from the "cache" provider, as
+// does a stale fallback when every provider fails. Cancelling ctx, or
+// emit returning an error, cancels the outstanding provider calls unless another
+// search shares them. The returned response carries the final hotels and stats.
+// An invalid request returns an error wrapping ErrInvalidSearch before any event
+func (a *Aggregator) SearchStream(ctx context.Context, req models.SearchRequest, emit func(StreamEvent) error) (models.SearchResponse, error) {
+	startTime := time.Now()
+
+	city := displayCity(req.City)
+	req, err := a.Normalize(req)
+	if err != nil {
+		return models.SearchResponse{}, err
+	}
+	a.recordSearch(req)
+
+	// Check cache first
+	fallback, hit := a.lookup(req)
+	if hit != "" {
+		if err := emit(StreamEvent{Provider: "cache", Hotels: shapeHotels(fallback, req)}); err != nil {
+			return models.SearchResponse{}, err
+		}
+		return a.cachedResponse(req, city, fallback, hit, startTime), nil
+	}
+
+	// Join the provider fan-out of an identical in-flight search, or start one
+	f, shared := a.joinFlight(req)
+
+	result := newFanOutResult(a.upstreams)
+	_, err = a.follow(ctx, f, nil, func(o providerOutcome) error {
+		result.add(o)
+
+		event := StreamEvent{Provider: o.provider, Err: o.err}
+		if o.err == nil {
+			event.Hotels = shapeHotels(a.buildHotels(result.hotels, req), req)
+		}
+		return emit(event)
+	})
+
+	// A disconnected client abandons the fan-out, cancelling it unless others share it
+	a.leaveFlight(f, false)
+	if err != nil {
+		return models.SearchResponse{}, err
+	}
+
+	hotels := a.buildHotels(result.hotels, req)
+	cacheState := cacheMiss
+
+	// Every provider failed: a stale result beats an empty one
+	if fallback != nil && result.succeeded == 0 {
+		hotels = fallback
+		cacheState = cacheStaleError
+		a.metrics.CacheStaleServed("error")
+		if err := emit(StreamEvent{Provider: "cache", Hotels: shapeHotels(fallback, req)}); err != nil {
+			return models.SearchResponse{}, err
+		}
+	}
+
+	response := models.SearchResponse{
+		Search: searchInfo(req, city),
+		Stats: models.Stats{
+			ProvidersTotal:     len(a.providers),
+			ProvidersSucceeded: result.succeeded,
+			ProvidersFailed:    result.failed,
+			ProvidersSkipped:   result.skipped,
+			ProvidersCached:    result.cached,
+			Retries:            result.retries,
+			Hedges:             result.hedges,
+			Breakers:           a.breakerStates(),
+			Cache:              cacheState,
+			Coalesced:          shared,
+			DurationMs:         time.Since(startTime).Milliseconds(),
+		},
+		Hotels: shapeHotels(hotels, req),
+	}
+
+	a.metrics.ObserveHotelsReturned(len(hotels))
+	return response, nil
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"container/list"
+	"hash/maphash"
+)
+
+// tinyLFUPolicy implements W-TinyLFU: newcomers enter a small LRU window, and an entry
+// leaving the window is only admitted to the main LRU if a frequency sketch says it is
+// more popular than the main region's own eviction candidate. This keeps one-off
+// searches from flushing entries that are requested over and over
+type tinyLFUPolicy struct {
+	sketch    *countMinSketch
+	window    *list.List // front is most recent
+	main      *list.List // front is most recent
+	elems     map[cacheKey]*list.Element
+	inWindow  map[cacheKey]bool
+	windowCap int
+	mainCap   int
+}
+
+func newTinyLFUPolicy(capacity int) *tinyLFUPolicy {
+	windowCap := max(1, capacity/100)
+	return &tinyLFUPolicy{
+		sketch:    newCountMinSketch(capacity),
+		window:    list.New(),
+		main:      list.New(),
+		elems:     make(map[cacheKey]*list.Element),
+		inWindow:  make(map[cacheKey]bool),
+		windowCap: windowCap,
+		mainCap:   max(0, capacity-windowCap),
+	}
+}
+
+func (p *tinyLFUPolicy) onInsert(key cacheKey) {
+	p.sketch.increment(key)
+	p.elems[key] = p.window.PushFront(key)
+	p.inWindow[key] = true
+
+	// Until the main region is full, entries leaving the window move in unopposed
+	for p.window.Len() > p.windowCap && p.main.Len() < p.mainCap {
+		p.promote(p.window.Back())
+	}
+}
+
+func (p *tinyLFUPolicy) onAccess(key cacheKey) {
+	p.sketch.increment(key)
+	if e, ok := p.elems[key]; ok {
+		p.segment(key).MoveToFront(e)
+	}
+}
+
+func (p *tinyLFUPolicy) onRemove(key cacheKey) {
+	if e, ok := p.elems[key]; ok {
+		p.segment(key).Remove(e)
+		delete(p.elems, key)
+		delete(p.inWindow, key)
+	}
+}
+
+func (p *tinyLFUPolicy) victim() (cacheKey, bool) {
+	// While the window holds more than its share, its oldest entry duels the main
+	// region's oldest entry; the winner stays and the loser is evicted
+	if p.window.Len() > p.windowCap || p.main.Len() == 0 {
+		candidate := p.window.Back()
+		if candidate == nil {
+			return cacheKey{}, false
+		}
+		incumbent := p.main.Back()
+		if incumbent == nil {
+			return candidate.Value.(cacheKey), true
+		}
+
+		ck, ik := candidate.Value.(cacheKey), incumbent.Value.(cacheKey)
+		if p.sketch.estimate(ck) <= p.sketch.estimate(ik) {
+			return ck, true
+		}
+
+		// Promote the candidate; the incumbent is the victim
+		p.promote(candidate)
+		return ik, true
+	}
+
+	return p.main.Back().Value.(cacheKey), true
+}
+
+// promote moves a window entry to the front of the main region
+func (p *tinyLFUPolicy) promote(e *list.Element) {
+	key := e.Value.(cacheKey)
+	p.window.Remove(e)
+	p.elems[key] = p.main.PushFront(key)
+	delete(p.inWindow, key)
+}
+
+func (p *tinyLFUPolicy) segment(key cacheKey) *list.List {
+	if p.inWindow[key] {
+		return p.window
+	}
+	return p.main
+}
+
+// countMinSketch estimates access frequencies in fixed memory. Counters are halved
+// periodically so that old popularity fades
+type countMinSketch struct {
+	rows       [4][]uint8
+	seeds      [4]maphash.Seed
+	mask       uint64
+	additions  int
+	resetAfter int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 64
+	for width < capacity {
+		width <<= 1
+	}
+
+	s := &countMinSketch{
+		mask:       uint64(width - 1),
+		resetAfter: 10 * max(capacity, 1),
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+		s.seeds[i] = maphash.MakeSeed()
+	}
+	return s
+}
+
+func (s *countMinSketch) increment(key cacheKey) {
+	for i := range s.rows {
+		idx := maphash.Comparable(s.seeds[i], key) & s.mask
+		if s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAfter {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(key cacheKey) uint8 {
+	est := uint8(255)
+	for i := range s.rows {
+		idx := maphash.Comparable(s.seeds[i], key) & s.mask
+		est = min(est, s.rows[i][idx])
+	}
+	return est
+}
+
+// reset halves every counter
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
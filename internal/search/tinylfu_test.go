@@ -0,0 +1,126 @@
+package search
+
+import "testing"
+
+func TestTinyLFUWindowSizing(t *testing.T) {
+	for capacity, want := range map[int]struct{ window, main int }{
+		10000: {100, 9900},
+		100:   {1, 99},
+		50:    {1, 49},
+		1:     {1, 0},
+	} {
+		p := newTinyLFUPolicy(capacity)
+		if p.windowCap != want.window || p.mainCap != want.main {
+			t.Errorf("capacity %d: window %d main %d, want %d and %d", capacity, p.windowCap, p.mainCap, want.window, want.main)
+		}
+	}
+}
+
+// fillTinyLFU inserts hot, makes it popular, then fills the policy to capacity with
+// fillers 1 to 99. hot is then the oldest entry of the main region and filler 99
+// the only one in the window
+func fillTinyLFU() *tinyLFUPolicy {
+	p := newTinyLFUPolicy(100)
+	hot := testKey(0)
+	p.onInsert(hot)
+	for range 5 {
+		p.onAccess(hot)
+	}
+	for i := 1; i < 100; i++ {
+		p.onInsert(testKey(i))
+	}
+	return p
+}
+
+func TestTinyLFUFillsMainRegionFromWindow(t *testing.T) {
+	p := fillTinyLFU()
+	if p.window.Len() != 1 || p.main.Len() != 99 {
+		t.Fatalf("window %d main %d, want 1 and 99", p.window.Len(), p.main.Len())
+	}
+	// Under budget the window's oldest entry is not a victim
+	if got, _ := p.victim(); got != testKey(0) {
+		t.Fatalf("victim %s, want the main region's oldest", got.city)
+	}
+}
+
+func TestTinyLFURejectsColdCandidate(t *testing.T) {
+	p := fillTinyLFU()
+
+	// Over capacity: filler 99 leaves the window and loses its duel with hot
+	p.onInsert(testKey(100))
+	if got := evict(t, p); got != testKey(99) {
+		t.Fatalf("evicted %s, want the cold candidate", got.city)
+	}
+	if p.inWindow[testKey(0)] || p.elems[testKey(0)] == nil {
+		t.Fatal("hot entry left the main region")
+	}
+}
+
+func TestTinyLFUAdmitsHotterCandidate(t *testing.T) {
+	p := fillTinyLFU()
+
+	// A newcomer requested more often than hot wins its duel once it leaves the window
+	popular := testKey(100)
+	p.onInsert(popular)
+	evict(t, p) // filler 99
+	for range 9 {
+		p.onAccess(popular)
+	}
+	p.onInsert(testKey(101))
+
+	if got := evict(t, p); got != testKey(0) {
+		t.Fatalf("evicted %s, want the colder incumbent", got.city)
+	}
+	if p.inWindow[popular] || p.main.Front().Value.(cacheKey) != popular {
+		t.Fatal("the winning candidate was not promoted to the main region")
+	}
+}
+
+func TestCountMinSketch(t *testing.T) {
+	if got := newCountMinSketch(100).mask; got != 127 {
+		t.Fatalf("mask %d for capacity 100, want 127", got)
+	}
+	if got := newCountMinSketch(10).mask; got != 63 {
+		t.Fatalf("mask %d for capacity 10, want the minimum width of 64", got)
+	}
+
+	s := newCountMinSketch(1000)
+	key, other := testKey(1), testKey(2)
+	for range 3 {
+		s.increment(key)
+	}
+	if got := s.estimate(key); got != 3 {
+		t.Fatalf("estimate %d after 3 increments, want 3", got)
+	}
+	if got := s.estimate(other); got != 0 {
+		t.Fatalf("estimate %d for an unseen key, want 0", got)
+	}
+
+	// Counters saturate at 15
+	for range 20 {
+		s.increment(key)
+	}
+	if got := s.estimate(key); got != 15 {
+		t.Fatalf("estimate %d after 23 increments, want the ceiling 15", got)
+	}
+}
+
+func TestCountMinSketchHalvesPeriodically(t *testing.T) {
+	// A capacity of 1 resets after 10 additions
+	s := newCountMinSketch(1)
+	key := testKey(1)
+	for range 9 {
+		s.increment(key)
+	}
+	if got := s.estimate(key); got != 9 {
+		t.Fatalf("estimate %d before the reset, want 9", got)
+	}
+
+	s.increment(key)
+	if got := s.estimate(key); got != 5 {
+		t.Fatalf("estimate %d after the reset, want 10 halved", got)
+	}
+	if s.additions != 5 {
+		t.Fatalf("additions %d after the reset, want 5", s.additions)
+	}
+}
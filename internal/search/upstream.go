@@ -0,0 +1,271 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
+	"hostaggr/internal/providers"
+)
+
+// upstream bundles a provider with its resilience state
+type upstream struct {
+	name     string
+	provider providers.Provider
+	breaker  *CircuitBreaker // nil when disabled
+	retry    *RetryPolicy    // nil when disabled
+	metrics  *obs.Metrics
+
+	// Set when the provider declares how long its results stay valid
+	freshness providers.FreshnessHinter
+
+	// What the provider's prices cover
+	pricing providers.Pricing
+
+	// Hedging, nil when disabled
+	latency       *latencyTracker
+	hedges        *hedgeLimiter // shared by all upstreams
+	hedgeQuantile float64
+	hedgeMinDelay time.Duration
+}
+
+// callStats counts the extra work done for one provider during a search
+type callStats struct {
+	retries int
+	hedges  int
+}
+
+func newUpstream(p providers.Provider, cfg config.AggregatorConfig, hedges *hedgeLimiter, metrics *obs.Metrics) *upstream {
+	u := &upstream{
+		name:     p.Name(),
+		provider: p,
+		pricing:  p.Pricing(),
+		metrics:  metrics,
+	}
+
+	if h, ok := p.(providers.FreshnessHinter); ok {
+		u.freshness = h
+	}
+
+	if cfg.Breaker.Enabled {
+		u.breaker = NewCircuitBreaker(cfg.Breaker)
+		u.breaker.onStateChange = func(_, to BreakerState) {
+			metrics.SetBreakerState(u.name, int(to))
+		}
+		metrics.SetBreakerState(u.name, int(BreakerClosed))
+	}
+
+	if cfg.Retry.Enabled {
+		u.retry = NewRetryPolicy(cfg.Retry)
+		u.retry.onRetry = func() {
+			metrics.ProviderRetry(u.name)
+		}
+		u.retry.onBudgetExhausted = func() {
+			metrics.RetryBudgetExhausted(u.name)
+		}
+	}
+
+	if cfg.Hedge.Enabled {
+		u.latency = newLatencyTracker(cfg.Hedge.WindowSize, cfg.Hedge.MinSamples)
+		u.hedges = hedges
+		u.hedgeQuantile = cfg.Hedge.Quantile
+		u.hedgeMinDelay = cfg.Hedge.MinDelay
+	}
+
+	return u
+}
+
+// cacheTTL returns how long the provider's results for req may be reused: zero for the
+// cache default, negative for not at all
+func (u *upstream) cacheTTL(req models.SearchRequest) time.Duration {
+	if u.freshness == nil {
+		return 0
+	}
+	return u.freshness.FreshFor(req)
+}
+
+// search calls the provider, retrying retryable errors when a retry policy is set
+// The caller must already have been admitted by the breaker for the first attempt, with ticket
+func (u *upstream) search(ctx context.Context, req models.SearchRequest, ticket BreakerTicket) ([]models.ProviderHotel, callStats, error) {
+	var stats callStats
+
+	if u.retry == nil {
+		hotels, hedged, err := u.hedgedAttempt(ctx, req, ticket)
+		if hedged {
+			stats.hedges++
+		}
+		return hotels, stats, err
+	}
+
+	var hotels []models.ProviderHotel
+	first := true
+
+	retries, err := u.retry.Do(ctx, func(ctx context.Context) error {
+		// Every retry is a new call and needs its own breaker admission
+		if !first {
+			var ok bool
+			if ticket, ok = u.admit(); !ok {
+				return ErrBreakerOpen
+			}
+		}
+		first = false
+
+		var err error
+		var hedged bool
+		hotels, hedged, err = u.hedgedAttempt(ctx, req, ticket)
+		if hedged {
+			stats.hedges++
+		}
+		return err
+	})
+	stats.retries = retries
+
+	return hotels, stats, err
+}
+
+// admit asks the breaker to let one more call through, returning the ticket its
+// outcome is recorded with
+func (u *upstream) admit() (BreakerTicket, bool) {
+	if u.breaker == nil {
+		return BreakerTicket{}, true
+	}
+	ticket, err := u.breaker.Allow()
+	if err != nil {
+		u.metrics.BreakerRejected(u.name)
+		return BreakerTicket{}, false
+	}
+	return ticket, true
+}
+
+type attemptResult struct {
+	hotels []models.ProviderHotel
+	err    error
+	hedge  bool
+}
+
+// hedgedAttempt makes one logical provider call. If hedging is enabled and the call is
+// still running after the provider's observed latency quantile, an identical call is
+// fired and whichever succeeds first wins; the other is cancelled. ticket is the
+// breaker admission of the original call; a hedge is admitted separately
+func (u *upstream) hedgedAttempt(ctx context.Context, req models.SearchRequest, ticket BreakerTicket) ([]models.ProviderHotel, bool, error) {
+	if u.latency == nil {
+		hotels, err := u.attempt(ctx, req, ticket)
+		return hotels, false, err
+	}
+
+	delay, ok := u.latency.quantile(u.hedgeQuantile)
+	if !ok {
+		hotels, err := u.attempt(ctx, req, ticket)
+		return hotels, false, err
+	}
+	delay = max(delay, u.hedgeMinDelay)
+
+	// Cancelling callCtx on return stops whichever call lost the race
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so the losing goroutine never blocks
+	results := make(chan attemptResult, 2)
+	launch := func(ticket BreakerTicket, hedge bool) {
+		go func() {
+			hotels, err := u.attempt(callCtx, req, ticket)
+			results <- attemptResult{hotels: hotels, err: err, hedge: hedge}
+		}()
+	}
+	launch(ticket, false)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.hotels, false, r.err
+	case <-timer.C:
+	}
+
+	// Fall back to waiting on the original call when the breaker or the cap says no.
+	// The breaker is asked first so an open breaker does not burn hedge tokens
+	hedgeTicket, ok := u.admit()
+	if !ok {
+		r := <-results
+		return r.hotels, false, r.err
+	}
+	if !u.hedges.allow() {
+		// Hand the unused admission back; a cancellation is not counted, but it
+		// frees the slot of a half-open probe
+		if u.breaker != nil {
+			u.breaker.Record(hedgeTicket, context.Canceled)
+		}
+		r := <-results
+		return r.hotels, false, r.err
+	}
+
+	u.metrics.HedgeFired(u.name)
+	launch(hedgeTicket, true)
+
+	r := <-results
+	if r.err != nil {
+		// The first call to finish failed; the other one may still succeed
+		if other := <-results; other.err == nil {
+			r = other
+		}
+	}
+
+	if r.err == nil && r.hedge {
+		u.metrics.HedgeWon(u.name)
+	}
+
+	return r.hotels, true, r.err
+}
+
+// attempt makes a single provider call and records its outcome against ticket
+func (u *upstream) attempt(ctx context.Context, req models.SearchRequest, ticket BreakerTicket) ([]models.ProviderHotel, error) {
+	start := time.Now()
+	hotels, err := u.provider.Search(ctx, req)
+	elapsed := time.Since(start)
+	u.metrics.ObserveProviderCall(u.name, elapsed, err)
+
+	// Cancellations come from our side (hedge losers, client disconnects) and
+	// are not provider failures
+	if kind := providers.KindOf(err); err != nil && kind != providers.KindCanceled {
+		u.metrics.ProviderError(u.name, kind.String())
+	}
+
+	if u.breaker != nil {
+		u.breaker.Record(ticket, err)
+	}
+
+	if err == nil && u.latency != nil {
+		u.latency.observe(elapsed)
+	}
+
+	// Offers carry their provider, its price basis and their fetch time from here
+	// on, cached ones included
+	hotels = u.annotate(hotels, start.Add(elapsed))
+
+	return hotels, err
+}
+
+// annotate returns a copy of hotels with the provider's name on every offer, its
+// declared price basis on offers that do not state their own and fetchedAt, unless
+// zero, on offers without a fetch time. It never writes to hotels, since cached
+// offers are shared between concurrent searches
+func (u *upstream) annotate(hotels []models.ProviderHotel, fetchedAt time.Time) []models.ProviderHotel {
+	if hotels == nil {
+		return nil
+	}
+	out := make([]models.ProviderHotel, len(hotels))
+	for i, h := range hotels {
+		h.Provider = u.name
+		if h.Basis == "" {
+			h.Basis = u.pricing.Basis
+		}
+		if h.FetchedAt.IsZero() && !fetchedAt.IsZero() {
+			h.FetchedAt = fetchedAt
+		}
+		out[i] = h
+	}
+	return out
+}
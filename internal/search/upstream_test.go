@@ -0,0 +1,35 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/models"
+	"hostaggr/internal/providers"
+)
+
+func TestAnnotateLeavesSharedOffersUntouched(t *testing.T) {
+	u := &upstream{name: "Mock1", pricing: providers.Pricing{Basis: models.PerNight}}
+	cached := []models.ProviderHotel{{HotelID: "H123"}}
+
+	got := u.annotate(cached, time.Now())
+	if got[0].Provider != "Mock1" || got[0].Basis != models.PerNight || got[0].FetchedAt.IsZero() {
+		t.Fatalf("annotate() = %+v", got[0])
+	}
+	if h := cached[0]; h.Provider != "" || h.Basis != "" || !h.FetchedAt.IsZero() {
+		t.Fatalf("annotate() wrote to its input: %+v", cached[0])
+	}
+}
+
+func TestUpstreamCacheTTLFollowsProviderHint(t *testing.T) {
+	cfg := config.Default().Aggregator
+	req := testSearch()
+
+	if got := newUpstream(providers.NewMock3(), cfg, nil, nil).cacheTTL(req); got != 2*time.Minute {
+		t.Fatalf("Mock3 cache TTL %s, want its declared 2m", got)
+	}
+	if got := newUpstream(providers.NewMock1(), cfg, nil, nil).cacheTTL(req); got != 0 {
+		t.Fatalf("Mock1 cache TTL %s, want 0 for the configured default", got)
+	}
+}
@@ -0,0 +1,387 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"hostaggr/internal/config"
+	"hostaggr/internal/models"
+	"hostaggr/internal/obs"
+)
+
+// Warm sources and outcomes, as reported in metrics
+const (
+	warmSeed    = "seed"
+	warmTraffic = "traffic"
+
+	warmWarmed = "warmed"
+	warmFresh  = "fresh" // already cached, providers not queried
+	warmFailed = "error"
+)
+
+// warmPattern is one line of the seed file. CheckIn names a fixed date; DaysAhead
+// expands to one search per offset from today, so a seed stays useful over time.
+// Currency is optional and defaults like a search's
+type warmPattern struct {
+	City      string `json:"city"`
+	CheckIn   string `json:"checkin"`
+	DaysAhead []int  `json:"days_ahead"`
+	Nights    int    `json:"nights"`
+	Adults    int    `json:"adults"`
+	Currency  string `json:"currency"`
+}
+
+// validate checks a pattern read from line n of the seed file
+func (p warmPattern) validate(n int) error {
+	switch {
+	case p.City == "":
+		return fmt.Errorf("seed line %d: city is required", n)
+	case p.CheckIn == "" && len(p.DaysAhead) == 0:
+		return fmt.Errorf("seed line %d: checkin or days_ahead is required", n)
+	case p.CheckIn != "" && len(p.DaysAhead) > 0:
+		return fmt.Errorf("seed line %d: checkin and days_ahead are mutually exclusive", n)
+	case p.Nights <= 0 || p.Adults <= 0:
+		return fmt.Errorf("seed line %d: nights and adults must be positive integers", n)
+	}
+	if p.CheckIn != "" {
+		if _, err := time.Parse(time.DateOnly, p.CheckIn); err != nil {
+			return fmt.Errorf("seed line %d: checkin must be in YYYY-MM-DD format", n)
+		}
+	}
+	for _, d := range p.DaysAhead {
+		if d < 0 {
+			return fmt.Errorf("seed line %d: days_ahead must not be negative", n)
+		}
+	}
+	return nil
+}
+
+// requests expands the pattern into the searches it stands for as of today
+func (p warmPattern) requests(today time.Time) []models.SearchRequest {
+	req := models.SearchRequest{City: p.City, CheckIn: p.CheckIn, Nights: p.Nights, Adults: p.Adults, Currency: p.Currency}
+	if p.CheckIn != "" {
+		return []models.SearchRequest{req}
+	}
+
+	reqs := make([]models.SearchRequest, 0, len(p.DaysAhead))
+	for _, d := range p.DaysAhead {
+		req.CheckIn = today.AddDate(0, 0, d).Format(time.DateOnly)
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+// loadWarmPatterns reads a seed file of one JSON pattern per line. Blank lines are skipped
+func loadWarmPatterns(path string) ([]warmPattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open warmer seed file: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []warmPattern
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+
+		var p warmPattern
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("seed line %d: %w", n, err)
+		}
+		if err := p.validate(n); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read warmer seed file: %w", err)
+	}
+
+	return patterns, nil
+}
+
+// trafficDecay is the share of a search's count kept from one warm cycle to the next
+const trafficDecay = 0.8
+
+// searchTracker counts live searches so the most popular can be warmed. Counts decay
+// every warm cycle so the ranking follows changing traffic
+type searchTracker struct {
+	mu     sync.Mutex
+	counts map[cacheKey]*trackedSearch
+	limit  int // distinct searches tracked; new ones are ignored once reached
+}
+
+type trackedSearch struct {
+	req   models.SearchRequest
+	count float64
+}
+
+func newSearchTracker(limit int) *searchTracker {
+	return &searchTracker{
+		counts: make(map[cacheKey]*trackedSearch),
+		limit:  limit,
+	}
+}
+
+// record counts one search
+func (t *searchTracker) record(req models.SearchRequest) {
+	key := newCacheKey(req)
+	req.MaxWait = 0
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s, exists := t.counts[key]; exists {
+		s.count++
+		return
+	}
+	if len(t.counts) < t.limit {
+		t.counts[key] = &trackedSearch{req: req, count: 1}
+	}
+}
+
+// top returns up to n of the most counted searches that do not check in before today
+func (t *searchTracker) top(n int, today string) []models.SearchRequest {
+	t.mu.Lock()
+	tracked := make([]trackedSearch, 0, len(t.counts))
+	for _, s := range t.counts {
+		if s.req.CheckIn >= today {
+			tracked = append(tracked, *s)
+		}
+	}
+	t.mu.Unlock()
+
+	sort.Slice(tracked, func(i, j int) bool {
+		return tracked[i].count > tracked[j].count
+	})
+
+	reqs := make([]models.SearchRequest, 0, min(n, len(tracked)))
+	for _, s := range tracked[:min(n, len(tracked))] {
+		reqs = append(reqs, s.req)
+	}
+	return reqs
+}
+
+// decay ages every count and forgets searches that have faded below half a search,
+// which also frees room for new ones
+func (t *searchTracker) decay() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, s := range t.counts {
+		s.count *= trafficDecay
+		if s.count < 0.5 {
+			delete(t.counts, key)
+		}
+	}
+}
+
+// warmTarget is one search to warm and where it came from
+type warmTarget struct {
+	req    models.SearchRequest
+	source string
+}
+
+// Warmer pre-populates the cache on a schedule with the searches of a seed file and
+// the most popular live searches. Warm searches run at most Concurrency at a time and
+// start at most MaxPerSecond per second, so they stay within the providers' rate budget
+type Warmer struct {
+	agg         *Aggregator
+	patterns    []warmPattern
+	traffic     *searchTracker
+	topN        int
+	interval    time.Duration
+	concurrency int
+	perSecond   float64
+	metrics     *obs.Metrics
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWarmer loads the seed file, starts counting the aggregator's searches when
+// cfg.TopN is positive and starts the background warm loop. The first cycle runs immediately
+func NewWarmer(agg *Aggregator, cfg config.WarmerConfig, metrics *obs.Metrics) (*Warmer, error) {
+	w := &Warmer{
+		agg:         agg,
+		topN:        cfg.TopN,
+		interval:    cfg.Interval,
+		concurrency: cfg.Concurrency,
+		perSecond:   cfg.MaxPerSecond,
+		metrics:     metrics,
+		done:        make(chan struct{}),
+	}
+
+	if cfg.SeedFile != "" {
+		patterns, err := loadWarmPatterns(cfg.SeedFile)
+		if err != nil {
+			return nil, err
+		}
+		w.patterns = patterns
+	}
+
+	if cfg.TopN > 0 {
+		w.traffic = newSearchTracker(cfg.Tracked)
+		agg.trackSearches(w.traffic)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	// Start background warm goroutine
+	go w.run(ctx)
+
+	return w, nil
+}
+
+// run warms once per interval until ctx is cancelled
+func (w *Warmer) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.cycle(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cycle warms every current target, pacing starts to the per-second budget
+func (w *Warmer) cycle(ctx context.Context) {
+	targets := w.targets(time.Now().UTC())
+	if len(targets) == 0 {
+		return
+	}
+
+	pace := time.NewTicker(time.Duration(float64(time.Second) / w.perSecond))
+	defer pace.Stop()
+
+	sem := make(chan struct{}, w.concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	outcomes := make(map[string]int)
+
+	for i, t := range targets {
+		// The first search starts at once, the rest wait for the pace
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-pace.C:
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(t warmTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			outcome := w.warm(ctx, t)
+			mu.Lock()
+			outcomes[outcome]++
+			mu.Unlock()
+		}(t)
+	}
+
+	wg.Wait()
+
+	if w.traffic != nil {
+		w.traffic.decay()
+	}
+
+	slog.Debug("cache warm cycle finished", "targets", len(targets),
+		"warmed", outcomes[warmWarmed], "fresh", outcomes[warmFresh], "failed", outcomes[warmFailed])
+}
+
+// warm fills the cache for one target and records its outcome
+func (w *Warmer) warm(ctx context.Context, t warmTarget) string {
+	queried, err := w.agg.Warm(ctx, t.req)
+
+	outcome := warmWarmed
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Stopped mid-cycle; nothing to record
+		return warmFailed
+	case err != nil:
+		outcome = warmFailed
+		slog.Warn("cache warm failed", "city", t.req.City, "checkin", t.req.CheckIn, "source", t.source, "error", err)
+	case !queried:
+		outcome = warmFresh
+	}
+
+	w.metrics.CacheWarm(t.source, outcome)
+	return outcome
+}
+
+// targets lists the searches to warm as of now: seed searches first, then the most
+// popular live searches not already seeded. Searches checking in before today are skipped
+func (w *Warmer) targets(now time.Time) []warmTarget {
+	today := now.Format(time.DateOnly)
+	midnight, _ := time.Parse(time.DateOnly, today)
+
+	seen := make(map[cacheKey]bool)
+	var targets []warmTarget
+	add := func(req models.SearchRequest, source string) {
+		// Normalize first so that equivalent seeds and searches are warmed once
+		req, err := w.agg.Normalize(req)
+		if err != nil {
+			return
+		}
+		key := newCacheKey(req)
+		if req.CheckIn < today || seen[key] {
+			return
+		}
+		seen[key] = true
+		targets = append(targets, warmTarget{req: req, source: source})
+	}
+
+	for _, p := range w.patterns {
+		for _, req := range p.requests(midnight) {
+			add(req, warmSeed)
+		}
+	}
+	if w.traffic != nil {
+		for _, req := range w.traffic.top(w.topN, today) {
+			add(req, warmTraffic)
+		}
+	}
+
+	return targets
+}
+
+// Stop cancels any warm searches in progress and waits for the loop to exit.
+// It is safe to call more than once
+func (w *Warmer) Stop() {
+	w.stopOnce.Do(func() {
+		w.cancel()
+		<-w.done
+	})
+}
@@ -0,0 +1,205 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"hostaggr/internal/models"
+)
+
+func writeSeed(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "seed.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWarmPatterns(t *testing.T) {
+	path := writeSeed(t,
+		`{"city": "Marrakech", "checkin": "2026-12-24", "nights": 2, "adults": 2}`,
+		``,
+		`  {"city": "Paris", "days_ahead": [0, 7, 14], "nights": 1, "adults": 1, "currency": "usd"}  `,
+	)
+	patterns, err := loadWarmPatterns(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("%d patterns, want 2 with the blank line skipped", len(patterns))
+	}
+
+	today := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	if reqs := patterns[0].requests(today); len(reqs) != 1 || reqs[0].CheckIn != "2026-12-24" {
+		t.Fatalf("fixed checkin expands to %+v", reqs)
+	}
+	var checkins []string
+	for _, req := range patterns[1].requests(today) {
+		checkins = append(checkins, req.CheckIn)
+	}
+	if want := []string{"2026-11-02", "2026-11-09", "2026-11-16"}; !slices.Equal(checkins, want) {
+		t.Fatalf("days_ahead expands to %v, want %v", checkins, want)
+	}
+}
+
+func TestLoadWarmPatternsRejectsBadLines(t *testing.T) {
+	for name, line := range map[string]string{
+		"not json":           `city=Paris`,
+		"unknown field":      `{"city": "Paris", "checkin": "2026-12-24", "nights": 1, "adults": 1, "rooms": 2}`,
+		"no city":            `{"checkin": "2026-12-24", "nights": 1, "adults": 1}`,
+		"no date":            `{"city": "Paris", "nights": 1, "adults": 1}`,
+		"both dates":         `{"city": "Paris", "checkin": "2026-12-24", "days_ahead": [1], "nights": 1, "adults": 1}`,
+		"bad checkin":        `{"city": "Paris", "checkin": "24/12/2026", "nights": 1, "adults": 1}`,
+		"negative days":      `{"city": "Paris", "days_ahead": [1, -1], "nights": 1, "adults": 1}`,
+		"no nights":          `{"city": "Paris", "checkin": "2026-12-24", "adults": 1}`,
+		"non-positive adult": `{"city": "Paris", "checkin": "2026-12-24", "nights": 1, "adults": 0}`,
+	} {
+		path := writeSeed(t, `{"city": "Marrakech", "checkin": "2026-12-24", "nights": 2, "adults": 2}`, line)
+		_, err := loadWarmPatterns(path)
+		if err == nil || !strings.Contains(err.Error(), "seed line 2") {
+			t.Errorf("%s: error %v, want one naming line 2", name, err)
+		}
+	}
+
+	if _, err := loadWarmPatterns(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("a missing seed file loaded")
+	}
+}
+
+func popularSearch(city, checkin string) models.SearchRequest {
+	return models.SearchRequest{City: city, CheckIn: checkin, Nights: 2, Adults: 2, Currency: "EUR"}
+}
+
+func TestSearchTrackerTop(t *testing.T) {
+	tr := newSearchTracker(3)
+	for city, n := range map[string]int{"paris": 5, "marrakech": 2, "rome": 9} {
+		for range n {
+			tr.record(popularSearch(city, "2026-12-01"))
+		}
+	}
+	// The tracker is full, so new searches are not counted
+	tr.record(popularSearch("lisbon", "2026-12-01"))
+
+	var cities []string
+	for _, req := range tr.top(2, "2026-11-02") {
+		cities = append(cities, req.City)
+	}
+	if !slices.Equal(cities, []string{"rome", "paris"}) {
+		t.Fatalf("top(2) = %v, want rome and paris", cities)
+	}
+	if got := tr.top(10, "2026-11-02"); len(got) != 3 {
+		t.Fatalf("top(10) returned %d searches, want the 3 tracked", len(got))
+	}
+	// Searches checking in before today are not worth warming
+	if got := tr.top(10, "2026-12-02"); len(got) != 0 {
+		t.Fatalf("top(10) after the checkin returned %+v", got)
+	}
+}
+
+func TestSearchTrackerDecay(t *testing.T) {
+	tr := newSearchTracker(10)
+	once, popular := popularSearch("paris", "2026-12-01"), popularSearch("rome", "2026-12-01")
+	tr.record(once)
+	for range 4 {
+		tr.record(popular)
+	}
+
+	// 1 search decays to 0.8 and 0.64, then below half a search after 4 cycles
+	for cycle := 1; cycle <= 4; cycle++ {
+		tr.decay()
+		_, tracked := tr.counts[newCacheKey(once)]
+		if tracked != (cycle < 4) {
+			t.Fatalf("after %d decays the single search is tracked: %t", cycle, tracked)
+		}
+	}
+	if got := tr.counts[newCacheKey(popular)].count; got < 4*0.8*0.8*0.8*0.8-1e-9 || got > 4*0.8*0.8*0.8*0.8+1e-9 {
+		t.Fatalf("popular count %g after 4 decays, want 4 * 0.8^4", got)
+	}
+
+	// Forgotten searches free room and start counting again
+	tr.record(once)
+	if got := tr.counts[newCacheKey(once)].count; got != 1 {
+		t.Fatalf("count %g for a forgotten search seen again, want 1", got)
+	}
+}
+
+// newTestWarmer returns a warmer over a's searches without starting its loop
+func newTestWarmer(a *Aggregator, concurrency int, perSecond float64, patterns ...warmPattern) *Warmer {
+	return &Warmer{
+		agg:         a,
+		patterns:    patterns,
+		concurrency: concurrency,
+		perSecond:   perSecond,
+		metrics:     a.metrics,
+	}
+}
+
+func TestWarmerCycleRespectsConcurrency(t *testing.T) {
+	p := newStubProvider("Mock1")
+	open := p.gated()
+	defer open()
+	a := newTestAggregator(t, newTestCache(t), p)
+	w := newTestWarmer(a, 2, 1000, warmPattern{City: "Marrakech", DaysAhead: []int{1, 2, 3, 4, 5}, Nights: 2, Adults: 2})
+
+	done := make(chan struct{})
+	go func() {
+		w.cycle(context.Background())
+		close(done)
+	}()
+
+	// Two warm searches hold both slots; at 1000/s the rest would have started by now
+	waitFor(t, "two warm searches", func() bool { return p.calls.Load() == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if got := p.calls.Load(); got != 2 {
+		t.Fatalf("%d warm searches started with a concurrency of 2", got)
+	}
+
+	open()
+	<-done
+	if got, peak := p.calls.Load(), p.peak.Load(); got != 5 || peak != 2 {
+		t.Fatalf("%d warm searches at most %d at once, want 5 and 2", got, peak)
+	}
+	if got := a.metrics.Registry().Sum("hostaggr_cache_warm_total"); got != 5 {
+		t.Fatalf("%g warm outcomes recorded, want 5", got)
+	}
+
+	// Everything is fresh now, so the next cycle queries nobody
+	w.cycle(context.Background())
+	if got := p.calls.Load(); got != 5 {
+		t.Fatalf("provider called %d times after a cycle over fresh entries, want 5", got)
+	}
+}
+
+func TestWarmerCycleRespectsPace(t *testing.T) {
+	p := newStubProvider("Mock1")
+	a := newTestAggregator(t, newTestCache(t), p)
+
+	// Seeds first, then popular searches not already seeded
+	a.trackSearches(newSearchTracker(10))
+	w := newTestWarmer(a, 4, 50, warmPattern{City: "Marrakech", DaysAhead: []int{1, 2}, Nights: 2, Adults: 2})
+	w.traffic, w.topN = a.searches, 10
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	a.recordSearch(popularSearch("marrakech", tomorrow))
+	a.recordSearch(popularSearch("paris", tomorrow))
+
+	targets := w.targets(time.Now().UTC())
+	if len(targets) != 3 || targets[2].source != warmTraffic || targets[2].req.City != "paris" {
+		t.Fatalf("targets %+v, want two seeds and Paris from traffic", targets)
+	}
+
+	// At 50 per second the third search starts no sooner than 40ms in
+	start := time.Now()
+	w.cycle(context.Background())
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("cycle of 3 warm searches took %s, want at least 40ms at 50/s", elapsed)
+	}
+	if got := p.calls.Load(); got != 3 {
+		t.Fatalf("provider called %d times, want 3", got)
+	}
+}
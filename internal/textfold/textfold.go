@@ -0,0 +1,26 @@
+// Package textfold folds text for comparison, so that names typed with different
+// case or accents compare equal
+package textfold
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fold strips combining marks after canonical decomposition and case-folds the
+// result with Unicode rules, so "Fès" and "FES" both become "fes"
+func Fold(s string) string {
+	// A transformer chain is stateful, so one is built per call
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		// Only reachable with invalid UTF-8; fall back to simple folding
+		return strings.ToLower(s)
+	}
+	return folded
+}
@@ -0,0 +1,17 @@
+package textfold
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	for in, want := range map[string]string{
+		"Fès":         "fes",
+		"FES":         "fes",
+		"São Paulo":   "sao paulo",
+		"Le Méridien": "le meridien",
+		"Straße":      "strasse",
+	} {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+}